@@ -17,13 +17,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount/reconciler"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/cache"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
 	cvmfsversion "github.com/cvmfs-contrib/cvmfs-csi/internal/version"
 
 	"k8s.io/klog/v2"
@@ -32,6 +37,19 @@ import (
 var (
 	version = flag.Bool("version", false, "Print driver version and exit.")
 	period  = flag.Duration("period", time.Second*30, "How often to check and reconcile autofs-managed CVMFS mounts.")
+
+	kubeletPodsDir = flag.String("kubelet-pods-dir", "", "Kubelet's pods directory. If set, stale CVMFS mounts in volume target paths inside of it are re-bound from /cvmfs. Disabled if empty.")
+
+	cacheAdminEndpoint   = flag.String("cache-admin-endpoint", "", "UNIX domain socket endpoint (unix://<absolute path to socket>) to serve the cache admin API on. The API is disabled if empty.")
+	cacheHighWatermark   = flag.Float64("cache-high-watermark", 0, "Usage ratio (0-1) of the local cache volume at which the cache is cleaned up. '0' disables watermark cleanup.")
+	cacheLowWatermark    = flag.Float64("cache-low-watermark", 0.7, "Usage ratio (0-1) of the local cache volume to clean up the cache down to, once it crossed the high watermark.")
+	cacheWatermarkPeriod = flag.Duration("cache-watermark-check-period", time.Minute, "How often to check local cache volume usage against the high watermark.")
+
+	metricsAddress = flag.String("metrics-address", "", "Address (host:port) to serve Prometheus metrics on. Metrics are disabled if empty.")
+
+	logFormat = flag.String("log-format", log.FormatText, "Log output format. Allowed values are: 'text', 'json'.")
+
+	tracingExporter = flag.String("tracing-exporter", "", "OpenTelemetry trace exporter. Allowed values are: 'otlp' (configured with OTEL_EXPORTER_OTLP_* environment variables), 'stdout', 'file://<absolute path>'. Tracing is disabled if empty.")
 )
 
 func main() {
@@ -43,6 +61,10 @@ func main() {
 	}
 	flag.Parse()
 
+	if err := log.SetFormat(*logFormat); err != nil {
+		klog.Exitf("failed to set log format: %v", err)
+	}
+
 	if *version {
 		fmt.Println("automount-reconciler for CVMFS CSI plugin version", cvmfsversion.FullVersion())
 		os.Exit(0)
@@ -53,14 +75,48 @@ func main() {
 	log.Infof("automount-reconciler for CVMFS CSI plugin version %s", cvmfsversion.FullVersion())
 	log.Infof("Command line arguments %v", os.Args)
 
+	if *metricsAddress != "" {
+		if err := metrics.Serve(*metricsAddress); err != nil {
+			log.Fatalf("Failed to serve metrics: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "automount-reconciler", *tracingExporter)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
+	if *cacheAdminEndpoint != "" || *cacheHighWatermark > 0 {
+		cacheDir, err := automount.LocalCacheDir()
+		if err != nil {
+			log.Fatalf("Failed to get local cache location: %v", err)
+		}
+
+		err = cache.Start(&cache.Opts{
+			CacheDir:             cacheDir,
+			AdminEndpoint:        *cacheAdminEndpoint,
+			HighWatermark:        *cacheHighWatermark,
+			LowWatermark:         *cacheLowWatermark,
+			WatermarkCheckPeriod: *cacheWatermarkPeriod,
+		})
+		if err != nil {
+			log.Fatalf("Failed to start cache management: %v", err)
+		}
+	}
+
 	// Run blocking.
 
-	err := mountreconcile.RunBlocking(&mountreconcile.Opts{
-		Period: *period,
+	err = mountreconcile.RunBlocking(&mountreconcile.Opts{
+		Period:  *period,
+		PodsDir: *kubeletPodsDir,
 	})
 	if err != nil {
 		log.Fatalf("Failed to run mount-reconciler: %v", err)
 	}
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Errorf("Failed to flush traces: %v", err)
+	}
+
 	os.Exit(0)
 }
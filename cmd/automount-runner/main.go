@@ -17,13 +17,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/env"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
 	cvmfsversion "github.com/cvmfs-contrib/cvmfs-csi/internal/version"
 
 	"k8s.io/klog/v2"
@@ -34,7 +38,23 @@ var (
 
 	hasAlienCache = flag.Bool("has-alien-cache", false, "CVMFS client is using alien cache volume")
 
-	unmountTimeoutSeconds = flag.Int("unmount-timeout", 300, "number of seconds of idle time after which an autofs-managed CVMFS mount will be unmounted. '0' means never unmount")
+	cachePreservation = flag.String("cache-preservation", automount.CachePreservationWipe, "What to do with the local cache left behind by previous runs. Allowed values are: 'wipe' (remove it), 'verify' (check it with cvmfs_fsck and keep it if healthy, remove it otherwise), 'keep' (keep it as is).")
+
+	unmountTimeoutSeconds = flag.Int("unmount-timeout", 300, "number of seconds of idle time after which an autofs-managed CVMFS mount will be unmounted. '0' means never unmount, negative value leaves the automount daemon's default (not supported with --automounter=native)")
+
+	automounter = flag.String("automounter", automount.AutomounterExternal, "Automounter serving /cvmfs. Allowed values are: 'external' (automount daemon from autofs), 'native' (built-in automounter talking to the autofs kernel module directly).")
+
+	configFilepath = flag.String("config", "", "Path to a JSON file with per-repository automount settings: unmount timeouts, extra mount options and repositories that must not be automounted. The file is re-read on SIGHUP.")
+
+	handoverDir = flag.String("handover-dir", "", "Directory shared with the previous and the next automount-runner instance, used to hand over /cvmfs during upgrades without breaking existing mounts. Supported only with --automounter=native. Handover is disabled if empty.")
+
+	drainTimeout = flag.Duration("drain-timeout", 5*time.Minute, "How long to keep CVMFS clients running after /cvmfs was handed over to the next automount-runner instance, waiting for their mounts to be replaced.")
+
+	metricsAddress = flag.String("metrics-address", "", "Address (host:port) to serve Prometheus metrics on. Metrics are disabled if empty.")
+
+	logFormat = flag.String("log-format", log.FormatText, "Log output format. Allowed values are: 'text', 'json'.")
+
+	tracingExporter = flag.String("tracing-exporter", "", "OpenTelemetry trace exporter. Allowed values are: 'otlp' (configured with OTEL_EXPORTER_OTLP_* environment variables), 'stdout', 'file://<absolute path>'. Tracing is disabled if empty.")
 )
 
 func main() {
@@ -46,6 +66,10 @@ func main() {
 	}
 	flag.Parse()
 
+	if err := log.SetFormat(*logFormat); err != nil {
+		klog.Exitf("failed to set log format: %v", err)
+	}
+
 	if *version {
 		fmt.Println("automount-runner for CVMFS CSI plugin version", cvmfsversion.FullVersion())
 		os.Exit(0)
@@ -57,17 +81,62 @@ func main() {
 	log.Infof("Command line arguments %v", os.Args)
 	log.Infof("Environment variables %s", env.StringAutofsTryCleanAtExit())
 
-	err := automount.Init(&automount.Opts{
+	if err := automount.ValidateCachePreservation(*cachePreservation); err != nil {
+		log.Fatalf("Invalid --cache-preservation: %v", err)
+	}
+
+	if err := automount.ValidateAutomounter(*automounter); err != nil {
+		log.Fatalf("Invalid --automounter: %v", err)
+	}
+
+	if *unmountTimeoutSeconds < 0 && *automounter == automount.AutomounterNative {
+		// Negative timeout means the autofs daemon's default, there is no such thing here.
+		log.Fatalf("Negative --unmount-timeout is supported only with --automounter=%s, use '0' to never unmount",
+			automount.AutomounterExternal)
+	}
+
+	if *handoverDir != "" && *automounter != automount.AutomounterNative {
+		log.Fatalf("--handover-dir is supported only with --automounter=%s", automount.AutomounterNative)
+	}
+
+	if *metricsAddress != "" {
+		if err := metrics.Serve(*metricsAddress); err != nil {
+			log.Fatalf("Failed to serve metrics: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "automount-runner", *tracingExporter)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
+	automountOpts := &automount.Opts{
 		UnmountTimeoutSeconds: *unmountTimeoutSeconds,
+		Automounter:           *automounter,
+		ConfigFilepath:        *configFilepath,
+		HandoverDir:           *handoverDir,
+		DrainTimeout:          *drainTimeout,
 		HasAlienCache:         *hasAlienCache,
-	})
-	if err != nil {
+		CachePreservation:     *cachePreservation,
+	}
+
+	if err = automount.Init(automountOpts); err != nil {
 		log.Fatalf("Failed to initialize automount-runner: %v", err)
 	}
 
-	if err = automount.RunBlocking(); err != nil {
+	if *automounter == automount.AutomounterNative {
+		err = automount.RunNativeBlocking(automountOpts)
+	} else {
+		err = automount.RunBlocking(automountOpts)
+	}
+
+	if err != nil {
 		log.Fatalf("Failed to run automount-runner: %v", err)
 	}
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Errorf("Failed to flush traces: %v", err)
+	}
+
 	os.Exit(0)
 }
@@ -17,13 +17,18 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/driver"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/node"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
 	cvmfsversion "github.com/cvmfs-contrib/cvmfs-csi/internal/version"
 
 	"k8s.io/klog/v2"
@@ -64,6 +69,18 @@ var (
 	startAutomountDaemon      = flag.Bool("start-automount-daemon", true, "(DEPRECATED: use automount-runner) start automount daemon when initializing CVMFS CSI driver")
 	singlemountRunnerendpoint = flag.String("singlemount-runner-endpoint", "unix:///var/lib/cvmfs.cern.ch/singlemount-runner.sock", "singlemount-runner endpoint.")
 
+	namespacePolicy = flag.String("namespace-policy", "", "Path to JSON file with namespace policy: a map of CVMFS repositories to lists of namespaces (glob patterns) whose Pods are allowed to mount them, and rules listing repositories that volumes are permitted to expose, keyed by namespace, service account or volume attributes. Policy is disabled if empty.")
+	listPublished   = flag.Bool("list-published", false, "Print records of volumes published on this node as JSON and exit.")
+	kubeletPodsDir  = flag.String("kubelet-pods-dir", "", "Kubelet's pods directory. If set, publish records of volumes mounted inside of it that are missing a record are rebuilt at startup. Disabled if empty.")
+
+	volumeRegistry = flag.String("volume-registry", "", "Registry of created volumes used by the controller service to serve ListVolumes and ControllerGetVolume RPCs. Supported backends: 'configmap://<namespace>/<name>', 'file://<absolute path to file>'. Registry is disabled if empty.")
+
+	metricsAddress = flag.String("metrics-address", "", "Address (host:port) to serve Prometheus metrics on. Metrics are disabled if empty.")
+
+	logFormat = flag.String("log-format", log.FormatText, "Log output format. Allowed values are: 'text', 'json'.")
+
+	tracingExporter = flag.String("tracing-exporter", "", "OpenTelemetry trace exporter. Allowed values are: 'otlp' (configured with OTEL_EXPORTER_OTLP_* environment variables), 'stdout', 'file://<absolute path>'. Tracing is disabled if empty.")
+
 	automountDaemonStartupTimeoutSeconds   = flag.Int("automount-startup-timeout", 10, "number of seconds to wait for automount daemon to start up before giving up and exiting. '0' means wait forever")
 	automountDaemonUnmountAfterIdleSeconds = flag.Int("automount-unmount-timeout", 300, "(DEPRECATED: use automount-runner --unmount-timeout) number of seconds of idle time after which an autofs-managed CVMFS mount will be unmounted. '0' means never unmount, '-1' leaves automount default option.")
 )
@@ -79,16 +96,46 @@ func main() {
 	}
 	flag.Parse()
 
+	if err := log.SetFormat(*logFormat); err != nil {
+		klog.Exitf("failed to set log format: %v", err)
+	}
+
 	if *version {
 		fmt.Println("CVMFS CSI plugin version", cvmfsversion.FullVersion())
 		os.Exit(0)
 	}
 
+	if *listPublished {
+		recs, err := node.ListPublishRecords()
+		if err != nil {
+			klog.Exitf("failed to list publish records: %v", err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(recs); err != nil {
+			klog.Exitf("failed to encode publish records: %v", err)
+		}
+
+		os.Exit(0)
+	}
+
 	// Initialize and run the driver.
 
 	log.Infof("CVMFS CSI plugin version %s", cvmfsversion.FullVersion())
 	log.Infof("Command line arguments %v", os.Args)
 
+	if *metricsAddress != "" {
+		if err := metrics.Serve(*metricsAddress); err != nil {
+			log.Fatalf("Failed to serve metrics: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "csi-cvmfsplugin", *tracingExporter)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
 	driverRoles := make(map[driver.ServiceRole]bool, len(roles))
 	for _, role := range roles {
 		driverRoles[role] = true
@@ -102,6 +149,9 @@ func main() {
 		Roles:                     driverRoles,
 
 		AutomountDaemonStartupTimeoutSeconds: *automountDaemonStartupTimeoutSeconds,
+		NamespacePolicyFilepath:              *namespacePolicy,
+		KubeletPodsDir:                       *kubeletPodsDir,
+		VolumeRegistryURL:                    *volumeRegistry,
 	})
 	if err != nil {
 		log.Fatalf("Failed to initialize the driver: %v", err)
@@ -112,5 +162,9 @@ func main() {
 		log.Fatalf("Failed to run the driver: %v", err)
 	}
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Errorf("Failed to flush traces: %v", err)
+	}
+
 	os.Exit(0)
 }
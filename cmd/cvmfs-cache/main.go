@@ -0,0 +1,119 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/cache"
+	cvmfsversion "github.com/cvmfs-contrib/cvmfs-csi/internal/version"
+)
+
+var (
+	version  = flag.Bool("version", false, "Print driver version and exit.")
+	endpoint = flag.String("endpoint", "unix:///run/cvmfs-csi-cache-admin.sock", "Cache admin API endpoint served by automount-reconciler.")
+	timeout  = flag.Duration("timeout", time.Minute, "Timeout of the cache admin API request.")
+)
+
+const usage = `Usage: %s [flags] <command> [args]
+
+Manage the local CVMFS cache of CVMFS CSI nodeplugin.
+
+Commands:
+  usage                          Show cache volume usage and cache usage of each mounted repository.
+  evict <repository> <path>      Remove a file at path inside the repository from the cache.
+  pin <repository> <path>        Pin a file at path inside the repository in the cache.
+  cleanup <target MB> [repository]
+                                 Shrink the cache of the repository, or of all mounted
+                                 repositories, down to target MB.
+
+Flags:
+`
+
+func exitf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *version {
+		fmt.Println("cvmfs-cache for CVMFS CSI plugin version", cvmfsversion.FullVersion())
+		os.Exit(0)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cl, err := cache.NewClient(*endpoint)
+	if err != nil {
+		exitf("%v", err)
+	}
+
+	var out any
+
+	switch cmd := args[0]; {
+	case cmd == "usage" && len(args) == 1:
+		out, err = cl.Usage(ctx)
+	case cmd == "evict" && len(args) == 3:
+		out, err = cl.Evict(ctx, args[1], args[2])
+	case cmd == "pin" && len(args) == 3:
+		out, err = cl.Pin(ctx, args[1], args[2])
+	case cmd == "cleanup" && (len(args) == 2 || len(args) == 3):
+		targetMB, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil || targetMB < 0 {
+			exitf("invalid cleanup target %q, expected a non-negative number of MB", args[1])
+		}
+
+		repo := ""
+		if len(args) == 3 {
+			repo = args[2]
+		}
+
+		out, err = cl.Cleanup(ctx, repo, targetMB<<20)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		exitf("%v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(out); err != nil {
+		exitf("failed to encode response: %v", err)
+	}
+}
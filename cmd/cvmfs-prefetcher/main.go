@@ -0,0 +1,102 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/prefetcher"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
+	cvmfsversion "github.com/cvmfs-contrib/cvmfs-csi/internal/version"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	version    = flag.Bool("version", false, "Print driver version and exit.")
+	configFile = flag.String("config", "/etc/cvmfs-csi/prefetcher-config/config.json", "Path to the JSON file with prefetching jobs.")
+	cvmfsRoot  = flag.String("cvmfs-root", "/cvmfs", "Path to the autofs-managed CVMFS root.")
+	period     = flag.Duration("period", time.Hour, "How often to run prefetching jobs that don't set their own period.")
+	maxReads   = flag.Int("max-concurrent-reads", 4, "Maximum number of files read at the same time, across all jobs.")
+	once       = flag.Bool("once", false, "Run each prefetching job once and exit.")
+
+	metricsAddress = flag.String("metrics-address", "", "Address (host:port) to serve Prometheus metrics on. Metrics are disabled if empty.")
+
+	logFormat = flag.String("log-format", log.FormatText, "Log output format. Allowed values are: 'text', 'json'.")
+
+	tracingExporter = flag.String("tracing-exporter", "", "OpenTelemetry trace exporter. Allowed values are: 'otlp' (configured with OTEL_EXPORTER_OTLP_* environment variables), 'stdout', 'file://<absolute path>'. Tracing is disabled if empty.")
+)
+
+func main() {
+	// Handle flags and initialize logging.
+
+	klog.InitFlags(nil)
+	if err := flag.Set("logtostderr", "true"); err != nil {
+		klog.Exitf("failed to set logtostderr flag: %v", err)
+	}
+	flag.Parse()
+
+	if err := log.SetFormat(*logFormat); err != nil {
+		klog.Exitf("failed to set log format: %v", err)
+	}
+
+	if *version {
+		fmt.Println("cvmfs-prefetcher for CVMFS CSI plugin version", cvmfsversion.FullVersion())
+		os.Exit(0)
+	}
+
+	// Initialize and run prefetcher.
+
+	log.Infof("cvmfs-prefetcher for CVMFS CSI plugin version %s", cvmfsversion.FullVersion())
+	log.Infof("Command line arguments %v", os.Args)
+
+	if *metricsAddress != "" {
+		if err := metrics.Serve(*metricsAddress); err != nil {
+			log.Fatalf("Failed to serve metrics: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "cvmfs-prefetcher", *tracingExporter)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
+	// Run blocking.
+
+	err = prefetcher.RunBlocking(&prefetcher.Opts{
+		ConfigFilepath:     *configFile,
+		CvmfsRoot:          *cvmfsRoot,
+		DefaultPeriod:      *period,
+		MaxConcurrentReads: *maxReads,
+		Once:               *once,
+	})
+	if err != nil {
+		log.Fatalf("Failed to run prefetcher: %v", err)
+	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Errorf("Failed to flush traces: %v", err)
+	}
+
+	os.Exit(0)
+}
@@ -17,12 +17,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
 	cvmfsversion "github.com/cvmfs-contrib/cvmfs-csi/internal/version"
 
 	"k8s.io/klog/v2"
@@ -31,6 +35,19 @@ import (
 var (
 	version  = flag.Bool("version", false, "Print singlemount-runner version and exit.")
 	endpoint = flag.String("endpoint", "unix:///var/lib/cvmfs.cern.ch/singlemount-runner.sock", "singlemount-runner endpoint.")
+
+	reconcilePeriod   = flag.Duration("reconcile-period", 0, "How often to check and reconcile singlemount CVMFS mounts. Reconciliation always runs at startup. '0' means only at startup.")
+	healthCheckPeriod = flag.Duration("health-check-period", 0, "How often to check singlemount CVMFS clients with cvmfs_talk and remount them if they are not running. '0' means never.")
+
+	configAllowedPaths    = flag.String("config-allowed-paths", "", "Comma-separated list of directories that path parameters (e.g. CVMFS_CACHE_BASE) in volume client config may point into, in addition to the mount's own directory.")
+	configExtraParameters = flag.String("config-extra-parameters", "", "Comma-separated list of CVMFS client config parameters accepted in volume client config in addition to the known ones.")
+	strictConfigParsing   = flag.Bool("strict-config-parsing", false, "Reject volume client config that contains anything else than CVMFS_<NAME>=<VALUE> assignments, instead of logging a warning. This will become the default in a future release.")
+
+	metricsAddress = flag.String("metrics-address", "", "Address (host:port) to serve Prometheus metrics on. Metrics are disabled if empty.")
+
+	logFormat = flag.String("log-format", log.FormatText, "Log output format. Allowed values are: 'text', 'json'.")
+
+	tracingExporter = flag.String("tracing-exporter", "", "OpenTelemetry trace exporter. Allowed values are: 'otlp' (configured with OTEL_EXPORTER_OTLP_* environment variables), 'stdout', 'file://<absolute path>'. Tracing is disabled if empty.")
 )
 
 func main() {
@@ -42,6 +59,10 @@ func main() {
 	}
 	flag.Parse()
 
+	if err := log.SetFormat(*logFormat); err != nil {
+		klog.Exitf("failed to set log format: %v", err)
+	}
+
 	if *version {
 		fmt.Println("singlemount-runner for CVMFS CSI plugin version", cvmfsversion.FullVersion())
 		os.Exit(0)
@@ -56,13 +77,49 @@ func main() {
 		log.Fatalf("Failed to create metadata directory in %s: %v", singlemount.SinglemountsDir, err)
 	}
 
+	if err := singlemount.RepairMetadata(); err != nil {
+		log.Fatalf("Failed to check metadata in %s: %v", singlemount.SinglemountsDir, err)
+	}
+
+	if *metricsAddress != "" {
+		if err := metrics.Serve(*metricsAddress); err != nil {
+			log.Fatalf("Failed to serve metrics: %v", err)
+		}
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), "singlemount-runner", *tracingExporter)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
 	opts := singlemount.Opts{
-		Endpoint: *endpoint,
+		Endpoint:          *endpoint,
+		ReconcilePeriod:   *reconcilePeriod,
+		HealthCheckPeriod: *healthCheckPeriod,
+
+		ConfigAllowedPaths:    splitList(*configAllowedPaths),
+		ConfigExtraParameters: splitList(*configExtraParameters),
+		StrictConfigParsing:   *strictConfigParsing,
 	}
 
 	if err := singlemount.RunBlocking(opts); err != nil {
 		log.Fatalf("Failed to run singlemount-runner: %v", err)
 	}
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Errorf("Failed to flush traces: %v", err)
+	}
+
 	os.Exit(0)
 }
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
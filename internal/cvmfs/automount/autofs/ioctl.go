@@ -0,0 +1,195 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package autofs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Control device exposing autofs miscellaneous device ioctls.
+// See Documentation/filesystems/autofs-mount-control.rst in the kernel tree.
+const controlDevicePath = "/dev/autofs"
+
+const (
+	devIoctlVersionMajor = 1
+	devIoctlVersionMinor = 0
+
+	// Size of struct autofs_dev_ioctl without the trailing path.
+	devIoctlHeaderSize = 24
+)
+
+// Command numbers of autofs device ioctls.
+const (
+	cmdVersion    = 0x71
+	cmdOpenMount  = 0x74
+	cmdCloseMount = 0x75
+	cmdReady      = 0x76
+	cmdFail       = 0x77
+	cmdSetPipeFd  = 0x78
+	cmdCatatonic  = 0x79
+	cmdTimeout    = 0x7a
+	cmdExpire     = 0x7c
+)
+
+// ioctlNumber computes _IOWR(AUTOFS_IOCTL, nr, struct autofs_dev_ioctl).
+func ioctlNumber(nr uintptr) uintptr {
+	const (
+		autofsIoctl = 0x93
+		iocRead     = 2
+		iocWrite    = 1
+	)
+
+	return (iocRead|iocWrite)<<30 | devIoctlHeaderSize<<16 | autofsIoctl<<8 | nr
+}
+
+// devIoctl holds struct autofs_dev_ioctl followed by the optional path.
+type devIoctl []byte
+
+func newDevIoctl(ioctlFd int, path string) devIoctl {
+	size := devIoctlHeaderSize
+	if path != "" {
+		size += len(path) + 1
+	}
+
+	// Round up to 8 bytes, like the struct itself is.
+	buf := make(devIoctl, (size+7)&^7)
+
+	binary.NativeEndian.PutUint32(buf[0:], devIoctlVersionMajor)
+	binary.NativeEndian.PutUint32(buf[4:], devIoctlVersionMinor)
+	binary.NativeEndian.PutUint32(buf[8:], uint32(size))
+	binary.NativeEndian.PutUint32(buf[12:], uint32(int32(ioctlFd)))
+	copy(buf[devIoctlHeaderSize:], path)
+
+	return buf
+}
+
+func (p devIoctl) ioctlFd() int { return int(int32(binary.NativeEndian.Uint32(p[12:]))) }
+
+// The union in struct autofs_dev_ioctl starts at offset 16.
+func (p devIoctl) setArg1(v uint32)  { binary.NativeEndian.PutUint32(p[16:], v) }
+func (p devIoctl) setArg2(v uint32)  { binary.NativeEndian.PutUint32(p[20:], v) }
+func (p devIoctl) setArg64(v uint64) { binary.NativeEndian.PutUint64(p[16:], v) }
+
+type control struct {
+	f *os.File
+}
+
+func openControl() (*control, error) {
+	f, err := os.OpenFile(controlDevicePath, os.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open autofs control device: %v", err)
+	}
+
+	c := &control{f: f}
+
+	// Make sure the kernel speaks the same version of the device ioctl protocol.
+	if err := c.ioctl(cmdVersion, newDevIoctl(-1, "")); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to get autofs device ioctl version: %v", err)
+	}
+
+	return c, nil
+}
+
+func (c *control) Close() error {
+	return c.f.Close()
+}
+
+func (c *control) ioctl(nr uintptr, param devIoctl) error {
+	conn, err := c.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(
+			syscall.SYS_IOCTL, fd, ioctlNumber(nr), uintptr(unsafe.Pointer(&param[0])))
+	})
+	if err != nil {
+		return err
+	}
+
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// openMount returns an ioctl file descriptor for autofs mount
+// at path with device number devid.
+func (c *control) openMount(path string, devid uint32) (int, error) {
+	param := newDevIoctl(-1, path)
+	param.setArg1(devid)
+
+	if err := c.ioctl(cmdOpenMount, param); err != nil {
+		return -1, err
+	}
+
+	return param.ioctlFd(), nil
+}
+
+func (c *control) closeMount(ioctlFd int) error {
+	return c.ioctl(cmdCloseMount, newDevIoctl(ioctlFd, ""))
+}
+
+func (c *control) ready(ioctlFd int, token uint32) error {
+	param := newDevIoctl(ioctlFd, "")
+	param.setArg1(token)
+
+	return c.ioctl(cmdReady, param)
+}
+
+func (c *control) fail(ioctlFd int, token uint32, status syscall.Errno) error {
+	param := newDevIoctl(ioctlFd, "")
+	param.setArg1(token)
+	param.setArg2(uint32(-int32(status)))
+
+	return c.ioctl(cmdFail, param)
+}
+
+func (c *control) setPipeFd(ioctlFd, pipeFd int) error {
+	param := newDevIoctl(ioctlFd, "")
+	param.setArg1(uint32(int32(pipeFd)))
+
+	return c.ioctl(cmdSetPipeFd, param)
+}
+
+func (c *control) catatonic(ioctlFd int) error {
+	return c.ioctl(cmdCatatonic, newDevIoctl(ioctlFd, ""))
+}
+
+func (c *control) setTimeout(ioctlFd int, seconds uint64) error {
+	param := newDevIoctl(ioctlFd, "")
+	param.setArg64(seconds)
+
+	return c.ioctl(cmdTimeout, param)
+}
+
+func (c *control) expire(ioctlFd int) error {
+	param := newDevIoctl(ioctlFd, "")
+	// how = 0: expire a single idle mount.
+	param.setArg1(0)
+
+	return c.ioctl(cmdExpire, param)
+}
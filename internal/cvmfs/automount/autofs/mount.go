@@ -0,0 +1,237 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package autofs implements the daemon side of the Linux autofs kernel
+// protocol (version 5) for indirect mounts, i.e. what the automount
+// daemon from autofs-tools does, without needing map files.
+package autofs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// Type of the autofs filesystem as reported by statfs(2).
+	autofsSuperMagic = 0x187
+
+	// Source shown in the mount table for autofs mounts made by this package.
+	mountSource = "cvmfs-csi"
+)
+
+// Mount is a connection to an indirect autofs mount. Requests are read
+// with ReadPacket and answered with Ready or Fail.
+type Mount struct {
+	Path string
+
+	ctl     *control
+	ioctlFd int
+	pipe    *os.File
+}
+
+// IsAutofs checks whether path is the root of an autofs mount.
+func IsAutofs(path string) (bool, error) {
+	statfs := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &statfs); err != nil {
+		return false, err
+	}
+
+	return statfs.Type == autofsSuperMagic, nil
+}
+
+// Open makes path an indirect autofs mount served by the calling process.
+// If there already is an autofs mount in path, e.g. one left behind by
+// a previous run, Open reconnects to it instead, and the returned
+// reconnected value is set to true. Mounts inside path are kept intact
+// in that case.
+//
+// The caller's process group is the one the kernel will not send
+// requests for, so any mounts done in response to packets need to be
+// made from within the same process group.
+func Open(path string, timeout time.Duration) (m *Mount, reconnected bool, err error) {
+	if err = os.MkdirAll(path, 0o755); err != nil {
+		return nil, false, err
+	}
+
+	isAutofs, err := IsAutofs(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctl, err := openControl()
+	if err != nil {
+		return nil, false, err
+	}
+
+	pipeR, pipeW, err := os.Pipe()
+	if err != nil {
+		ctl.Close()
+		return nil, false, err
+	}
+
+	// The kernel holds its own reference to the write end of the pipe,
+	// we don't need ours once the mount is set up.
+	defer pipeW.Close()
+
+	m = &Mount{
+		Path:    path,
+		ctl:     ctl,
+		ioctlFd: -1,
+		pipe:    pipeR,
+	}
+
+	defer func() {
+		if err != nil {
+			m.Close()
+		}
+	}()
+
+	if !isAutofs {
+		opts := fmt.Sprintf("fd=%d,pgrp=%d,minproto=%d,maxproto=%d,indirect",
+			pipeW.Fd(), syscall.Getpgrp(), ProtocolVersion, ProtocolVersion)
+
+		if err = syscall.Mount(mountSource, path, "autofs", 0, opts); err != nil {
+			return nil, false, fmt.Errorf("failed to mount autofs in %s: %v", path, err)
+		}
+	}
+
+	devid, err := deviceID(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if m.ioctlFd, err = ctl.openMount(path, devid); err != nil {
+		return nil, false, fmt.Errorf("failed to open autofs mount %s: %v", path, err)
+	}
+
+	if isAutofs {
+		// Take over the mount from whoever was serving it before. The kernel
+		// accepts a new pipe only for a catatonic mount, and only from a process
+		// in the same PID namespace as the process that has mounted it originally.
+
+		if err = ctl.catatonic(m.ioctlFd); err != nil {
+			return nil, false, fmt.Errorf("failed to make autofs mount %s catatonic: %v", path, err)
+		}
+
+		if err = ctl.setPipeFd(m.ioctlFd, int(pipeW.Fd())); err != nil {
+			return nil, false, fmt.Errorf("failed to reconnect to autofs mount %s: %v", path, err)
+		}
+	}
+
+	if err = m.SetTimeout(timeout); err != nil {
+		return nil, false, err
+	}
+
+	return m, isAutofs, nil
+}
+
+// deviceID returns the device number of the filesystem mounted
+// in path, encoded the same way the kernel does in new_encode_dev().
+func deviceID(path string) (uint32, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+
+	major := uint32((st.Dev>>8)&0xfff | (st.Dev>>32)&^0xfff)
+	minor := uint32(st.Dev&0xff | (st.Dev>>12)&^0xff)
+
+	return (minor & 0xff) | (major << 8) | ((minor &^ 0xff) << 12), nil
+}
+
+// Close disconnects from the autofs mount, leaving it mounted. Until
+// someone reconnects to it, lookups of missing entries fail with ENOENT.
+func (m *Mount) Close() error {
+	var errs []error
+
+	if m.ioctlFd != -1 {
+		errs = append(errs, m.ctl.closeMount(m.ioctlFd))
+		m.ioctlFd = -1
+	}
+
+	errs = append(errs, m.pipe.Close(), m.ctl.Close())
+
+	return errors.Join(errs...)
+}
+
+// Catatonic makes the autofs mount stop sending requests. Pending
+// and future lookups of missing entries fail with ENOENT, until
+// someone reconnects to the mount with Open.
+func (m *Mount) Catatonic() error {
+	return m.ctl.catatonic(m.ioctlFd)
+}
+
+// Unmount makes the autofs mount catatonic and unmounts it.
+// Mounts inside it need to be unmounted beforehand.
+func (m *Mount) Unmount() error {
+	if err := m.Catatonic(); err != nil {
+		return err
+	}
+
+	// The ioctl fd is open in the mount's root, and would keep it busy.
+	if err := m.ctl.closeMount(m.ioctlFd); err != nil {
+		return err
+	}
+	m.ioctlFd = -1
+
+	return syscall.Unmount(m.Path, 0)
+}
+
+// ReadPacket blocks until the next request from the kernel arrives.
+// It returns os.ErrClosed once the Mount is closed.
+func (m *Mount) ReadPacket() (*Packet, error) {
+	return ReadPacket(m.pipe)
+}
+
+// Ready tells the kernel the request identified by token was handled successfully.
+func (m *Mount) Ready(token uint32) error {
+	return m.ctl.ready(m.ioctlFd, token)
+}
+
+// Fail tells the kernel the request identified by token has failed.
+// The process waiting on the request receives status as the error.
+func (m *Mount) Fail(token uint32, status syscall.Errno) error {
+	return m.ctl.fail(m.ioctlFd, token, status)
+}
+
+// SetTimeout sets the idle time after which mounts become candidates
+// for expiry. Zero disables expiry.
+func (m *Mount) SetTimeout(timeout time.Duration) error {
+	if err := m.ctl.setTimeout(m.ioctlFd, uint64(timeout/time.Second)); err != nil {
+		return fmt.Errorf("failed to set autofs timeout: %v", err)
+	}
+
+	return nil
+}
+
+// Expire asks the kernel to expire a single idle mount. The kernel
+// then sends a PacketExpireIndirect packet and the call blocks until
+// it is answered, so packets must be read concurrently. Returns false
+// when there was nothing to expire.
+func (m *Mount) Expire() (bool, error) {
+	err := m.ctl.expire(m.ioctlFd)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, syscall.EAGAIN):
+		return false, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package autofs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// Version of the autofs kernel protocol spoken by this package.
+const ProtocolVersion = 5
+
+// PacketType identifies the kind of request sent by the kernel.
+type PacketType int32
+
+const (
+	// Sent when a lookup of a non-existent directory in an indirect
+	// autofs mount is made. The daemon is expected to mount it.
+	PacketMissingIndirect PacketType = 3
+
+	// Sent as a result of an expire ioctl, when a mount in an indirect
+	// autofs mount has been idle for longer than the configured timeout.
+	// The daemon is expected to unmount it.
+	PacketExpireIndirect PacketType = 4
+
+	// Direct mounts are not used by this package, but we still
+	// want to be able to tell them apart in logs.
+	PacketMissingDirect PacketType = 5
+	PacketExpireDirect  PacketType = 6
+)
+
+func (t PacketType) String() string {
+	switch t {
+	case PacketMissingIndirect:
+		return "missing_indirect"
+	case PacketExpireIndirect:
+		return "expire_indirect"
+	case PacketMissingDirect:
+		return "missing_direct"
+	case PacketExpireDirect:
+		return "expire_direct"
+	default:
+		return fmt.Sprintf("unknown(%d)", int32(t))
+	}
+}
+
+// v5Packet mirrors struct autofs_v5_packet from <linux/auto_fs.h>.
+// It is used only to compute the size of the packet as written
+// by the kernel, which includes trailing padding on 64-bit
+// architectures.
+type v5Packet struct {
+	ProtoVersion   int32
+	Type           int32
+	WaitQueueToken uint32
+	Dev            uint32
+	Ino            uint64
+	UID            uint32
+	GID            uint32
+	PID            uint32
+	TGID           uint32
+	Len            uint32
+	Name           [256]byte
+}
+
+const packetSize = int(unsafe.Sizeof(v5Packet{}))
+
+// Packet is a request received from the kernel.
+type Packet struct {
+	Type PacketType
+
+	// Token identifying the request. It needs to be passed back
+	// to the kernel when replying with Mount.Ready or Mount.Fail.
+	Token uint32
+
+	// Name of the directory entry in the autofs mount the request is for.
+	Name string
+
+	// Process that triggered the request.
+	PID uint32
+	UID uint32
+	GID uint32
+}
+
+// ReadPacket reads a single packet from the autofs pipe.
+func ReadPacket(r io.Reader) (*Packet, error) {
+	buf := make([]byte, packetSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	var raw v5Packet
+	if err := binary.Read(bytes.NewReader(buf[:binary.Size(raw)]), binary.NativeEndian, &raw); err != nil {
+		return nil, err
+	}
+
+	if raw.ProtoVersion != ProtocolVersion {
+		return nil, fmt.Errorf("unsupported autofs protocol version %d", raw.ProtoVersion)
+	}
+
+	nameLen := int(raw.Len)
+	if nameLen > len(raw.Name) {
+		nameLen = len(raw.Name)
+	}
+
+	return &Packet{
+		Type:  PacketType(raw.Type),
+		Token: raw.WaitQueueToken,
+		Name:  string(raw.Name[:nameLen]),
+		PID:   raw.PID,
+		UID:   raw.UID,
+		GID:   raw.GID,
+	}, nil
+}
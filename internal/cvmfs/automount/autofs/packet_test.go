@@ -0,0 +1,169 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package autofs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// encodePacket encodes raw the way the kernel writes it to the pipe,
+// including trailing padding.
+func encodePacket(t *testing.T, raw *v5Packet) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.NativeEndian, raw); err != nil {
+		t.Fatal(err)
+	}
+
+	b := buf.Bytes()
+	return append(b, make([]byte, packetSize-len(b))...)
+}
+
+func newV5Packet(typ PacketType, name string, nameLen int) *v5Packet {
+	raw := &v5Packet{
+		ProtoVersion:   ProtocolVersion,
+		Type:           int32(typ),
+		WaitQueueToken: 42,
+		Dev:            1,
+		Ino:            2,
+		UID:            1000,
+		GID:            1001,
+		PID:            1234,
+		TGID:           1234,
+		Len:            uint32(nameLen),
+	}
+	copy(raw.Name[:], name)
+
+	return raw
+}
+
+func TestReadPacket(t *testing.T) {
+	longName := strings.Repeat("a", 256)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *Packet
+		wantErr error
+	}{
+		{
+			name: "missing indirect",
+			data: encodePacket(t, newV5Packet(PacketMissingIndirect, "atlas.cern.ch", len("atlas.cern.ch"))),
+			want: &Packet{Type: PacketMissingIndirect, Token: 42, Name: "atlas.cern.ch", PID: 1234, UID: 1000, GID: 1001},
+		},
+		{
+			name: "expire indirect",
+			data: encodePacket(t, newV5Packet(PacketExpireIndirect, "cms.cern.ch", len("cms.cern.ch"))),
+			want: &Packet{Type: PacketExpireIndirect, Token: 42, Name: "cms.cern.ch", PID: 1234, UID: 1000, GID: 1001},
+		},
+		{
+			name: "name is cut at len",
+			data: encodePacket(t, newV5Packet(PacketMissingIndirect, "atlas.cern.ch", len("atlas"))),
+			want: &Packet{Type: PacketMissingIndirect, Token: 42, Name: "atlas", PID: 1234, UID: 1000, GID: 1001},
+		},
+		{
+			name: "len past the name buffer",
+			data: encodePacket(t, newV5Packet(PacketMissingIndirect, longName, 1000)),
+			want: &Packet{Type: PacketMissingIndirect, Token: 42, Name: longName, PID: 1234, UID: 1000, GID: 1001},
+		},
+		{
+			name: "unknown type",
+			data: encodePacket(t, newV5Packet(PacketType(99), "x", 1)),
+			want: &Packet{Type: PacketType(99), Token: 42, Name: "x", PID: 1234, UID: 1000, GID: 1001},
+		},
+		{
+			name: "unsupported protocol version",
+			data: func() []byte {
+				raw := newV5Packet(PacketMissingIndirect, "x", 1)
+				raw.ProtoVersion = 4
+				return encodePacket(t, raw)
+			}(),
+			wantErr: errors.New("unsupported autofs protocol version 4"),
+		},
+		{name: "empty pipe", data: nil, wantErr: io.EOF},
+		{
+			name:    "short packet",
+			data:    encodePacket(t, newV5Packet(PacketMissingIndirect, "x", 1))[:packetSize-1],
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPacket(bytes.NewReader(tt.data))
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPacketConsecutive(t *testing.T) {
+	var data []byte
+	data = append(data, encodePacket(t, newV5Packet(PacketMissingIndirect, "atlas.cern.ch", len("atlas.cern.ch")))...)
+	data = append(data, encodePacket(t, newV5Packet(PacketExpireIndirect, "cms.cern.ch", len("cms.cern.ch")))...)
+
+	r := bytes.NewReader(data)
+
+	for _, want := range []string{"atlas.cern.ch", "cms.cern.ch"} {
+		p, err := ReadPacket(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Name != want {
+			t.Errorf("got name %q, want %q", p.Name, want)
+		}
+	}
+
+	if _, err := ReadPacket(r); err != io.EOF {
+		t.Errorf("got error %v, want %v", err, io.EOF)
+	}
+}
+
+func TestPacketTypeString(t *testing.T) {
+	tests := []struct {
+		typ  PacketType
+		want string
+	}{
+		{typ: PacketMissingIndirect, want: "missing_indirect"},
+		{typ: PacketExpireIndirect, want: "expire_indirect"},
+		{typ: PacketMissingDirect, want: "missing_direct"},
+		{typ: PacketExpireDirect, want: "expire_direct"},
+		{typ: PacketType(99), want: "unknown(99)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.typ.String(); got != tt.want {
+			t.Errorf("PacketType(%d).String() = %q, want %q", int32(tt.typ), got, tt.want)
+		}
+	}
+}
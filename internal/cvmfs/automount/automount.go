@@ -24,17 +24,23 @@ import (
 	goexec "os/exec"
 	"os/signal"
 	"path"
+	"strings"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/env"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+
+	"github.com/moby/sys/mountinfo"
 )
 
-const (
-	AutofsCvmfsRoot = "/cvmfs"
+// autofs-managed CVMFS root mountpoint. It is a variable
+// only so that tests can point it to a temporary directory.
+var AutofsCvmfsRoot = "/cvmfs"
 
+const (
 	// Default Location of alien cache if not otherwise specified in
 	// default.local cvmfs configuration.
 	DefaultAlienCachePath = "/cvmfs-aliencache"
@@ -46,13 +52,36 @@ const (
 
 type Opts struct {
 	// Number of seconds of idle time after which an autofs-managed CVMFS
-	// mount will be unmounted. Zero means never unmount.
+	// mount will be unmounted. Zero means never unmount. Negative value
+	// leaves the automount daemon's default, and is not supported by
+	// AutomounterNative.
 	UnmountTimeoutSeconds int
 
+	// Path to a JSON file with per-repository automount settings,
+	// see Config. Optional.
+	ConfigFilepath string
+
+	// Directory shared with the previous and the next automount-runner
+	// instance, used to coordinate handover of /cvmfs during upgrades.
+	// Supported only by AutomounterNative. Handover is disabled if empty.
+	HandoverDir string
+
+	// How long to keep CVMFS clients running after /cvmfs was handed over
+	// to the next instance, waiting for it to replace their mounts.
+	DrainTimeout time.Duration
+
+	// Automounter serving /cvmfs. One of AutomounterExternal, AutomounterNative.
+	Automounter string
+
 	// HasAlienCache determines whether we're using alien cache.
 	// If so, we need to prepare the alien cache volume first (e.g.
 	// make sure it has correct permissions).
 	HasAlienCache bool
+
+	// What to do with the local cache left behind by previous nodeplugin
+	// Pod runs. One of CachePreservationWipe, CachePreservationVerify,
+	// CachePreservationKeep.
+	CachePreservation string
 }
 
 func cvmfsVersion() (string, error) {
@@ -157,6 +186,48 @@ func readEffectiveDefaultCvmfsConfig() (map[string]string, error) {
 	return config, nil
 }
 
+func localCacheDir(cvmfsConfig map[string]string) string {
+	if cacheDir := cvmfsConfig["CVMFS_CACHE_BASE"]; cacheDir != "" {
+		return cacheDir
+	}
+
+	return DefaultLocalCachePath
+}
+
+// LocalCacheDir returns location of the local cache
+// set in the default CVMFS configuration.
+func LocalCacheDir() (string, error) {
+	cvmfsConfig, err := readEffectiveDefaultCvmfsConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to read CVMFS config: %v", err)
+	}
+
+	return localCacheDir(cvmfsConfig), nil
+}
+
+// MountedRepositories lists CVMFS mounts in /cvmfs that the kernel knows
+// about. We do that by listing mounts in /proc/self/mountinfo and filtering
+// those where the device is "fuse" and the mountpoint is rooted in /cvmfs.
+func MountedRepositories() ([]string, error) {
+	mountPathPrefix := AutofsCvmfsRoot + "/"
+
+	cvmfsMountInfos, err := mountinfo.GetMounts(func(info *mountinfo.Info) (skip, stop bool) {
+		return info.FSType != "fuse" || !strings.HasPrefix(info.Mountpoint, mountPathPrefix),
+			false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := make([]string, len(cvmfsMountInfos))
+
+	for i := range cvmfsMountInfos {
+		repositories[i] = cvmfsMountInfos[i].Mountpoint[len(mountPathPrefix):]
+	}
+
+	return repositories, nil
+}
+
 func setupCvmfs(o *Opts) error {
 	cvmfsConfig, err := readEffectiveDefaultCvmfsConfig()
 	if err != nil {
@@ -174,20 +245,12 @@ func setupCvmfs(o *Opts) error {
 		}
 	}
 
-	cacheDir := cvmfsConfig["CVMFS_CACHE_BASE"]
-	if cacheDir == "" {
-		cacheDir = DefaultLocalCachePath
-	}
-
-	// Clean up local cache. It may be dirty after previous nodeplugin Pod runs.
-	log.Debugf("Cleaning up local cache directory %s...", cacheDir)
+	cacheDir := localCacheDir(cvmfsConfig)
 
-	if err := removeDirContents(cacheDir); err != nil {
-		return fmt.Errorf("failed to clean up local cache directory %s: %v", cacheDir, err)
+	if err := prepareLocalCache(cacheDir, o.CachePreservation, o.HandoverDir != ""); err != nil {
+		return err
 	}
 
-	log.Debugf("Finished cleaning up local cache directory %s", cacheDir)
-
 	// Set up configuration required for autofs with CVMFS to work properly.
 
 	if _, err := exec.CombinedOutput(goexec.Command("cvmfs_config", "setup", "nocfgmod", "nostart", "noautofs")); err != nil {
@@ -217,11 +280,29 @@ browse_mode = no
 		return err
 	}
 
+	mapPath := autofsCvmfsMapPath
+
+	if o.ConfigFilepath != "" {
+		config, err := LoadConfig(o.ConfigFilepath)
+		if err != nil {
+			return err
+		}
+
+		warnUnsupportedConfig(config)
+
+		if err = writeAutofsProgramMap(config); err != nil {
+			return err
+		}
+
+		mapPath = autofsProgramMapPath
+	}
+
 	if err := writeFmtFile(
 		"/etc/auto.master",
 		`# Generated by automount-runner for CVMFS CSI.
-/cvmfs /etc/auto.cvmfs
+/cvmfs %s
 `,
+		mapPath,
 	); err != nil {
 		return err
 	}
@@ -241,6 +322,11 @@ func Init(o *Opts) error {
 		return err
 	}
 
+	if o.Automounter == AutomounterNative {
+		// The native automounter doesn't need any autofs configuration files.
+		return nil
+	}
+
 	if err := setupAutofs(o); err != nil {
 		return err
 	}
@@ -248,7 +334,37 @@ func Init(o *Opts) error {
 	return nil
 }
 
-func RunBlocking() error {
+func warnUnsupportedConfig(config *Config) {
+	if timeouts := config.UnmountTimeouts(); len(timeouts) > 0 {
+		log.Warningf("Per-repository unmount timeouts are supported only with %s automounter, ignoring %v",
+			AutomounterNative, timeouts)
+	}
+}
+
+// reloadConfig re-reads automount config and regenerates the autofs map.
+// On error, the previous map is kept in place.
+func reloadConfig(o *Opts) {
+	if o.ConfigFilepath == "" {
+		return
+	}
+
+	config, err := LoadConfig(o.ConfigFilepath)
+	if err != nil {
+		log.Errorf("Failed to reload automount config, keeping the previous one: %v", err)
+		return
+	}
+
+	warnUnsupportedConfig(config)
+
+	if err = writeAutofsProgramMap(config); err != nil {
+		log.Errorf("Failed to reload automount config: %v", err)
+		return
+	}
+
+	log.Infof("Reloaded automount config from %s", o.ConfigFilepath)
+}
+
+func RunBlocking(o *Opts) error {
 	args := []string{
 		"--foreground",
 	}
@@ -298,6 +414,7 @@ func RunBlocking() error {
 	}()
 
 	// Catch SIGTERM and SIGKILL and forward it to the automount process.
+	// SIGHUP reloads automount config.
 
 	autofsTryCleanAtExit := env.GetAutofsTryCleanAtExit()
 
@@ -313,6 +430,13 @@ func RunBlocking() error {
 				break
 			}
 
+			if sig == syscall.SIGHUP {
+				// The map is read by automount on each lookup,
+				// there's no need to notify the daemon.
+				reloadConfig(o)
+				continue
+			}
+
 			if !autofsTryCleanAtExit && sig == syscall.SIGTERM {
 				// automount daemon unmounts the autofs root in /cvmfs upon
 				// receiving SIGTERM. This makes it impossible to reconnect
@@ -355,6 +479,7 @@ func RunBlocking() error {
 	}
 
 	signal.Notify(sigCh, shutdownSignals...)
+	signal.Notify(sigCh, syscall.SIGHUP)
 
 	// Start automount daemon.
 
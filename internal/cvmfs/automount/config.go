@@ -0,0 +1,233 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package automount
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Config holds per-repository automount settings.
+// It's read from a JSON file, e.g.:
+//
+//	{
+//	  "repositories": {
+//	    "sft.cern.ch": {
+//	      "unmountTimeoutSeconds": 0
+//	    },
+//	    "atlas.cern.ch": {
+//	      "mountOptions": ["nofiles"]
+//	    }
+//	  },
+//	  "blockedRepositories": ["*.example.org"]
+//	}
+type Config struct {
+	// Settings for individual repositories, keyed by repository name.
+	Repositories map[string]RepositoryConfig `json:"repositories,omitempty"`
+
+	// Repositories that must never be automounted. Patterns are matched
+	// against the name of the accessed directory in /cvmfs as in path.Match,
+	// except that character classes are not allowed.
+	BlockedRepositories []string `json:"blockedRepositories,omitempty"`
+}
+
+type RepositoryConfig struct {
+	// Number of seconds of idle time after which the repository will be
+	// unmounted. Zero means never unmount. Defaults to UnmountTimeoutSeconds
+	// set in Opts. Supported only by AutomounterNative.
+	UnmountTimeoutSeconds *int `json:"unmountTimeoutSeconds,omitempty"`
+
+	// Additional options passed to mount.cvmfs when mounting the repository.
+	MountOptions []string `json:"mountOptions,omitempty"`
+}
+
+var (
+	// Both repository names and mount options end up in the generated
+	// autofs program map, so we stay on the safe side with the characters
+	// we allow.
+	repositoryNameRegexp    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	repositoryPatternRegexp = regexp.MustCompile(`^[A-Za-z0-9._*?-]+$`)
+	mountOptionRegexp       = regexp.MustCompile(`^[A-Za-z0-9._:/=@+-]+$`)
+)
+
+// LoadConfig reads automount config from filepath.
+// Empty filepath yields empty config.
+func LoadConfig(filepath string) (*Config, error) {
+	if filepath == "" {
+		return &Config{}, nil
+	}
+
+	configJSON, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err = json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse automount config %s: %v", filepath, err)
+	}
+
+	if err = config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid automount config %s: %v", filepath, err)
+	}
+
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	for repo, repoConfig := range c.Repositories {
+		if !repositoryNameRegexp.MatchString(repo) {
+			return fmt.Errorf("invalid repository name %q", repo)
+		}
+
+		if t := repoConfig.UnmountTimeoutSeconds; t != nil && *t < 0 {
+			return fmt.Errorf("repository %s: unmountTimeoutSeconds must not be negative", repo)
+		}
+
+		for _, opt := range repoConfig.MountOptions {
+			if !mountOptionRegexp.MatchString(opt) {
+				return fmt.Errorf("repository %s: invalid mount option %q", repo, opt)
+			}
+		}
+	}
+
+	for _, pattern := range c.BlockedRepositories {
+		if !repositoryPatternRegexp.MatchString(pattern) {
+			return fmt.Errorf("invalid blocked repository pattern %q", pattern)
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid blocked repository pattern %q: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// IsBlocked checks whether repo must not be automounted.
+func (c *Config) IsBlocked(repo string) bool {
+	for _, pattern := range c.BlockedRepositories {
+		if matched, _ := path.Match(pattern, repo); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MountOptions returns additional mount options for repo.
+func (c *Config) MountOptions(repo string) []string {
+	return c.Repositories[repo].MountOptions
+}
+
+// UnmountTimeouts returns per-repository unmount timeouts in seconds,
+// for repositories that have one set.
+func (c *Config) UnmountTimeouts() map[string]int {
+	timeouts := make(map[string]int)
+
+	for repo, repoConfig := range c.Repositories {
+		if t := repoConfig.UnmountTimeoutSeconds; t != nil {
+			timeouts[repo] = *t
+		}
+	}
+
+	return timeouts
+}
+
+// Paths to the generated autofs program map, and to the map from the cvmfs
+// package it wraps. They are variables only so that tests can point them
+// to a temporary directory.
+var (
+	autofsProgramMapPath = "/etc/auto.cvmfs-csi"
+	autofsCvmfsMapPath   = "/etc/auto.cvmfs"
+)
+
+// writeAutofsProgramMap generates autofs program map for the external
+// automount daemon. The map wraps /etc/auto.cvmfs, refusing blocked
+// repositories and adding configured mount options to its entries.
+// automount runs the program on each lookup, so changes take effect
+// without having to signal the daemon.
+func writeAutofsProgramMap(c *Config) error {
+	var sb strings.Builder
+
+	sb.WriteString(`#!/bin/sh
+# Generated by automount-runner for CVMFS CSI.
+
+key="$1"
+`)
+
+	if len(c.BlockedRepositories) > 0 {
+		fmt.Fprintf(&sb, `
+case "$key" in
+  %s)
+    exit 1
+    ;;
+esac
+`, strings.Join(c.BlockedRepositories, "|"))
+	}
+
+	fmt.Fprintf(&sb, `
+entry=$(%s "$key") || exit 1
+[ -n "$entry" ] || exit 1
+
+extra=
+case "$key" in
+`, autofsCvmfsMapPath)
+
+	for _, repo := range slices.Sorted(maps.Keys(c.Repositories)) {
+		repoConfig := c.Repositories[repo]
+		if len(repoConfig.MountOptions) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, `  %s)
+    extra="%s"
+    ;;
+`, repo, strings.Join(repoConfig.MountOptions, ","))
+	}
+
+	sb.WriteString(`esac
+
+if [ -z "$extra" ]; then
+  echo "$entry"
+else
+  # Map entries are in the form "-<options> :<location>".
+  echo "${entry%% *},${extra} ${entry#* }"
+fi
+`)
+
+	// Replace the map atomically, as automount may be running it right now.
+
+	tmpPath := autofsProgramMapPath + ".tmp"
+
+	if err := os.WriteFile(tmpPath, []byte(sb.String()), 0o755); err != nil {
+		return fmt.Errorf("failed to write autofs map to %s: %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, autofsProgramMapPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write autofs map to %s: %v", autofsProgramMapPath, err)
+	}
+
+	return nil
+}
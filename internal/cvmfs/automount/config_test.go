@@ -0,0 +1,191 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package automount
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "empty", config: `{}`},
+		{
+			name: "example",
+			config: `{
+  "repositories": {
+    "sft.cern.ch": {"unmountTimeoutSeconds": 0},
+    "atlas.cern.ch": {"mountOptions": ["nofiles", "foreground"]}
+  },
+  "blockedRepositories": ["*.example.org", "lhcb?.cern.ch"]
+}`,
+		},
+		{name: "mount option with value", config: `{"repositories": {"atlas.cern.ch": {"mountOptions": ["uid=1000"]}}}`},
+		{name: "invalid JSON", config: `{"repositories": []}`, wantErr: true},
+		{name: "wrong field type", config: `{"repositories": {"atlas.cern.ch": {"unmountTimeoutSeconds": "10"}}}`, wantErr: true},
+		{name: "invalid repository name", config: `{"repositories": {"../etc": {}}}`, wantErr: true},
+		{name: "repository name with space", config: `{"repositories": {"atlas cern.ch": {}}}`, wantErr: true},
+		{name: "negative timeout", config: `{"repositories": {"atlas.cern.ch": {"unmountTimeoutSeconds": -1}}}`, wantErr: true},
+		{name: "mount option with comma", config: `{"repositories": {"atlas.cern.ch": {"mountOptions": ["ro,suid"]}}}`, wantErr: true},
+		{name: "mount option with shell", config: `{"repositories": {"atlas.cern.ch": {"mountOptions": ["$(id)"]}}}`, wantErr: true},
+		{name: "blocked pattern with character class", config: `{"blockedRepositories": ["[a-z].cern.ch"]}`, wantErr: true},
+		{name: "blocked pattern with pipe", config: `{"blockedRepositories": ["a|b"]}`, wantErr: true},
+		{name: "empty blocked pattern", config: `{"blockedRepositories": [""]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(p, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadConfig(p)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigNoFile(t *testing.T) {
+	c, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.Repositories) != 0 || len(c.BlockedRepositories) != 0 {
+		t.Errorf("expected empty config, got %+v", c)
+	}
+
+	if _, err = LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestConfigIsBlocked(t *testing.T) {
+	c := &Config{BlockedRepositories: []string{"*.example.org", "lhcb?.cern.ch"}}
+
+	tests := []struct {
+		repo string
+		want bool
+	}{
+		{repo: "test.example.org", want: true},
+		{repo: "lhcb1.cern.ch", want: true},
+		{repo: "lhcb.cern.ch", want: false},
+		{repo: "example.org", want: false},
+		{repo: "atlas.cern.ch", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := c.IsBlocked(tt.repo); got != tt.want {
+			t.Errorf("IsBlocked(%q) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestConfigRepositorySettings(t *testing.T) {
+	zero, ten := 0, 10
+	c := &Config{
+		Repositories: map[string]RepositoryConfig{
+			"sft.cern.ch":   {UnmountTimeoutSeconds: &zero},
+			"cms.cern.ch":   {UnmountTimeoutSeconds: &ten, MountOptions: []string{"nofiles"}},
+			"atlas.cern.ch": {MountOptions: []string{"nofiles", "foreground"}},
+		},
+	}
+
+	if got, want := c.MountOptions("atlas.cern.ch"), []string{"nofiles", "foreground"}; !slices.Equal(got, want) {
+		t.Errorf("MountOptions(atlas.cern.ch) = %v, want %v", got, want)
+	}
+
+	if got := c.MountOptions("lhcb.cern.ch"); got != nil {
+		t.Errorf("MountOptions(lhcb.cern.ch) = %v, want nil", got)
+	}
+
+	if got, want := c.UnmountTimeouts(), map[string]int{"sft.cern.ch": 0, "cms.cern.ch": 10}; !maps.Equal(got, want) {
+		t.Errorf("UnmountTimeouts() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteAutofsProgramMap(t *testing.T) {
+	dir := t.TempDir()
+
+	origProgramMapPath, origCvmfsMapPath := autofsProgramMapPath, autofsCvmfsMapPath
+	autofsProgramMapPath = filepath.Join(dir, "auto.cvmfs-csi")
+	autofsCvmfsMapPath = filepath.Join(dir, "auto.cvmfs")
+	t.Cleanup(func() { autofsProgramMapPath, autofsCvmfsMapPath = origProgramMapPath, origCvmfsMapPath })
+
+	// Stands in for the map from the cvmfs package.
+	cvmfsMap := "#!/bin/sh\necho \"-fstype=cvmfs :$1\"\n"
+	if err := os.WriteFile(autofsCvmfsMapPath, []byte(cvmfsMap), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		Repositories: map[string]RepositoryConfig{
+			"atlas.cern.ch": {MountOptions: []string{"nofiles", "foreground"}},
+			"sft.cern.ch":   {UnmountTimeoutSeconds: new(int)},
+		},
+		BlockedRepositories: []string{"*.example.org", "lhcb?.cern.ch"},
+	}
+
+	if err := writeAutofsProgramMap(config); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key       string
+		wantEntry string
+		wantErr   bool
+	}{
+		{key: "atlas.cern.ch", wantEntry: "-fstype=cvmfs,nofiles,foreground :atlas.cern.ch"},
+		{key: "sft.cern.ch", wantEntry: "-fstype=cvmfs :sft.cern.ch"},
+		{key: "cms.cern.ch", wantEntry: "-fstype=cvmfs :cms.cern.ch"},
+		{key: "repo.example.org", wantErr: true},
+		{key: "lhcb1.cern.ch", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			out, err := exec.Command(autofsProgramMapPath, tt.key).Output()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("blocked repository got map entry %q", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.TrimSpace(string(out)); got != tt.wantEntry {
+				t.Errorf("got map entry %q, want %q", got, tt.wantEntry)
+			}
+		})
+	}
+}
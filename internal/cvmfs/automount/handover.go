@@ -0,0 +1,456 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package automount
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
+
+	"github.com/moby/sys/mountinfo"
+)
+
+// Upgrades of the nodeplugin Pod with the native automounter are
+// coordinated between the old and the new automount-runner instance
+// through a UNIX domain socket in Opts.HandoverDir, which must be shared
+// between the two:
+//
+//  1. The new instance finds an autofs mount in /cvmfs, and asks the old
+//     instance to hand it over.
+//  2. The old instance stops serving the autofs mount (makes it catatonic),
+//     waits for mounts in progress, and replies with the list of its mounts.
+//     Its CVMFS clients keep serving these mounts.
+//  3. The new instance takes over the autofs mount. Mounts of the old instance
+//     are offered for expiry as soon as they are idle, and once unmounted,
+//     the repositories are mounted again by the new instance on next access.
+//  4. When the old nodeplugin Pod is being deleted, the old instance waits
+//     for its mounts to be replaced, at most for Opts.DrainTimeout, before
+//     exiting and stopping its CVMFS clients.
+//
+// Progress is recorded in handover.json in Opts.HandoverDir.
+
+const (
+	handoverSocketName = "handover.sock"
+	handoverRecordName = "handover.json"
+
+	handoverPath = "/v1/handover"
+
+	// How long to wait for the previous instance to hand over.
+	handoverRequestTimeout = 30 * time.Second
+
+	// Mounts of the previous instance are offered for expiry
+	// after being idle for this long.
+	handoverExpireTimeout = 30 * time.Second
+)
+
+const (
+	HandoverInProgress = "InProgress"
+	HandoverCompleted  = "Completed"
+
+	// Some mounts of the previous instance broke before they could be
+	// replaced, most likely because the previous instance exited before
+	// they became idle. These are unmounted, and consumers that were
+	// using them may need to be restarted.
+	HandoverCompletedWithErrors = "CompletedWithErrors"
+
+	// The previous instance didn't respond to the handover request,
+	// and the autofs mount was taken over without its cooperation.
+	HandoverTakenOver = "TakenOver"
+)
+
+// HandoverRecord describes the last handover of /cvmfs
+// from a previous automount-runner instance.
+type HandoverRecord struct {
+	Result string `json:"result"`
+
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+
+	// PIDs of the previous and of this automount-runner instance.
+	PreviousPID int `json:"previousPID,omitempty"`
+	PID         int `json:"pid"`
+
+	// Repositories mounted by the previous instance, still in use.
+	Pending []string `json:"pending,omitempty"`
+
+	// Repositories mounted by the previous instance that were unmounted
+	// once idle, and are mounted by this instance on next access.
+	Replaced []string `json:"replaced,omitempty"`
+
+	// Repositories mounted by the previous instance whose CVMFS clients
+	// exited before the mounts could be replaced.
+	Broken []string `json:"broken,omitempty"`
+
+	// Why the previous instance didn't hand over, if it didn't.
+	Error string `json:"error,omitempty"`
+}
+
+type handoverResponse struct {
+	PID int `json:"pid"`
+
+	// Mounts of repositories being handed over, keyed by
+	// repository name. Values are mount IDs from mountinfo.
+	Mounts map[string]int `json:"mounts"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// mountedRepositoryIDs returns mount IDs of CVMFS mounts in /cvmfs.
+func mountedRepositoryIDs() (map[string]int, error) {
+	mountPathPrefix := AutofsCvmfsRoot + "/"
+
+	infos, err := mountinfo.GetMounts(func(info *mountinfo.Info) (skip, stop bool) {
+		return info.FSType != "fuse" || !strings.HasPrefix(info.Mountpoint, mountPathPrefix),
+			false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make(map[string]int, len(infos))
+	for _, info := range infos {
+		mounts[info.Mountpoint[len(mountPathPrefix):]] = info.ID
+	}
+
+	return mounts, nil
+}
+
+func handoverClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+		Timeout: handoverRequestTimeout,
+	}
+}
+
+// requestHandover asks the automount-runner instance currently
+// serving /cvmfs to stop, and hand over its mounts.
+func requestHandover(handoverDir string) (*handoverResponse, error) {
+	socketPath := path.Join(handoverDir, handoverSocketName)
+
+	// Host is ignored when dialing the UNIX domain socket.
+	httpResp, err := handoverClient(socketPath).Post("http://localhost"+handoverPath, "application/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	var resp handoverResponse
+	if err = json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode handover response: %s", httpResp.Status)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("handover request failed: %s", resp.Error)
+	}
+
+	return &resp, nil
+}
+
+// serveHandover serves handover requests from the next
+// automount-runner instance.
+func (a *nativeAutomounter) serveHandover() error {
+	socketPath := path.Join(a.o.HandoverDir, handoverSocketName)
+
+	// The socket may be still served by the previous instance. It has
+	// already handed over at this point, and so it's safe to replace it.
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove existing UNIX domain socket %s: %v", socketPath, err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", socketPath, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+handoverPath, a.handleHandover)
+
+	log.Infof("Serving handover requests on %s", socketPath)
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Errorf("Failed to serve handover requests: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (a *nativeAutomounter) handleHandover(w http.ResponseWriter, r *http.Request) {
+	writeResponse := func(code int, resp *handoverResponse) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Errorf("Failed to write handover response: %v", err)
+		}
+	}
+
+	mounts, err := a.handOver()
+	if err != nil {
+		log.Errorf("Failed to hand over %s: %v", AutofsCvmfsRoot, err)
+		writeResponse(http.StatusInternalServerError, &handoverResponse{Error: err.Error()})
+		return
+	}
+
+	log.Infof("Handed over %s, mounts to be replaced by the next instance: %v", AutofsCvmfsRoot, mounts)
+
+	writeResponse(http.StatusOK, &handoverResponse{
+		PID:    os.Getpid(),
+		Mounts: mounts,
+	})
+}
+
+// handOver stops serving the autofs mount, and returns the mounts
+// the next instance will take over.
+func (a *nativeAutomounter) handOver() (map[string]int, error) {
+	a.mu.Lock()
+	if a.handedOver != nil {
+		a.mu.Unlock()
+		return nil, errors.New("already handed over")
+	}
+	a.handedOver = make(map[string]int)
+	a.mu.Unlock()
+
+	// Fail any pending lookups. The next instance takes over the mount
+	// right after we reply, and so the lookups can be retried shortly.
+	if err := a.m.Catatonic(); err != nil {
+		return nil, err
+	}
+
+	// Wait for mounts in progress, so that we can report them too.
+	a.inflight.Wait()
+
+	mounts, err := mountedRepositoryIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.handedOver = mounts
+	a.mu.Unlock()
+
+	return mounts, nil
+}
+
+func (a *nativeAutomounter) isHandedOver() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.handedOver != nil
+}
+
+// drain waits until mounts handed over to the next instance are
+// replaced, at most for o.DrainTimeout.
+func (a *nativeAutomounter) drain() {
+	a.mu.Lock()
+	handedOver := a.handedOver
+	a.mu.Unlock()
+
+	if len(handedOver) == 0 {
+		return
+	}
+
+	log.Infof("Waiting at most %s for the next instance to replace mounts %v", a.o.DrainTimeout, handedOver)
+
+	deadline := time.Now().Add(a.o.DrainTimeout)
+
+	for {
+		mounts, err := mountedRepositoryIDs()
+		if err != nil {
+			log.Errorf("Failed to list mounted repositories: %v", err)
+			return
+		}
+
+		var pending []string
+		for repo, id := range handedOver {
+			if mounts[repo] == id {
+				pending = append(pending, repo)
+			}
+		}
+
+		if len(pending) == 0 {
+			log.Infof("All handed over mounts were replaced")
+			return
+		}
+
+		if time.Now().After(deadline) {
+			log.Infof("Drain timeout expired, mounts still in use: %v", pending)
+			return
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+// takeOver is called by the new instance before connecting to the autofs
+// mount. Handover progress is then updated with updateHandoverProgress.
+func (a *nativeAutomounter) takeOver() {
+	a.record = &HandoverRecord{
+		Result:    HandoverInProgress,
+		StartedAt: time.Now(),
+		PID:       os.Getpid(),
+	}
+
+	resp, err := requestHandover(a.o.HandoverDir)
+	if err != nil {
+		log.Warningf("Previous instance did not hand over %s, taking it over: %v", AutofsCvmfsRoot, err)
+
+		a.record.Result = HandoverTakenOver
+		a.record.Error = err.Error()
+		a.completeRecord()
+
+		return
+	}
+
+	log.Infof("Previous instance PID %d handed over %s, mounts to be replaced: %v",
+		resp.PID, AutofsCvmfsRoot, resp.Mounts)
+
+	a.record.PreviousPID = resp.PID
+
+	a.mu.Lock()
+	a.handedOff = resp.Mounts
+	a.applyConfig(a.config)
+	a.mu.Unlock()
+}
+
+// updateHandoverProgress checks the mounts handed off by the previous
+// instance. Mounts that are gone are considered replaced, and broken
+// mounts are unmounted. Callers must not hold a.mu.
+func (a *nativeAutomounter) updateHandoverProgress() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.record == nil || a.record.CompletedAt != nil {
+		return
+	}
+
+	mounts, err := mountedRepositoryIDs()
+	if err != nil {
+		log.Errorf("Failed to list mounted repositories: %v", err)
+		return
+	}
+
+	for repo, id := range a.handedOff {
+		if mounts[repo] != id {
+			a.handOffDone(repo, false)
+			continue
+		}
+
+		mountpoint := path.Join(AutofsCvmfsRoot, repo)
+
+		if state, _ := mountutils.GetState(mountpoint); state != mountutils.StCorrupted {
+			continue
+		}
+
+		log.InfoS("Handed off mount is broken, unmounting", log.KeyRepository, repo)
+
+		if err := syscall.Unmount(mountpoint, syscall.MNT_DETACH); err != nil {
+			log.ErrorS(err, "Failed to unmount broken mount", log.KeyRepository, repo)
+			continue
+		}
+
+		os.Remove(mountpoint)
+		a.handOffDone(repo, true)
+	}
+
+	a.record.Pending = slices.Sorted(maps.Keys(a.handedOff))
+
+	handoverPendingRepositories.Set(float64(len(a.handedOff)))
+
+	if len(a.handedOff) > 0 {
+		a.writeRecord()
+		return
+	}
+
+	a.record.Result = HandoverCompleted
+	if len(a.record.Broken) > 0 {
+		a.record.Result = HandoverCompletedWithErrors
+	}
+
+	log.Infof("Handover of %s completed: %s", AutofsCvmfsRoot, a.record.Result)
+
+	a.applyConfig(a.config)
+	if err := a.m.SetTimeout(a.kernelTimeout); err != nil {
+		log.Errorf("Failed to restore autofs timeout: %v", err)
+	}
+
+	a.completeRecord()
+}
+
+// handOffDone marks repo as no longer served by the previous instance.
+// Callers must hold a.mu.
+func (a *nativeAutomounter) handOffDone(repo string, broken bool) {
+	if _, ok := a.handedOff[repo]; !ok {
+		return
+	}
+
+	delete(a.handedOff, repo)
+
+	if broken {
+		a.record.Broken = append(a.record.Broken, repo)
+		handedOffRepositories.WithLabelValues(resultFailure).Inc()
+	} else {
+		a.record.Replaced = append(a.record.Replaced, repo)
+		handedOffRepositories.WithLabelValues(resultSuccess).Inc()
+	}
+}
+
+func (a *nativeAutomounter) completeRecord() {
+	now := time.Now()
+	a.record.CompletedAt = &now
+	a.record.Pending = nil
+
+	a.writeRecord()
+}
+
+func (a *nativeAutomounter) writeRecord() {
+	recordJSON, err := json.MarshalIndent(a.record, "", "  ")
+	if err != nil {
+		log.Errorf("Failed to marshal handover record: %v", err)
+		return
+	}
+
+	recordPath := path.Join(a.o.HandoverDir, handoverRecordName)
+	tmpPath := recordPath + ".tmp"
+
+	if err = os.WriteFile(tmpPath, append(recordJSON, '\n'), 0o644); err == nil {
+		err = os.Rename(tmpPath, recordPath)
+	}
+
+	if err != nil {
+		log.Errorf("Failed to write handover record to %s: %v", recordPath, err)
+	}
+}
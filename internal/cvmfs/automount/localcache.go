@@ -0,0 +1,253 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package automount
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	goexec "os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount/autofs"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+)
+
+// What to do with the local cache left behind by previous nodeplugin Pod runs.
+const (
+	// Remove the local cache contents.
+	CachePreservationWipe = "wipe"
+
+	// Check integrity of the local cache with cvmfs_fsck, and keep
+	// it if it's healthy or could be repaired. Remove it otherwise.
+	CachePreservationVerify = "verify"
+
+	// Keep the local cache as is.
+	CachePreservationKeep = "keep"
+)
+
+// cvmfs_fsck exit codes, following fsck(8).
+const (
+	fsckOK    = 0
+	fsckFixed = 1
+)
+
+func ValidateCachePreservation(mode string) error {
+	switch mode {
+	case CachePreservationWipe, CachePreservationVerify, CachePreservationKeep:
+		return nil
+	default:
+		return fmt.Errorf("unknown cache preservation mode %q, allowed values are: %s, %s, %s",
+			mode, CachePreservationWipe, CachePreservationVerify, CachePreservationKeep)
+	}
+}
+
+func wipeLocalCache(cacheDir string) error {
+	log.Debugf("Cleaning up local cache directory %s...", cacheDir)
+
+	if err := removeDirContents(cacheDir); err != nil {
+		return fmt.Errorf("failed to clean up local cache directory %s: %v", cacheDir, err)
+	}
+
+	log.Debugf("Finished cleaning up local cache directory %s", cacheDir)
+
+	return nil
+}
+
+// listCacheInstances returns directories inside cacheDir holding
+// cached objects. With CVMFS_SHARED_CACHE=yes, there is only one such
+// directory named "shared". Otherwise, each repository has its own.
+func listCacheInstances(cacheDir string) ([]string, error) {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var instances []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		instance := path.Join(cacheDir, entry.Name())
+
+		// Every cache directory has a directory for files being
+		// downloaded. Use it to tell them apart from other contents.
+		if fi, err := os.Stat(path.Join(instance, "txn")); err == nil && fi.IsDir() {
+			instances = append(instances, instance)
+		}
+	}
+
+	return instances, nil
+}
+
+// verifyCacheInstance runs cvmfs_fsck on a cache directory, repairing
+// what it can. Returns an error if the cache is not usable.
+func verifyCacheInstance(instance string) error {
+	// -p: repair errors, corrupted files are moved into quarantaine.
+	out, err := exec.CombinedOutput(goexec.Command("cvmfs_fsck", "-p", instance))
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *goexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == fsckFixed {
+		log.Infof("Repaired errors in local cache directory %s: %s", instance, out)
+		return nil
+	}
+
+	return fmt.Errorf("cvmfs_fsck failed (%v): %s", err, out)
+}
+
+func verifyLocalCache(cacheDir string) error {
+	instances, err := listCacheInstances(cacheDir)
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		log.Infof("Verifying local cache directory %s...", instance)
+
+		if err = verifyCacheInstance(instance); err != nil {
+			return fmt.Errorf("local cache directory %s is corrupted: %v", instance, err)
+		}
+	}
+
+	log.Infof("Verified %d local cache directories in %s", len(instances), cacheDir)
+
+	return nil
+}
+
+// isInDir returns true if p is dir or is inside of it.
+func isInDir(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// cacheUsers returns PIDs of cvmfs2 processes whose working directory or
+// open files are in cacheDir. Processes of other Pods are visible only
+// with hostPID.
+func cacheUsers(cacheDir string) ([]string, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []string
+
+	for _, entry := range entries {
+		pid := entry.Name()
+		if pid[0] < '0' || pid[0] > '9' {
+			continue
+		}
+
+		comm, err := os.ReadFile(path.Join("/proc", pid, "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != "cvmfs2" {
+			continue
+		}
+
+		if cwd, err := os.Readlink(path.Join("/proc", pid, "cwd")); err == nil && isInDir(cwd, cacheDir) {
+			pids = append(pids, pid)
+			continue
+		}
+
+		fdDir := path.Join("/proc", pid, "fd")
+		fds, _ := os.ReadDir(fdDir)
+
+		for _, fd := range fds {
+			if target, err := os.Readlink(path.Join(fdDir, fd.Name())); err == nil && isInDir(target, cacheDir) {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+
+	return pids, nil
+}
+
+// localCacheInUse returns a reason why the local cache is still in use
+// by the previous nodeplugin Pod, or an empty string if it's not. This
+// happens during handover of /cvmfs, when the CVMFS clients of the old
+// instance keep serving their mounts until they are replaced.
+func localCacheInUse(cacheDir string, handover bool) (string, error) {
+	if handover {
+		isAutofs, err := autofs.IsAutofs(AutofsCvmfsRoot)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if isAutofs {
+			return fmt.Sprintf("%s is an existing autofs mount being taken over", AutofsCvmfsRoot), nil
+		}
+	}
+
+	pids, err := cacheUsers(cacheDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(pids) > 0 {
+		return fmt.Sprintf("cvmfs2 processes %v are using it", pids), nil
+	}
+
+	return "", nil
+}
+
+// prepareLocalCache handles the local cache in cacheDir left behind
+// by previous nodeplugin Pod runs, according to the preservation mode.
+// The cache is kept as is if it's still in use by the previous Pod,
+// e.g. when /cvmfs is being handed over.
+func prepareLocalCache(cacheDir, mode string, handover bool) error {
+	if mode != CachePreservationKeep {
+		reason, err := localCacheInUse(cacheDir, handover)
+		if err != nil {
+			return fmt.Errorf("failed to check whether local cache directory %s is in use: %v", cacheDir, err)
+		}
+
+		if reason != "" {
+			log.Infof("Keeping local cache directory %s as is (preservation mode %s is skipped): %s",
+				cacheDir, mode, reason)
+			return nil
+		}
+	}
+
+	switch mode {
+	case CachePreservationKeep:
+		log.Infof("Keeping local cache directory %s", cacheDir)
+		return nil
+	case CachePreservationVerify:
+		err := verifyLocalCache(cacheDir)
+		if err == nil {
+			log.Infof("Keeping local cache directory %s", cacheDir)
+			return nil
+		}
+
+		log.Warningf("Failed to verify local cache, wiping it: %v", err)
+		fallthrough
+	default:
+		// Clean up local cache. It may be dirty after previous nodeplugin Pod runs.
+		return wipeLocalCache(cacheDir)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package automount
+
+import (
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "automount"
+
+var (
+	mountRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "mount_requests_total",
+			Help:      "Number of autofs mount requests handled by the native automounter, by result.",
+		},
+		[]string{"result"},
+	)
+
+	expireRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "expire_requests_total",
+			Help:      "Number of autofs expire requests handled by the native automounter, by result.",
+		},
+		[]string{"result"},
+	)
+
+	handoverPendingRepositories = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "handover_pending_repositories",
+			Help:      "Number of mounts handed off by the previous automount-runner instance that are yet to be replaced.",
+		},
+	)
+
+	handedOffRepositories = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "handed_off_repositories_total",
+			Help:      "Number of mounts handed off by the previous automount-runner instance, by result of their replacement.",
+		},
+		[]string{"result"},
+	)
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultRefused = "refused"
+)
+
+func registerMetrics() {
+	metrics.MustRegister(
+		mountRequests,
+		expireRequests,
+		handoverPendingRepositories,
+		handedOffRepositories,
+	)
+}
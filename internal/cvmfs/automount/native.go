@@ -0,0 +1,587 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package automount
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	goexec "os/exec"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount/autofs"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/env"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
+)
+
+const (
+	// /cvmfs is served by the automount daemon from autofs-tools,
+	// using /etc/auto.cvmfs map from the cvmfs package.
+	AutomounterExternal = "external"
+
+	// /cvmfs is served by automount-runner itself, talking directly
+	// to the autofs kernel module.
+	AutomounterNative = "native"
+)
+
+func ValidateAutomounter(automounter string) error {
+	switch automounter {
+	case AutomounterExternal, AutomounterNative:
+		return nil
+	default:
+		return fmt.Errorf("unknown automounter \"%s\", expected one of: %s, %s",
+			automounter, AutomounterExternal, AutomounterNative)
+	}
+}
+
+type nativeAutomounter struct {
+	m *autofs.Mount
+	o *Opts
+
+	// Guards settings and idle tracking below.
+	mu sync.Mutex
+
+	config         *Config
+	defaultTimeout time.Duration
+	repoTimeouts   map[string]time.Duration
+
+	// Timeout set in the kernel. This is the shortest of all configured
+	// timeouts, and so the kernel offers mounts for expiry at least as often
+	// as needed. Repositories with longer timeouts refuse the offers until
+	// they have been idle for long enough.
+	kernelTimeout time.Duration
+	expirePeriod  time.Duration
+
+	// Per-repository time since which we believe the mount is idle.
+	idleSince map[string]time.Time
+	// Per-repository time of the last expire offer from the kernel.
+	lastOffered map[string]time.Time
+
+	// Mounts handed over to the next instance, keyed by repository name.
+	// Non-nil once the handover has started.
+	handedOver map[string]int
+	// Mounts handed off by the previous instance, not yet replaced.
+	handedOff map[string]int
+	// Record of the handover from the previous instance, if any.
+	record *HandoverRecord
+
+	// Mount requests being handled.
+	inflight sync.WaitGroup
+}
+
+func newNativeAutomounter(o *Opts) (*nativeAutomounter, error) {
+	config, err := LoadConfig(o.ConfigFilepath)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &nativeAutomounter{
+		o:              o,
+		defaultTimeout: time.Duration(o.UnmountTimeoutSeconds) * time.Second,
+		idleSince:      make(map[string]time.Time),
+		lastOffered:    make(map[string]time.Time),
+	}
+
+	a.applyConfig(config)
+
+	return a, nil
+}
+
+// applyConfig sets timeouts from command line options and config.
+// Timeouts set in config take precedence. Callers must hold a.mu
+// if the automounter is already running.
+func (a *nativeAutomounter) applyConfig(config *Config) {
+	a.config = config
+	a.repoTimeouts = make(map[string]time.Duration)
+	a.kernelTimeout = a.defaultTimeout
+
+	setTimeout := func(repo string, seconds int) {
+		timeout := time.Duration(seconds) * time.Second
+		a.repoTimeouts[repo] = timeout
+
+		if timeout > 0 && (a.kernelTimeout == 0 || timeout < a.kernelTimeout) {
+			a.kernelTimeout = timeout
+		}
+	}
+
+	for repo, seconds := range config.UnmountTimeouts() {
+		setTimeout(repo, seconds)
+	}
+
+	if len(a.handedOff) > 0 && (a.kernelTimeout == 0 || a.kernelTimeout > handoverExpireTimeout) {
+		// Replace mounts of the previous instance as soon as possible.
+		a.kernelTimeout = handoverExpireTimeout
+	}
+
+	// Same as the automount daemon does.
+	a.expirePeriod = max(a.kernelTimeout/4, time.Second)
+}
+
+// reload re-reads automount config. On error, the previous config is kept.
+func (a *nativeAutomounter) reload() {
+	config, err := LoadConfig(a.o.ConfigFilepath)
+	if err != nil {
+		log.Errorf("Failed to reload automount config, keeping the previous one: %v", err)
+		return
+	}
+
+	a.mu.Lock()
+	a.applyConfig(config)
+	kernelTimeout := a.kernelTimeout
+	a.mu.Unlock()
+
+	if err = a.m.SetTimeout(kernelTimeout); err != nil {
+		log.Errorf("Failed to reload automount config: %v", err)
+		return
+	}
+
+	a.logSettings("Reloaded automount config")
+}
+
+func (a *nativeAutomounter) logSettings(msg string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	log.Infof("%s defaultTimeout=%s repositoryTimeouts=%v kernelTimeout=%s blockedRepositories=%v",
+		msg, a.defaultTimeout, a.repoTimeouts, a.kernelTimeout, a.config.BlockedRepositories)
+}
+
+func (a *nativeAutomounter) timeoutFor(repo string) time.Duration {
+	if timeout, ok := a.repoTimeouts[repo]; ok {
+		return timeout
+	}
+
+	return a.defaultTimeout
+}
+
+// RunNativeBlocking serves /cvmfs autofs mount until SIGINT or SIGTERM
+// is received. SIGHUP reloads automount config. If Opts.HandoverDir is set,
+// the autofs mount is taken over from the previous instance, and handed
+// over to the next one, see handover.go. The autofs mount is left in place on exit, unless
+// AUTOFS_TRY_CLEAN_AT_EXIT is set, so that the next run can reconnect to it
+// without disrupting existing consumers.
+func RunNativeBlocking(o *Opts) error {
+	registerMetrics()
+
+	a, err := newNativeAutomounter(o)
+	if err != nil {
+		return err
+	}
+
+	if o.HandoverDir != "" {
+		isAutofs, err := autofs.IsAutofs(AutofsCvmfsRoot)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if isAutofs {
+			a.takeOver()
+		}
+	}
+
+	m, reconnected, err := autofs.Open(AutofsCvmfsRoot, a.kernelTimeout)
+	if err != nil {
+		return err
+	}
+	a.m = m
+
+	if reconnected {
+		log.Infof("Reconnected to existing autofs mount in %s", AutofsCvmfsRoot)
+		a.cleanupStaleMounts()
+		a.updateHandoverProgress()
+	} else {
+		log.Infof("Mounted autofs in %s", AutofsCvmfsRoot)
+	}
+
+	if o.HandoverDir != "" {
+		if err = a.serveHandover(); err != nil {
+			return err
+		}
+	}
+
+	a.logSettings("Serving " + AutofsCvmfsRoot)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	doneCh := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.servePackets()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.runExpire(doneCh)
+	}()
+
+	for sig := range sigCh {
+		if sig == syscall.SIGHUP {
+			a.reload()
+			continue
+		}
+
+		log.Infof("Received %s, exiting", sig)
+		break
+	}
+
+	close(doneCh)
+
+	if a.isHandedOver() {
+		// The next instance serves /cvmfs now. Keep our CVMFS clients
+		// running until it replaces their mounts.
+		a.drain()
+	} else if env.GetAutofsTryCleanAtExit() {
+		a.unmountAll()
+		if err := a.m.Unmount(); err != nil {
+			log.Errorf("Failed to unmount autofs in %s: %v", AutofsCvmfsRoot, err)
+		}
+	}
+
+	// Closing the pipe stops servePackets. Any in-flight mount requests
+	// are left to the kernel, which fails them once the mount goes catatonic.
+	err = a.m.Close()
+
+	wg.Wait()
+
+	return err
+}
+
+func (a *nativeAutomounter) servePackets() {
+	for {
+		pkt, err := a.m.ReadPacket()
+		if err != nil {
+			switch {
+			case errors.Is(err, os.ErrClosed):
+			case errors.Is(err, io.EOF):
+				// The kernel drops its end of the pipe when the mount
+				// becomes catatonic, e.g. after a handover.
+				log.Infof("autofs mount in %s stopped sending requests", AutofsCvmfsRoot)
+			default:
+				log.Errorf("Failed to read autofs packet: %v", err)
+			}
+			return
+		}
+
+		log.Tracef("Received autofs packet type=%s token=%d name=%s pid=%d",
+			pkt.Type, pkt.Token, pkt.Name, pkt.PID)
+
+		a.mu.Lock()
+		if a.handedOver != nil {
+			// The mount is catatonic now, and the kernel has failed the request already.
+			a.mu.Unlock()
+			continue
+		}
+		a.inflight.Add(1)
+		a.mu.Unlock()
+
+		switch pkt.Type {
+		case autofs.PacketMissingIndirect:
+			go func() {
+				defer a.inflight.Done()
+				a.handleMount(pkt)
+			}()
+		case autofs.PacketExpireIndirect:
+			go func() {
+				defer a.inflight.Done()
+				a.handleExpire(pkt)
+			}()
+		default:
+			log.Errorf("Unexpected autofs packet type %s for %s", pkt.Type, pkt.Name)
+			a.reply(pkt, syscall.EINVAL)
+			a.inflight.Done()
+		}
+	}
+}
+
+func (a *nativeAutomounter) reply(pkt *autofs.Packet, status syscall.Errno) {
+	var err error
+	if status == 0 {
+		err = a.m.Ready(pkt.Token)
+	} else {
+		err = a.m.Fail(pkt.Token, status)
+	}
+
+	if err != nil {
+		log.ErrorS(err, "Failed to reply to autofs request", "type", pkt.Type, log.KeyRepository, pkt.Name)
+	}
+}
+
+func isValidRepositoryName(name string) bool {
+	return name != "" &&
+		!strings.HasPrefix(name, ".") &&
+		strings.Contains(name, ".") &&
+		!strings.ContainsAny(name, "/ \t\n")
+}
+
+func (a *nativeAutomounter) handleMount(pkt *autofs.Packet) {
+	repo := pkt.Name
+
+	if !isValidRepositoryName(repo) {
+		// Shells, file browsers and others tend to look up all sorts
+		// of names. Don't bother trying to mount these.
+		log.Debugf("Refusing to automount invalid repository name \"%s\"", repo)
+		mountRequests.WithLabelValues(resultRefused).Inc()
+		a.reply(pkt, syscall.ENOENT)
+		return
+	}
+
+	a.mu.Lock()
+	config := a.config
+	a.mu.Unlock()
+
+	if config.IsBlocked(repo) {
+		log.InfoS("Refusing to automount blocked repository", log.KeyRepository, repo)
+		mountRequests.WithLabelValues(resultRefused).Inc()
+		a.reply(pkt, syscall.ENOENT)
+		return
+	}
+
+	a.forgetIdle(repo)
+
+	if err := mountRepository(repo, config.MountOptions(repo)); err != nil {
+		log.ErrorS(err, "Failed to automount repository", log.KeyRepository, repo)
+		mountRequests.WithLabelValues(resultFailure).Inc()
+		a.reply(pkt, syscall.ENOENT)
+		return
+	}
+
+	log.InfoS("Mounted repository", log.KeyRepository, repo)
+	mountRequests.WithLabelValues(resultSuccess).Inc()
+	a.reply(pkt, 0)
+}
+
+func mountRepository(repo string, mountOptions []string) error {
+	mountpoint := path.Join(AutofsCvmfsRoot, repo)
+
+	if err := os.Mkdir(mountpoint, 0o755); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	args := []string{"-t", "cvmfs"}
+
+	if log.LevelEnabled(log.LevelTrace) {
+		// Enable CVMFS debug logging.
+		mountOptions = append(slices.Clip(mountOptions), "debug")
+	}
+
+	if len(mountOptions) > 0 {
+		args = append(args, "-o", strings.Join(mountOptions, ","))
+	}
+
+	args = append(args, repo, mountpoint)
+
+	if _, err := exec.CombinedOutput(goexec.Command("mount", args...)); err != nil {
+		os.Remove(mountpoint)
+		return err
+	}
+
+	return nil
+}
+
+func (a *nativeAutomounter) handleExpire(pkt *autofs.Packet) {
+	repo := pkt.Name
+
+	if !a.shouldExpire(repo, time.Now()) {
+		log.Tracef("Repository %s has not been idle for long enough, refusing to expire", repo)
+		expireRequests.WithLabelValues(resultRefused).Inc()
+		a.reply(pkt, syscall.EBUSY)
+		return
+	}
+
+	mountpoint := path.Join(AutofsCvmfsRoot, repo)
+
+	if err := syscall.Unmount(mountpoint, 0); err != nil && err != syscall.EINVAL {
+		// EINVAL means it's not mounted, nothing to do then.
+		log.ErrorS(err, "Failed to unmount expired repository", log.KeyRepository, repo)
+		expireRequests.WithLabelValues(resultFailure).Inc()
+		a.reply(pkt, syscall.EBUSY)
+		return
+	}
+
+	if err := os.Remove(mountpoint); err != nil && !os.IsNotExist(err) {
+		log.ErrorS(err, "Failed to remove mountpoint of expired repository", log.KeyRepository, repo)
+	}
+
+	log.InfoS("Unmounted idle repository", log.KeyRepository, repo)
+	expireRequests.WithLabelValues(resultSuccess).Inc()
+	a.reply(pkt, 0)
+}
+
+// shouldExpire decides whether an idle mount offered for expiry by the kernel
+// has reached its own timeout.
+//
+// The kernel knows only about the shortest of the timeouts. When it offers
+// a mount for expiry and we refuse, it resets the mount's last-used time,
+// so an idle mount is offered again after kernelTimeout. We treat a series
+// of such offers as a single idle period, until a gap between two offers
+// suggests the mount was used in between.
+func (a *nativeAutomounter) shouldExpire(repo string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.handedOff[repo]; ok {
+		// Mounted by the previous instance, replace it.
+		return true
+	}
+
+	timeout := a.timeoutFor(repo)
+
+	if timeout == 0 {
+		// Never unmount.
+		return false
+	}
+
+	if timeout <= a.kernelTimeout {
+		return true
+	}
+
+	lastOffered, ok := a.lastOffered[repo]
+	if !ok || now.Sub(lastOffered) > a.kernelTimeout+2*a.expirePeriod {
+		a.idleSince[repo] = now.Add(-a.kernelTimeout)
+	}
+	a.lastOffered[repo] = now
+
+	if now.Sub(a.idleSince[repo]) < timeout {
+		return false
+	}
+
+	delete(a.idleSince, repo)
+	delete(a.lastOffered, repo)
+
+	return true
+}
+
+func (a *nativeAutomounter) forgetIdle(repo string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.idleSince, repo)
+	delete(a.lastOffered, repo)
+}
+
+func (a *nativeAutomounter) runExpire(doneCh <-chan struct{}) {
+	for {
+		a.mu.Lock()
+		kernelTimeout, expirePeriod := a.kernelTimeout, a.expirePeriod
+		a.mu.Unlock()
+
+		select {
+		case <-doneCh:
+			return
+		case <-time.After(expirePeriod):
+		}
+
+		if a.isHandedOver() {
+			// Expiring is up to the next instance now.
+			return
+		}
+
+		a.updateHandoverProgress()
+
+		if kernelTimeout == 0 {
+			// Nothing to expire, but the timeouts may change on config reload.
+			continue
+		}
+
+		// Keep expiring until there's nothing left to expire.
+		// Refused expires don't count, as the kernel won't offer
+		// the same mount again until it's been idle for another
+		// kernelTimeout.
+		for {
+			expired, err := a.m.Expire()
+			if err != nil && !errors.Is(err, syscall.EBUSY) {
+				log.Errorf("Failed to expire mounts in %s: %v", AutofsCvmfsRoot, err)
+				break
+			}
+
+			if !expired && err == nil {
+				break
+			}
+		}
+	}
+}
+
+// cleanupStaleMounts removes mounts in /cvmfs left behind by the previous
+// run whose CVMFS clients are no longer running, as well as empty
+// directories without a mount.
+func (a *nativeAutomounter) cleanupStaleMounts() {
+	entries, err := os.ReadDir(AutofsCvmfsRoot)
+	if err != nil {
+		log.Errorf("Failed to list %s: %v", AutofsCvmfsRoot, err)
+		return
+	}
+
+	for _, entry := range entries {
+		mountpoint := path.Join(AutofsCvmfsRoot, entry.Name())
+
+		state, err := mountutils.GetState(mountpoint)
+		if err != nil {
+			log.ErrorS(err, "Failed to check mount state", log.KeyRepository, entry.Name())
+			continue
+		}
+
+		switch state {
+		case mountutils.StMounted:
+			log.InfoS("Keeping existing mount", log.KeyRepository, entry.Name())
+			continue
+		case mountutils.StCorrupted:
+			log.InfoS("Unmounting broken mount left behind by previous run", log.KeyRepository, entry.Name())
+			if err := syscall.Unmount(mountpoint, syscall.MNT_DETACH); err != nil {
+				log.ErrorS(err, "Failed to unmount broken mount", log.KeyRepository, entry.Name())
+				continue
+			}
+		}
+
+		if err := os.Remove(mountpoint); err != nil {
+			log.ErrorS(err, "Failed to remove stale mountpoint", log.KeyRepository, entry.Name())
+		}
+	}
+}
+
+func (a *nativeAutomounter) unmountAll() {
+	repos, err := MountedRepositories()
+	if err != nil {
+		log.Errorf("Failed to list mounted repositories: %v", err)
+		return
+	}
+
+	for _, repo := range repos {
+		mountpoint := path.Join(AutofsCvmfsRoot, repo)
+
+		if err := syscall.Unmount(mountpoint, 0); err != nil {
+			log.ErrorS(err, "Failed to unmount repository", log.KeyRepository, repo)
+			continue
+		}
+
+		os.Remove(mountpoint)
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package automount
+
+import (
+	"errors"
+	"os"
+	goexec "os/exec"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount/autofs"
+
+	"github.com/moby/sys/mountinfo"
+)
+
+// useFakeMountCvmfs puts a mount executable in PATH that mounts a tmpfs
+// instead of CVMFS repositories, and fails for repositories whose name
+// starts with "fail.". Arguments of the CVMFS mounts are appended to
+// the returned log file, one mount per line.
+func useFakeMountCvmfs(t *testing.T) string {
+	t.Helper()
+
+	realMount, err := goexec.LookPath("mount")
+	if err != nil {
+		t.Skip("mount is not available")
+	}
+
+	binDir := t.TempDir()
+	logPath := path.Join(binDir, "mount.log")
+
+	// mount -t cvmfs [-o <options>] <repository> <mountpoint>
+	script := `#!/bin/sh
+if [ "$1" != "-t" ] || [ "$2" != "cvmfs" ]; then
+	exec ` + realMount + ` "$@"
+fi
+echo "$@" >> ` + logPath + `
+for mountpoint; do :; done
+case "$(basename "$mountpoint")" in
+fail.*) echo "failed to mount" >&2; exit 32 ;;
+esac
+exec ` + realMount + ` -t tmpfs cvmfs-csi-test "$mountpoint"
+`
+
+	if err = os.WriteFile(path.Join(binDir, "mount"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", binDir+":"+os.Getenv("PATH"))
+
+	return logPath
+}
+
+// readMountLog returns lines of the log written by the fake mount.
+func readMountLog(t *testing.T, logPath string) []string {
+	t.Helper()
+
+	contents, err := os.ReadFile(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(contents)), "\n")
+}
+
+// startNativeAutomounter serves autofs in a temporary AutofsCvmfsRoot
+// until the test finishes.
+func startNativeAutomounter(t *testing.T, o *Opts) *nativeAutomounter {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("test needs to mount filesystems, run it as root")
+	}
+
+	if _, err := os.Stat("/dev/autofs"); err != nil {
+		t.Skipf("autofs is not available: %v", err)
+	}
+
+	origAutofsCvmfsRoot := AutofsCvmfsRoot
+	AutofsCvmfsRoot = path.Join(t.TempDir(), "cvmfs")
+	t.Cleanup(func() { AutofsCvmfsRoot = origAutofsCvmfsRoot })
+
+	a, err := newNativeAutomounter(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.m, _, err = autofs.Open(AutofsCvmfsRoot, a.kernelTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.servePackets()
+	}()
+
+	t.Cleanup(func() {
+		// Listing the autofs root from the process serving it
+		// doesn't trigger any mounts.
+		entries, _ := os.ReadDir(AutofsCvmfsRoot)
+		for _, entry := range entries {
+			syscall.Unmount(path.Join(AutofsCvmfsRoot, entry.Name()), syscall.MNT_DETACH)
+		}
+
+		if err := a.m.Unmount(); err != nil {
+			t.Errorf("failed to unmount autofs: %v", err)
+		}
+
+		a.m.Close()
+		wg.Wait()
+	})
+
+	return a
+}
+
+// lookup accesses repository in AutofsCvmfsRoot. The kernel doesn't send
+// requests for lookups from the automounter's own process group, and so
+// this is done from a new one.
+func lookup(repository string) error {
+	cmd := goexec.Command("stat", path.Join(AutofsCvmfsRoot, repository))
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	return cmd.Run()
+}
+
+func isMounted(t *testing.T, repository string) bool {
+	t.Helper()
+
+	mounted, err := mountinfo.Mounted(path.Join(AutofsCvmfsRoot, repository))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	return mounted
+}
+
+func TestNativeAutomounterMissing(t *testing.T) {
+	logPath := useFakeMountCvmfs(t)
+	startNativeAutomounter(t, &Opts{})
+
+	if err := lookup("sft.cern.ch"); err != nil {
+		t.Fatalf("lookup of sft.cern.ch failed: %v", err)
+	}
+
+	if !isMounted(t, "sft.cern.ch") {
+		t.Errorf("sft.cern.ch was not mounted")
+	}
+
+	// Mounted repositories are not requested again.
+
+	if err := lookup("sft.cern.ch"); err != nil {
+		t.Fatalf("second lookup of sft.cern.ch failed: %v", err)
+	}
+
+	// Names that are not repositories are refused without mounting,
+	// and failed mounts leave no mountpoint behind.
+
+	for _, name := range []string{"foo", ".hidden.cern.ch", "fail.cern.ch"} {
+		if err := lookup(name); err == nil {
+			t.Errorf("lookup of %s succeeded", name)
+		}
+
+		if _, err := os.Stat(path.Join(AutofsCvmfsRoot, name)); !os.IsNotExist(err) {
+			t.Errorf("mountpoint of %s was left behind: %v", name, err)
+		}
+	}
+
+	want := []string{
+		"-t cvmfs sft.cern.ch " + path.Join(AutofsCvmfsRoot, "sft.cern.ch"),
+		"-t cvmfs fail.cern.ch " + path.Join(AutofsCvmfsRoot, "fail.cern.ch"),
+	}
+	if got := readMountLog(t, logPath); !slices.Equal(got, want) {
+		t.Errorf("got mounts %q, want %q", got, want)
+	}
+}
+
+func TestNativeAutomounterExpire(t *testing.T) {
+	useFakeMountCvmfs(t)
+	a := startNativeAutomounter(t, &Opts{UnmountTimeoutSeconds: 1})
+
+	if err := lookup("sft.cern.ch"); err != nil {
+		t.Fatalf("lookup of sft.cern.ch failed: %v", err)
+	}
+
+	// Mounts are offered for expiry only once they have been idle
+	// for the timeout set in the kernel.
+
+	if expired, err := a.m.Expire(); err != nil || expired {
+		t.Fatalf("mount in use was expired: %v, %v", expired, err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if expired, err := a.m.Expire(); err != nil || !expired {
+		t.Fatalf("idle mount was not expired: %v, %v", expired, err)
+	}
+
+	if isMounted(t, "sft.cern.ch") {
+		t.Errorf("sft.cern.ch is still mounted after expiry")
+	}
+
+	if _, err := os.Stat(path.Join(AutofsCvmfsRoot, "sft.cern.ch")); !os.IsNotExist(err) {
+		t.Errorf("mountpoint of expired sft.cern.ch was left behind: %v", err)
+	}
+
+	// Expired repositories are mounted again on the next access.
+
+	if err := lookup("sft.cern.ch"); err != nil {
+		t.Fatalf("lookup of sft.cern.ch after expiry failed: %v", err)
+	}
+
+	if !isMounted(t, "sft.cern.ch") {
+		t.Errorf("sft.cern.ch was not mounted again")
+	}
+}
+
+func TestNativeAutomounterConfig(t *testing.T) {
+	logPath := useFakeMountCvmfs(t)
+
+	configPath := path.Join(t.TempDir(), "config.json")
+	writeConfig := func(config string) {
+		if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig(`{
+  "repositories": {
+    "sft.cern.ch": {"unmountTimeoutSeconds": 0},
+    "atlas.cern.ch": {"mountOptions": ["nofiles", "foreground"]}
+  },
+  "blockedRepositories": ["*.example.org"]
+}`)
+
+	a := startNativeAutomounter(t, &Opts{UnmountTimeoutSeconds: 1, ConfigFilepath: configPath})
+
+	for _, repository := range []string{"atlas.cern.ch", "sft.cern.ch"} {
+		if err := lookup(repository); err != nil {
+			t.Fatalf("lookup of %s failed: %v", repository, err)
+		}
+	}
+
+	if err := lookup("repo.example.org"); err == nil {
+		t.Errorf("lookup of blocked repo.example.org succeeded")
+	}
+
+	want := []string{
+		"-t cvmfs -o nofiles,foreground atlas.cern.ch " + path.Join(AutofsCvmfsRoot, "atlas.cern.ch"),
+		"-t cvmfs sft.cern.ch " + path.Join(AutofsCvmfsRoot, "sft.cern.ch"),
+	}
+	if got := readMountLog(t, logPath); !slices.Equal(got, want) {
+		t.Errorf("got mounts %q, want %q", got, want)
+	}
+
+	// sft.cern.ch is never unmounted, while atlas.cern.ch
+	// uses the default timeout.
+
+	time.Sleep(1500 * time.Millisecond)
+
+	// Same as runExpire, keep expiring until there's nothing left.
+	// Refused expires fail with EBUSY.
+	for i := 0; i < 10; i++ {
+		expired, err := a.m.Expire()
+		if err != nil && !errors.Is(err, syscall.EBUSY) {
+			t.Fatal(err)
+		}
+		if !expired && err == nil {
+			break
+		}
+	}
+
+	if isMounted(t, "atlas.cern.ch") {
+		t.Errorf("idle atlas.cern.ch was not unmounted")
+	}
+
+	if !isMounted(t, "sft.cern.ch") {
+		t.Errorf("sft.cern.ch was unmounted despite having no timeout")
+	}
+
+	// Config changes are picked up on reload.
+
+	writeConfig(`{"blockedRepositories": ["atlas.cern.ch"]}`)
+	a.reload()
+
+	if err := lookup("repo.example.org"); err != nil {
+		t.Errorf("lookup of repo.example.org unblocked on reload failed: %v", err)
+	}
+
+	if err := lookup("atlas.cern.ch"); err == nil {
+		t.Errorf("lookup of atlas.cern.ch blocked on reload succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mountreconcile
+
+import (
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "automount_reconciler"
+
+var (
+	reconcileRuns = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "runs_total",
+			Help:      "Number of reconciliation runs.",
+		},
+	)
+
+	reconcileRepairs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "repairs_total",
+			Help:      "Number of corrupted CVMFS mounts that were unmounted.",
+		},
+	)
+
+	reconcileFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "failures_total",
+			Help:      "Number of CVMFS mounts that failed to be checked or repaired.",
+		},
+	)
+
+	targetRepairs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "target_repairs_total",
+			Help:      "Number of stale CVMFS mounts in volume target paths that were re-bound from /cvmfs.",
+		},
+	)
+
+	targetRepairFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "target_repair_failures_total",
+			Help:      "Number of stale CVMFS mounts in volume target paths that failed to be re-bound.",
+		},
+	)
+
+	mountedRepositories = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "mounted_repositories",
+			Help:      "Number of CVMFS repositories mounted in /cvmfs, as seen by the last reconciliation run.",
+		},
+	)
+)
+
+func registerMetrics() {
+	metrics.MustRegister(
+		reconcileRuns,
+		reconcileRepairs,
+		reconcileFailures,
+		targetRepairs,
+		targetRepairFailures,
+		mountedRepositories,
+	)
+}
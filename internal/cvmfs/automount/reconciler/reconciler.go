@@ -19,32 +19,49 @@ package mountreconcile
 import (
 	"bytes"
 	"fmt"
-	goexec "os/exec"
 	"path"
-	"strings"
 	"time"
 
-	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/talk"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
-
-	"github.com/moby/sys/mountinfo"
 )
 
 const mountPathPrefix = "/cvmfs/"
 
 type Opts struct {
 	Period time.Duration
+
+	// PodsDir is the kubelet's pods directory. If set, volume target
+	// paths inside of it are checked for stale CVMFS mounts too.
+	PodsDir string
 }
 
 func RunBlocking(o *Opts) error {
+	registerMetrics()
+
 	t := time.NewTicker(o.Period)
 
 	doReconcile := func() {
+		// Stale mounts in volume target paths are looked up first, while
+		// the dead mounts in /cvmfs they were bound from are still there.
+		var staleTargets []staleTarget
+		if o.PodsDir != "" {
+			log.Tracef("Reconciling volume target paths in %s", o.PodsDir)
+
+			var err error
+			if staleTargets, err = findStaleTargets(o.PodsDir); err != nil {
+				log.Errorf("Failed to reconcile volume target paths in %s: %v", o.PodsDir, err)
+			}
+		}
+
 		log.Tracef("Reconciling /cvmfs")
 		if err := reconcile(); err != nil {
 			log.Errorf("Failed to reconcile /cvmfs: %v", err)
 		}
+
+		repairStaleTargets(staleTargets)
 	}
 
 	// Run at start so that broken mounts after nodeplugin Pod
@@ -67,37 +84,6 @@ func RunBlocking(o *Opts) error {
 	}
 }
 
-// List CVMFS mounts in /cvmfs that the kernel knows about.
-// We do that by listing mounts in /proc/self/mountinfo and filtering
-// those where the device is "fuse" and the mountpoint is rooted in /cvmfs.
-func getMountedRepositories() ([]string, error) {
-	cvmfsMountInfos, err := mountinfo.GetMounts(func(info *mountinfo.Info) (skip, stop bool) {
-		return info.FSType != "fuse" || !strings.HasPrefix(info.Mountpoint, mountPathPrefix),
-			false
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	repositories := make([]string, len(cvmfsMountInfos))
-
-	for i := range cvmfsMountInfos {
-		repositories[i] = cvmfsMountInfos[i].Mountpoint[len(mountPathPrefix):]
-	}
-
-	return repositories, nil
-}
-
-func doCvmfsTalk(repo, command string) ([]byte, error) {
-	return exec.CombinedOutput(
-		goexec.Command(
-			"cvmfs_talk",
-			"-i", repo,
-			command,
-		),
-	)
-}
-
 // repoNeedsUnmount checks if a /cvmfs/<repo> mountpoint is healthy.
 // Because mounts under /cvmfs are managed by autofs, we cannot check
 // them directly (with a stat() for example), as this would trigger
@@ -105,7 +91,7 @@ func doCvmfsTalk(repo, command string) ([]byte, error) {
 // for CVMFS client, and only if this fails with "Connection refused",
 // we use stat("/cvmfs/<repo>") to check the mount.
 func repoNeedsUnmount(repo string) (bool, error) {
-	out, err := doCvmfsTalk(repo, "mountpoint")
+	out, err := talk.Repository(repo, "mountpoint")
 	if err == nil {
 		if bytes.HasPrefix(out, []byte(mountPathPrefix)) {
 			return false, nil
@@ -119,13 +105,7 @@ func repoNeedsUnmount(repo string) (bool, error) {
 
 	// The CVMFS client exited unexpectedly, and the watchdog
 	// didn't remount it automatically.
-	const cvmfsErrConnRefused = "(111 - Connection refused)\x0A"
-	const cvmfsErrClientNotRunning = "Seems like CernVM-FS is not running"
-
-	outputHasKnownErr := bytes.HasSuffix(out, []byte(cvmfsErrConnRefused)) ||
-		bytes.HasPrefix(out, []byte(cvmfsErrClientNotRunning))
-
-	if !outputHasKnownErr {
+	if !talk.ClientNotRunning(out) {
 		return false, fmt.Errorf("failed to talk to CVMFS client (%v): %s", err, out)
 	}
 
@@ -135,11 +115,15 @@ func repoNeedsUnmount(repo string) (bool, error) {
 func reconcile() error {
 	// List mounted CVMFS repositories in /cvmfs.
 
-	mountedRepos, err := getMountedRepositories()
+	reconcileRuns.Inc()
+
+	mountedRepos, err := automount.MountedRepositories()
 	if err != nil {
 		return err
 	}
 
+	mountedRepositories.Set(float64(len(mountedRepos)))
+
 	log.Tracef("CVMFS mounts in /cvmfs: %v", mountedRepos)
 
 	// Check each mountpoint we found above. In case it's corrupted,
@@ -152,6 +136,7 @@ func reconcile() error {
 
 		if err != nil {
 			log.Errorf("Failed to reconcile %s: %v", mountpoint, err)
+			reconcileFailures.Inc()
 			continue
 		}
 
@@ -160,8 +145,11 @@ func reconcile() error {
 
 			if err := mountutils.Unmount(mountpoint); err != nil {
 				log.Errorf("Failed to unmount %s during mount reconciliation: %v", mountpoint, err)
+				reconcileFailures.Inc()
 				continue
 			}
+
+			reconcileRepairs.Inc()
 		}
 	}
 
@@ -0,0 +1,164 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package mountreconcile
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/node"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
+
+	"github.com/moby/sys/mountinfo"
+)
+
+// staleTarget is a stale CVMFS mount in a volume target path,
+// together with the publish record of the volume.
+type staleTarget struct {
+	mountpoint string
+	rec        *node.PublishRecord
+}
+
+// staleTargetMounts returns CVMFS mounts in podsDir whose CVMFS client
+// is gone, together with their parent mounts. These are bindmounts of
+// /cvmfs/<repo> made when publishing a volume, which kept the old mount
+// after the repository was remounted. Mounts inside of bindmounts of the
+// whole autofs-CVMFS root are skipped, as those receive unmount events
+// from /cvmfs. mounts are all mounts on the node.
+func staleTargetMounts(podsDir string, mounts []*mountinfo.Info) (stale, parents []*mountinfo.Info) {
+	prefix := path.Clean(podsDir) + "/"
+
+	infosByID := make(map[int]*mountinfo.Info, len(mounts))
+	for _, info := range mounts {
+		infosByID[info.ID] = info
+	}
+
+	for _, info := range mounts {
+		if info.FSType != "fuse" || !strings.HasPrefix(info.Mountpoint, prefix) {
+			continue
+		}
+
+		// Mounts nested in another mount in podsDir are expected only
+		// in restricted CVMFS roots.
+		parent := infosByID[info.Parent]
+		if parent != nil && strings.HasPrefix(parent.Mountpoint, prefix) {
+			if parent.Source != node.RestrictedRootSource {
+				continue
+			}
+		} else {
+			parent = nil
+		}
+
+		mntState, err := mountutils.GetState(info.Mountpoint)
+		if err != nil {
+			log.Debugf("Failed to probe %s: %v", info.Mountpoint, err)
+			continue
+		}
+
+		if mntState == mountutils.StCorrupted {
+			stale = append(stale, info)
+			parents = append(parents, parent)
+		}
+	}
+
+	return stale, parents
+}
+
+// findStaleTargets finds stale CVMFS mounts in volume target paths
+// in podsDir that can be re-bound from /cvmfs. Only volumes mounted from
+// the autofs-CVMFS root are handled, volumes mounted by singlemount-runner
+// are left alone. Volumes without a publish record, or with a record
+// written by an older version, are recognized by matching the stale mount
+// with the dead mount in /cvmfs, and so this must run before reconcile()
+// unmounts it.
+func findStaleTargets(podsDir string) ([]staleTarget, error) {
+	mounts, err := mountinfo.GetMounts(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stale, parents := staleTargetMounts(podsDir, mounts)
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	recs, err := node.ListPublishRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	recsByTarget := make(map[string]*node.PublishRecord, len(recs))
+	for _, rec := range recs {
+		recsByTarget[rec.TargetPath] = rec
+	}
+
+	var targets []staleTarget
+
+	for i, info := range stale {
+		// The stale mount is either in the target path itself,
+		// or in a repository directory of a restricted CVMFS root.
+
+		rec := recsByTarget[info.Mountpoint]
+		if rec == nil {
+			rec = recsByTarget[path.Dir(info.Mountpoint)]
+		}
+
+		if rec == nil || !rec.Automounted {
+			recovered, err := node.RecoverStaleMountRecord(info, parents[i], mounts, rec)
+			if err != nil {
+				log.Errorf("Failed to recover publish record of stale mount %s: %v", info.Mountpoint, err)
+				targetRepairFailures.Inc()
+				continue
+			}
+
+			if recovered == nil {
+				if rec != nil {
+					log.Infof("Skipping stale mount %s: volume %s is not published from /cvmfs", info.Mountpoint, rec.VolumeID)
+				} else {
+					log.Warningf("Skipping stale mount %s: it has no publish record and doesn't match any mount in /cvmfs, "+
+						"the Pod needs to be restarted", info.Mountpoint)
+				}
+
+				continue
+			}
+
+			rec = recovered
+		}
+
+		targets = append(targets, staleTarget{mountpoint: info.Mountpoint, rec: rec})
+	}
+
+	return targets, nil
+}
+
+// repairStaleTargets re-binds stale mounts in volume target
+// paths from /cvmfs, once /cvmfs is reconciled.
+func repairStaleTargets(targets []staleTarget) {
+	for _, t := range targets {
+		log.Infof("%s is corrupted, re-binding it from /cvmfs", t.mountpoint)
+
+		if err := node.RepairStaleMount(context.Background(), t.rec, t.mountpoint); err != nil {
+			log.Errorf("Failed to repair stale mount %s in volume %s: %v", t.mountpoint, t.rec.VolumeID, err)
+			targetRepairFailures.Inc()
+			continue
+		}
+
+		targetRepairs.Inc()
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package cache manages the local CVMFS client cache shared by
+// autofs-managed CVMFS mounts: it reports cache usage, evicts and pins
+// cached files, and shrinks the cache when its volume is running full.
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/talk"
+)
+
+// VolumeUsage describes the filesystem holding the local cache.
+type VolumeUsage struct {
+	TotalBytes uint64 `json:"totalBytes"`
+	UsedBytes  uint64 `json:"usedBytes"`
+}
+
+// RepositoryUsage describes the cache of a mounted repository,
+// as reported by its CVMFS client. When CVMFS_SHARED_CACHE is enabled
+// (the default), cache size is shared by all repositories.
+type RepositoryUsage struct {
+	Repository  string `json:"repository"`
+	SizeBytes   int64  `json:"sizeBytes"`
+	PinnedBytes int64  `json:"pinnedBytes"`
+
+	// Number of cached objects listed by "cache list".
+	Entries int `json:"entries"`
+
+	// Cached catalogs of the repository listed by "cache list catalogs".
+	Catalogs []string `json:"catalogs,omitempty"`
+
+	// Error is set if the CVMFS client couldn't be queried.
+	Error string `json:"error,omitempty"`
+}
+
+type Usage struct {
+	CacheDir     string            `json:"cacheDir"`
+	Volume       VolumeUsage       `json:"volume"`
+	Repositories []RepositoryUsage `json:"repositories"`
+}
+
+// UsedRatio returns used to total volume space ratio.
+func (v *VolumeUsage) UsedRatio() float64 {
+	if v.TotalBytes == 0 {
+		return 0
+	}
+
+	return float64(v.UsedBytes) / float64(v.TotalBytes)
+}
+
+var (
+	// ErrRepositoryNotMounted is returned when a repository is not
+	// currently mounted in /cvmfs, and so there is no CVMFS client
+	// to talk to.
+	ErrRepositoryNotMounted = errors.New("repository is not mounted")
+
+	// Matches cvmfs_talk "cache size" output, e.g.:
+	//   Current cache size is 1234MB (1293892342 Bytes), pinned: 345MB (362123123 Bytes)
+	cacheSizeRe = regexp.MustCompile(`\((\d+) Bytes\), pinned: \d+MB \((\d+) Bytes\)`)
+)
+
+func getVolumeUsage(cacheDir string) (VolumeUsage, error) {
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(cacheDir, &statfs); err != nil {
+		return VolumeUsage{}, fmt.Errorf("failed to stat cache volume %s: %v", cacheDir, err)
+	}
+
+	total := statfs.Blocks * uint64(statfs.Bsize)
+	free := statfs.Bfree * uint64(statfs.Bsize)
+
+	return VolumeUsage{
+		TotalBytes: total,
+		UsedBytes:  total - free,
+	}, nil
+}
+
+func talkRepository(repo string, command ...string) (string, error) {
+	out, err := talk.Repository(repo, strings.Join(command, " "))
+	if err != nil {
+		return "", fmt.Errorf("cvmfs_talk %s failed (%v): %s", command[0], err, bytes.TrimSpace(out))
+	}
+
+	return string(bytes.TrimSpace(out)), nil
+}
+
+func cacheSize(repo string) (size, pinned int64, err error) {
+	out, err := talkRepository(repo, "cache", "size")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	m := cacheSizeRe.FindStringSubmatch(out)
+	if m == nil {
+		return 0, 0, fmt.Errorf("unexpected output of cvmfs_talk cache size: %s", out)
+	}
+
+	size, _ = strconv.ParseInt(m[1], 10, 64)
+	pinned, _ = strconv.ParseInt(m[2], 10, 64)
+
+	return size, pinned, nil
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func getRepositoryUsage(repo string) RepositoryUsage {
+	usage := RepositoryUsage{Repository: repo}
+
+	var err error
+	if usage.SizeBytes, usage.PinnedBytes, err = cacheSize(repo); err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
+
+	entries, err := talkRepository(repo, "cache", "list")
+	if err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
+	usage.Entries = len(nonEmptyLines(entries))
+
+	catalogs, err := talkRepository(repo, "cache", "list", "catalogs")
+	if err != nil {
+		usage.Error = err.Error()
+		return usage
+	}
+
+	// With shared cache, catalogs of all repositories are listed.
+	for _, catalog := range nonEmptyLines(catalogs) {
+		if strings.Contains(catalog, repo+":") {
+			usage.Catalogs = append(usage.Catalogs, catalog)
+		}
+	}
+
+	return usage
+}
+
+// GetUsage reports usage of the local cache in cacheDir, and
+// of the cache of each of repos.
+func GetUsage(cacheDir string, repos []string) (*Usage, error) {
+	volume, err := getVolumeUsage(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &Usage{
+		CacheDir:     cacheDir,
+		Volume:       volume,
+		Repositories: make([]RepositoryUsage, len(repos)),
+	}
+
+	for i, repo := range repos {
+		usage.Repositories[i] = getRepositoryUsage(repo)
+	}
+
+	sort.Slice(usage.Repositories, func(i, j int) bool {
+		return usage.Repositories[i].Repository < usage.Repositories[j].Repository
+	})
+
+	return usage, nil
+}
+
+func validateRepositoryPath(p string) error {
+	if !path.IsAbs(p) || path.Clean(p) != p {
+		return fmt.Errorf("path %q must be an absolute, clean path inside the repository", p)
+	}
+
+	return nil
+}
+
+// Evict removes a file at path p inside repository repo from the cache.
+func Evict(repo, p string) (string, error) {
+	if err := validateRepositoryPath(p); err != nil {
+		return "", err
+	}
+
+	return talkRepository(repo, "evict", p)
+}
+
+// Pin pins a file at path p inside repository repo in the cache, so
+// that it's not removed during cache cleanup.
+func Pin(repo, p string) (string, error) {
+	if err := validateRepositoryPath(p); err != nil {
+		return "", err
+	}
+
+	return talkRepository(repo, "pin", p)
+}
+
+// Cleanup asks the CVMFS client serving repo to shrink
+// its cache down to targetBytes.
+func Cleanup(repo string, targetBytes int64) (string, error) {
+	if targetBytes < 0 {
+		return "", fmt.Errorf("cleanup target must not be negative, got %d", targetBytes)
+	}
+
+	const mb = 1 << 20
+	return talkRepository(repo, "cleanup", strconv.FormatInt(targetBytes/mb, 10))
+}
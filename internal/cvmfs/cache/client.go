@@ -0,0 +1,116 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Client talks to the cache admin API served by Start.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewClient returns a client of the cache admin API
+// served on unix://<absolute path to socket>.
+func NewClient(endpoint string) (*Client, error) {
+	socketPath, ok := strings.CutPrefix(endpoint, unixDomainSocketScheme)
+	if !ok || socketPath == "" {
+		return nil, fmt.Errorf("invalid cache admin endpoint %s: expected %s<absolute path to socket>",
+			endpoint, unixDomainSocketScheme)
+	}
+
+	return &Client{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+		// Host is ignored when dialing the UNIX domain socket.
+		baseURL: "http://localhost",
+	}, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, req *Request, resp any) error {
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return err
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		var errResp Response
+		if err = json.NewDecoder(httpResp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			return fmt.Errorf("cache admin API request failed: %s", httpResp.Status)
+		}
+		return fmt.Errorf("cache admin API request failed: %s", errResp.Error)
+	}
+
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+func (c *Client) Usage(ctx context.Context) (*Usage, error) {
+	var usage Usage
+	if err := c.do(ctx, http.MethodGet, usagePath, nil, &usage); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+func (c *Client) Evict(ctx context.Context, repo, p string) (*Response, error) {
+	var resp Response
+	err := c.do(ctx, http.MethodPost, evictPath, &Request{Repository: repo, Path: p}, &resp)
+	return &resp, err
+}
+
+func (c *Client) Pin(ctx context.Context, repo, p string) (*Response, error) {
+	var resp Response
+	err := c.do(ctx, http.MethodPost, pinPath, &Request{Repository: repo, Path: p}, &resp)
+	return &resp, err
+}
+
+// Cleanup shrinks the cache of repo down to targetBytes.
+// If repo is empty, caches of all mounted repositories are cleaned up.
+func (c *Client) Cleanup(ctx context.Context, repo string, targetBytes int64) (*Response, error) {
+	var resp Response
+	err := c.do(ctx, http.MethodPost, cleanupPath, &Request{Repository: repo, TargetBytes: targetBytes}, &resp)
+	return &resp, err
+}
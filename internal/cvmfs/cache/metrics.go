@@ -0,0 +1,83 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cache
+
+import (
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "cache"
+
+var (
+	volumeUsedRatio = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "volume_used_ratio",
+			Help:      "Ratio of used to total space of the local cache volume.",
+		},
+	)
+
+	watermarkCleanups = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "watermark_cleanups_total",
+			Help:      "Number of cache cleanups triggered by crossing the high watermark.",
+		},
+	)
+
+	watermarkCleanupFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "watermark_cleanup_failures_total",
+			Help:      "Number of failed cache watermark checks and cleanups.",
+		},
+	)
+
+	watermarkFreedBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "watermark_freed_bytes_total",
+			Help:      "Number of bytes freed on the cache volume by watermark cleanups.",
+		},
+	)
+
+	adminRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "admin_requests_total",
+			Help:      "Number of handled cache admin API requests.",
+		},
+		[]string{"operation", "code"},
+	)
+)
+
+func registerMetrics() {
+	metrics.MustRegister(
+		volumeUsedRatio,
+		watermarkCleanups,
+		watermarkCleanupFailures,
+		watermarkFreedBytes,
+		adminRequests,
+	)
+}
@@ -0,0 +1,340 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+)
+
+type Opts struct {
+	// Location of the local cache.
+	CacheDir string
+
+	// Endpoint to serve the cache admin API on, as
+	// unix://<absolute path to socket>. The API is not authenticated,
+	// and so it's served only on UNIX domain sockets. The API is
+	// disabled if empty.
+	AdminEndpoint string
+
+	// Usage ratio of the cache volume (0-1) at which the cache is cleaned
+	// up, down to LowWatermark. Watermark cleanup is disabled if zero.
+	HighWatermark float64
+	LowWatermark  float64
+
+	// How often to check cache volume usage against HighWatermark.
+	WatermarkCheckPeriod time.Duration
+}
+
+// Request is the body of cache admin API POST requests.
+type Request struct {
+	Repository string `json:"repository,omitempty"`
+
+	// Path inside the repository to evict or pin.
+	Path string `json:"path,omitempty"`
+
+	// Size in bytes to shrink the cache down to.
+	TargetBytes int64 `json:"targetBytes,omitempty"`
+}
+
+// Response is the body of cache admin API POST responses.
+type Response struct {
+	// Output of cvmfs_talk, for each repository.
+	Output map[string]string `json:"output,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+const (
+	unixDomainSocketScheme = "unix://"
+
+	usagePath   = "/v1/usage"
+	evictPath   = "/v1/evict"
+	pinPath     = "/v1/pin"
+	cleanupPath = "/v1/cleanup"
+)
+
+type manager struct {
+	cacheDir string
+
+	highWatermark float64
+	lowWatermark  float64
+
+	// Serializes operations modifying the cache.
+	mu sync.Mutex
+}
+
+func (o *Opts) validate() error {
+	if o.HighWatermark == 0 {
+		return nil
+	}
+
+	if o.HighWatermark < 0 || o.HighWatermark > 1 || o.LowWatermark < 0 || o.LowWatermark >= o.HighWatermark {
+		return fmt.Errorf("cache watermarks must satisfy 0 <= low < high <= 1, got low=%v high=%v",
+			o.LowWatermark, o.HighWatermark)
+	}
+
+	if o.WatermarkCheckPeriod <= 0 {
+		return errors.New("cache watermark check period must be positive")
+	}
+
+	return nil
+}
+
+// Start starts serving the cache admin API and watermark
+// cleanup in background, as set in Opts.
+func Start(o *Opts) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	registerMetrics()
+
+	m := &manager{
+		cacheDir:      o.CacheDir,
+		highWatermark: o.HighWatermark,
+		lowWatermark:  o.LowWatermark,
+	}
+
+	if o.AdminEndpoint != "" {
+		listener, err := listen(o.AdminEndpoint)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Serving cache admin API on %s", listener.Addr())
+
+		go func() {
+			if err := http.Serve(listener, m.handler()); err != nil {
+				log.Fatalf("Failed to serve cache admin API: %v", err)
+			}
+		}()
+	}
+
+	if o.HighWatermark > 0 {
+		log.Infof("Checking cache volume usage every %s, high watermark %v, low watermark %v",
+			o.WatermarkCheckPeriod, o.HighWatermark, o.LowWatermark)
+
+		go m.runWatermarkCleanup(o.WatermarkCheckPeriod)
+	}
+
+	return nil
+}
+
+func listen(endpoint string) (net.Listener, error) {
+	socketPath, ok := strings.CutPrefix(endpoint, unixDomainSocketScheme)
+	if !ok || !path.IsAbs(socketPath) {
+		return nil, fmt.Errorf("invalid cache admin endpoint %s: expected %s<absolute path to socket>",
+			endpoint, unixDomainSocketScheme)
+	}
+
+	// Remove a stale socket left behind by a previous run.
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove existing UNIX domain socket %s: %v", socketPath, err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", endpoint, err)
+	}
+
+	return listener, nil
+}
+
+func (m *manager) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+usagePath, m.handleUsage)
+	mux.HandleFunc("POST "+evictPath, m.handleRepositoryPath("evict", Evict))
+	mux.HandleFunc("POST "+pinPath, m.handleRepositoryPath("pin", Pin))
+	mux.HandleFunc("POST "+cleanupPath, m.handleCleanup)
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, operation string, code int, v any) {
+	adminRequests.WithLabelValues(operation, strconv.Itoa(code)).Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to write cache admin API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, operation string, code int, err error) {
+	log.ErrorS(err, "Cache admin API request failed", "operation", operation)
+	writeJSON(w, operation, code, &Response{Error: err.Error()})
+}
+
+func (m *manager) handleUsage(w http.ResponseWriter, r *http.Request) {
+	const operation = "usage"
+
+	repos, err := automount.MountedRepositories()
+	if err != nil {
+		writeError(w, operation, http.StatusInternalServerError,
+			fmt.Errorf("failed to list mounted repositories: %v", err))
+		return
+	}
+
+	usage, err := GetUsage(m.cacheDir, repos)
+	if err != nil {
+		writeError(w, operation, http.StatusInternalServerError, err)
+		return
+	}
+
+	volumeUsedRatio.Set(usage.Volume.UsedRatio())
+
+	writeJSON(w, operation, http.StatusOK, usage)
+}
+
+func readRequest(r *http.Request) (*Request, error) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("failed to parse request: %v", err)
+	}
+
+	return &req, nil
+}
+
+// checkMounted returns ErrRepositoryNotMounted if there is no
+// CVMFS client serving repo in /cvmfs.
+func checkMounted(repo string) error {
+	repos, err := automount.MountedRepositories()
+	if err != nil {
+		return fmt.Errorf("failed to list mounted repositories: %v", err)
+	}
+
+	if !slices.Contains(repos, repo) {
+		return fmt.Errorf("%w: %s", ErrRepositoryNotMounted, repo)
+	}
+
+	return nil
+}
+
+func errorCode(err error) int {
+	if errors.Is(err, ErrRepositoryNotMounted) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+func (m *manager) handleRepositoryPath(
+	operation string,
+	do func(repo, p string) (string, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := readRequest(r)
+		if err != nil {
+			writeError(w, operation, http.StatusBadRequest, err)
+			return
+		}
+
+		if req.Repository == "" {
+			writeError(w, operation, http.StatusBadRequest, errors.New("repository must be set"))
+			return
+		}
+
+		if err = validateRepositoryPath(req.Path); err != nil {
+			writeError(w, operation, http.StatusBadRequest, err)
+			return
+		}
+
+		if err = checkMounted(req.Repository); err != nil {
+			writeError(w, operation, errorCode(err), err)
+			return
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		log.InfoS("Cache admin request", "operation", operation, log.KeyRepository, req.Repository, "path", req.Path)
+
+		out, err := do(req.Repository, req.Path)
+		if err != nil {
+			writeError(w, operation, http.StatusInternalServerError, err)
+			return
+		}
+
+		writeJSON(w, operation, http.StatusOK, &Response{
+			Output: map[string]string{req.Repository: out},
+		})
+	}
+}
+
+func (m *manager) handleCleanup(w http.ResponseWriter, r *http.Request) {
+	const operation = "cleanup"
+
+	req, err := readRequest(r)
+	if err != nil {
+		writeError(w, operation, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.TargetBytes < 0 {
+		writeError(w, operation, http.StatusBadRequest, errors.New("targetBytes must not be negative"))
+		return
+	}
+
+	// Clean up the cache of the requested repository,
+	// or of all mounted repositories.
+
+	repos := []string{req.Repository}
+
+	if req.Repository != "" {
+		err = checkMounted(req.Repository)
+	} else {
+		repos, err = automount.MountedRepositories()
+	}
+	if err != nil {
+		writeError(w, operation, errorCode(err), err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp := &Response{Output: make(map[string]string, len(repos))}
+
+	for _, repo := range repos {
+		log.InfoS("Cache admin request", "operation", operation, log.KeyRepository, repo, "targetBytes", req.TargetBytes)
+
+		out, err := Cleanup(repo, req.TargetBytes)
+		if err != nil {
+			writeError(w, operation, http.StatusInternalServerError, err)
+			return
+		}
+
+		resp.Output[repo] = out
+	}
+
+	writeJSON(w, operation, http.StatusOK, resp)
+}
@@ -0,0 +1,131 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/automount"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+)
+
+// runWatermarkCleanup periodically checks usage of the cache volume, and
+// when it crosses the high watermark, shrinks the cache until the usage
+// falls under the low watermark.
+func (m *manager) runWatermarkCleanup(period time.Duration) {
+	t := time.NewTicker(period)
+	defer t.Stop()
+
+	for range t.C {
+		if err := m.checkWatermark(); err != nil {
+			log.Errorf("Failed to check cache watermark: %v", err)
+			watermarkCleanupFailures.Inc()
+		}
+	}
+}
+
+func (m *manager) checkWatermark() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	volume, err := getVolumeUsage(m.cacheDir)
+	if err != nil {
+		return err
+	}
+
+	volumeUsedRatio.Set(volume.UsedRatio())
+
+	if volume.UsedRatio() < m.highWatermark {
+		return nil
+	}
+
+	log.InfoS("Cache volume usage crossed high watermark, cleaning up cache",
+		"cacheDir", m.cacheDir,
+		"usedBytes", volume.UsedBytes,
+		"totalBytes", volume.TotalBytes,
+		"highWatermark", m.highWatermark,
+		"lowWatermark", m.lowWatermark,
+	)
+
+	watermarkCleanups.Inc()
+
+	repos, err := automount.MountedRepositories()
+	if err != nil {
+		return fmt.Errorf("failed to list mounted repositories: %v", err)
+	}
+
+	// Shrink the largest caches first. With shared cache, the first
+	// cleanup applies to all repositories, and is normally enough.
+
+	type repoCache struct {
+		repo string
+		size int64
+	}
+
+	caches := make([]repoCache, 0, len(repos))
+	for _, repo := range repos {
+		size, _, err := cacheSize(repo)
+		if err != nil {
+			log.ErrorS(err, "Failed to get cache size", log.KeyRepository, repo)
+			continue
+		}
+		caches = append(caches, repoCache{repo: repo, size: size})
+	}
+
+	sort.Slice(caches, func(i, j int) bool {
+		return caches[i].size > caches[j].size
+	})
+
+	usedBefore := volume.UsedBytes
+
+	for _, c := range caches {
+		toFree := int64(volume.UsedBytes) - int64(m.lowWatermark*float64(volume.TotalBytes))
+		if toFree <= 0 {
+			break
+		}
+
+		target := max(c.size-toFree, 0)
+
+		log.InfoS("Cleaning up cache", log.KeyRepository, c.repo, "sizeBytes", c.size, "targetBytes", target)
+
+		if _, err := Cleanup(c.repo, target); err != nil {
+			log.ErrorS(err, "Failed to clean up cache", log.KeyRepository, c.repo)
+			continue
+		}
+
+		if volume, err = getVolumeUsage(m.cacheDir); err != nil {
+			return err
+		}
+	}
+
+	volumeUsedRatio.Set(volume.UsedRatio())
+
+	if usedBefore > volume.UsedBytes {
+		watermarkFreedBytes.Add(float64(usedBefore - volume.UsedBytes))
+	}
+
+	if volume.UsedRatio() >= m.highWatermark {
+		return fmt.Errorf("cache volume usage is still above high watermark after cleanup: %d of %d bytes used",
+			volume.UsedBytes, volume.TotalBytes)
+	}
+
+	log.InfoS("Cache cleanup finished", "cacheDir", m.cacheDir, "usedBytes", volume.UsedBytes, "totalBytes", volume.TotalBytes)
+
+	return nil
+}
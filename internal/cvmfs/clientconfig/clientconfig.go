@@ -0,0 +1,221 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package clientconfig parses and validates CVMFS client configuration
+// supplied with volumes, and merges it with the node's default configuration.
+//
+// Volume configuration is layered on top of node defaults, later layers
+// taking precedence:
+//
+//  1. Node defaults: the default configuration of the repository in
+//     /etc/cvmfs of singlemount-runner, and in the config repository
+//     (CVMFS_CONFIG_REPOSITORY).
+//  2. clientConfig or clientConfigFilepath volume parameter, typically
+//     set in the StorageClass.
+//  3. clientConfigOverrides volume parameter, and parameters derived
+//     from other volume parameters (e.g. snapshot tag).
+//
+// Unlike node defaults, volume configuration may contain only simple
+// KEY=VALUE assignments of known CVMFS parameters.
+package clientconfig
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// OverridesKey is the volume parameter with per-volume
+// config parameters, in KEY=VALUE lines.
+const OverridesKey = "clientConfigOverrides"
+
+type Parameter struct {
+	Name  string
+	Value string
+}
+
+// ValidateOpts adjusts validation of volume configuration.
+type ValidateOpts struct {
+	// Parameter names accepted in addition to the known CVMFS parameters.
+	ExtraParameters []string
+
+	// Directories that parameters holding paths (e.g. CVMFS_CACHE_BASE)
+	// may point into.
+	AllowedPaths []string
+}
+
+func parseLine(line string) (p Parameter, skip bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Parameter{}, true, nil
+	}
+
+	line = strings.TrimPrefix(line, "export ")
+
+	name, value, ok := strings.Cut(line, "=")
+	if !ok || !parameterNameRegexp.MatchString(name) {
+		return Parameter{}, false, fmt.Errorf("expected CVMFS_<NAME>=<VALUE> assignment, got %q", line)
+	}
+
+	// The config is sourced by a shell. Values with shell
+	// metacharacters must be quoted.
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	} else if strings.ContainsAny(value, unquotedValueForbiddenChars) {
+		return Parameter{}, false, fmt.Errorf("value of %s must be quoted", name)
+	}
+
+	return Parameter{Name: name, Value: value}, false, nil
+}
+
+// Parse parses volume configuration. Each line must be empty, a comment,
+// or a CVMFS_<NAME>=<VALUE> assignment, optionally prefixed with "export".
+func Parse(config string) ([]Parameter, error) {
+	var params []Parameter
+
+	for i, line := range strings.Split(config, "\n") {
+		p, skip, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		if !skip {
+			params = append(params, p)
+		}
+	}
+
+	return params, nil
+}
+
+// ParseMap parses volume configuration into a map. Parameters
+// defined later take precedence.
+func ParseMap(config string) (map[string]string, error) {
+	params, err := Parse(config)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string, len(params))
+	for _, p := range params {
+		m[p.Name] = p.Value
+	}
+
+	return m, nil
+}
+
+// Effective returns parameters set by config, which may be a concatenation
+// of all layers, with later assignments taking precedence. Lines that are
+// not simple assignments (e.g. shell conditionals in node defaults)
+// are skipped.
+func Effective(config string) map[string]string {
+	m := make(map[string]string)
+
+	for _, line := range strings.Split(config, "\n") {
+		p, skip, err := parseLine(line)
+		if skip || err != nil {
+			continue
+		}
+
+		m[p.Name] = p.Value
+	}
+
+	return m
+}
+
+const (
+	// Characters that need quoting in the shell.
+	unquotedValueForbiddenChars = " \t\"'`\\;&|<>()"
+
+	// Characters not allowed in values even when quoted, as they
+	// could make the shell run commands, or break out of the quotes.
+	valueForbiddenChars = "\"'`\\\n\r"
+)
+
+// Quote returns value quoted for the shell if needed. The value
+// must have passed validation.
+func Quote(value string) string {
+	if strings.ContainsAny(value, unquotedValueForbiddenChars) {
+		return `"` + value + `"`
+	}
+
+	return value
+}
+
+func isSubpath(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+func validateParameter(p Parameter, o *ValidateOpts) error {
+	if !parameterNameRegexp.MatchString(p.Name) {
+		return fmt.Errorf("invalid parameter name %q", p.Name)
+	}
+
+	if strings.ContainsAny(p.Value, valueForbiddenChars) || strings.Contains(p.Value, "$(") {
+		return fmt.Errorf("value of parameter %s must not contain quotes, backslashes, line breaks or command substitutions", p.Name)
+	}
+
+	if reservedParameters[p.Name] {
+		return fmt.Errorf("parameter %s is set by CVMFS CSI and must not be overridden", p.Name)
+	}
+
+	if isForbiddenParameter(p.Name) {
+		return fmt.Errorf("parameter %s is not allowed in volume configuration", p.Name)
+	}
+
+	if !isKnownParameter(p.Name) && !slices.Contains(o.ExtraParameters, p.Name) {
+		if suggestion := suggestParameter(p.Name); suggestion != "" {
+			return fmt.Errorf("unknown parameter %s, did you mean %s?", p.Name, suggestion)
+		}
+		return fmt.Errorf("unknown parameter %s", p.Name)
+	}
+
+	if isPathParameter(p.Name) && p.Value != "" {
+		if !path.IsAbs(p.Value) || path.Clean(p.Value) != p.Value {
+			return fmt.Errorf("parameter %s must be an absolute, clean path, got %q", p.Name, p.Value)
+		}
+
+		if !slices.ContainsFunc(o.AllowedPaths, func(dir string) bool { return isSubpath(p.Value, dir) }) {
+			return fmt.Errorf("parameter %s must point into one of %v, got %q", p.Name, o.AllowedPaths, p.Value)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that params are known CVMFS parameters that
+// may be set in volume configuration.
+func Validate(params []Parameter, o *ValidateOpts) error {
+	for _, p := range params {
+		if err := validateParameter(p, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateMap is like Validate, but for parameters in a map.
+func ValidateMap(params map[string]string, o *ValidateOpts) error {
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		if err := validateParameter(Parameter{Name: name, Value: params[name]}, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package clientconfig
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    []Parameter
+		wantErr bool
+	}{
+		{name: "empty", config: "", want: nil},
+		{name: "blank lines and comments", config: "\n  \n# CVMFS_QUOTA_LIMIT=1\n\t# comment\n", want: nil},
+		{
+			name:   "assignments",
+			config: "CVMFS_QUOTA_LIMIT=4000\nCVMFS_HTTP_PROXY=DIRECT\n",
+			want: []Parameter{
+				{Name: "CVMFS_QUOTA_LIMIT", Value: "4000"},
+				{Name: "CVMFS_HTTP_PROXY", Value: "DIRECT"},
+			},
+		},
+		{
+			name:   "export prefix and surrounding whitespace",
+			config: "  export CVMFS_QUOTA_LIMIT=4000  ",
+			want:   []Parameter{{Name: "CVMFS_QUOTA_LIMIT", Value: "4000"}},
+		},
+		{
+			name:   "repeated parameter keeps order",
+			config: "CVMFS_QUOTA_LIMIT=1\nCVMFS_QUOTA_LIMIT=2",
+			want: []Parameter{
+				{Name: "CVMFS_QUOTA_LIMIT", Value: "1"},
+				{Name: "CVMFS_QUOTA_LIMIT", Value: "2"},
+			},
+		},
+		{name: "empty value", config: "CVMFS_HTTP_PROXY=", want: []Parameter{{Name: "CVMFS_HTTP_PROXY", Value: ""}}},
+		{
+			name:   "double quoted value",
+			config: `CVMFS_HTTP_PROXY="http://a:3128|http://b:3128;DIRECT"`,
+			want:   []Parameter{{Name: "CVMFS_HTTP_PROXY", Value: "http://a:3128|http://b:3128;DIRECT"}},
+		},
+		{
+			name:   "single quoted value",
+			config: `CVMFS_SERVER_URL='http://s1/cvmfs/@fqrn@ http://s2/cvmfs/@fqrn@'`,
+			want:   []Parameter{{Name: "CVMFS_SERVER_URL", Value: "http://s1/cvmfs/@fqrn@ http://s2/cvmfs/@fqrn@"}},
+		},
+		{
+			name:   "value containing equals sign",
+			config: "CVMFS_HTTP_PROXY=http://proxy?a=b",
+			want:   []Parameter{{Name: "CVMFS_HTTP_PROXY", Value: "http://proxy?a=b"}},
+		},
+		{name: "unquoted semicolon", config: "CVMFS_HTTP_PROXY=http://a:3128;DIRECT", wantErr: true},
+		{name: "unquoted pipe", config: "CVMFS_HTTP_PROXY=http://a:3128|http://b:3128", wantErr: true},
+		{name: "unquoted space", config: "CVMFS_SERVER_URL=http://s1 http://s2", wantErr: true},
+		{name: "unquoted command substitution", config: "CVMFS_HTTP_PROXY=$(id)", wantErr: true},
+		{name: "mismatched quotes", config: `CVMFS_HTTP_PROXY="DIRECT'`, wantErr: true},
+		{name: "missing assignment", config: "CVMFS_HTTP_PROXY", wantErr: true},
+		{name: "not a CVMFS parameter", config: "PATH=/tmp", wantErr: true},
+		{name: "shell statement", config: "if true; then\nCVMFS_QUOTA_LIMIT=1\nfi", wantErr: true},
+		{name: "error on later line", config: "CVMFS_QUOTA_LIMIT=1\n\nCVMFS_HTTP_PROXY=a b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	_, err := Parse("CVMFS_QUOTA_LIMIT=1\n\nCVMFS_HTTP_PROXY=a b")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if want := "line 3: value of CVMFS_HTTP_PROXY must be quoted"; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
+func TestParseMap(t *testing.T) {
+	got, err := ParseMap("CVMFS_QUOTA_LIMIT=1\nCVMFS_HTTP_PROXY=DIRECT\nCVMFS_QUOTA_LIMIT=2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{"CVMFS_QUOTA_LIMIT": "2", "CVMFS_HTTP_PROXY": "DIRECT"}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   map[string]string
+	}{
+		{name: "empty", config: "", want: map[string]string{}},
+		{
+			name:   "later layers take precedence",
+			config: "CVMFS_QUOTA_LIMIT=1\nCVMFS_HTTP_PROXY=DIRECT\nexport CVMFS_QUOTA_LIMIT=2",
+			want:   map[string]string{"CVMFS_QUOTA_LIMIT": "2", "CVMFS_HTTP_PROXY": "DIRECT"},
+		},
+		{
+			name:   "shell statements are skipped",
+			config: "if [ -z \"$X\" ]; then\n  CVMFS_QUOTA_LIMIT=1\nfi\nCVMFS_HTTP_PROXY=a;b\nPATH=/tmp",
+			want:   map[string]string{"CVMFS_QUOTA_LIMIT": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Effective(tt.config); !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	opts := &ValidateOpts{
+		ExtraParameters: []string{"CVMFS_SITE_SPECIFIC"},
+		AllowedPaths:    []string{"/cvmfs-localcache", "/var/lib/cvmfs/"},
+	}
+
+	tests := []struct {
+		name    string
+		param   Parameter
+		wantErr bool
+	}{
+		{name: "known parameter", param: Parameter{"CVMFS_QUOTA_LIMIT", "4000"}},
+		{name: "extra parameter", param: Parameter{"CVMFS_SITE_SPECIFIC", "1"}},
+		{name: "cache instance parameter", param: Parameter{"CVMFS_CACHE_myram_TYPE", "ram"}},
+		{name: "value with separators", param: Parameter{"CVMFS_HTTP_PROXY", "http://a:3128|http://b:3128;DIRECT"}},
+		{name: "allowed path", param: Parameter{"CVMFS_CACHE_BASE", "/cvmfs-localcache/atlas"}},
+		{name: "allowed path with trailing slash", param: Parameter{"CVMFS_CACHE_BASE", "/var/lib/cvmfs/x"}},
+		{name: "allowed directory itself", param: Parameter{"CVMFS_CACHE_BASE", "/cvmfs-localcache"}},
+		{name: "empty path", param: Parameter{"CVMFS_DEBUGLOG", ""}},
+		{name: "unknown parameter", param: Parameter{"CVMFS_QUOTA_LIMT", "4000"}, wantErr: true},
+		{name: "invalid name", param: Parameter{"QUOTA_LIMIT", "4000"}, wantErr: true},
+		{name: "reserved parameter", param: Parameter{"CVMFS_TALK_SOCKET", "/tmp/sock"}, wantErr: true},
+		{name: "forbidden parameter", param: Parameter{"CVMFS_AUTHZ_HELPER", "/bin/sh"}, wantErr: true},
+		{name: "forbidden cache instance parameter", param: Parameter{"CVMFS_CACHE_ext_CMDLINE", "/bin/sh"}, wantErr: true},
+		{name: "command substitution", param: Parameter{"CVMFS_HTTP_PROXY", "$(id)"}, wantErr: true},
+		{name: "quote", param: Parameter{"CVMFS_HTTP_PROXY", `a"b`}, wantErr: true},
+		{name: "line break", param: Parameter{"CVMFS_HTTP_PROXY", "a\nb"}, wantErr: true},
+		{name: "relative path", param: Parameter{"CVMFS_CACHE_BASE", "cache"}, wantErr: true},
+		{name: "unclean path", param: Parameter{"CVMFS_CACHE_BASE", "/cvmfs-localcache/../etc"}, wantErr: true},
+		{name: "path outside allowed directories", param: Parameter{"CVMFS_CACHE_BASE", "/etc"}, wantErr: true},
+		{name: "path sharing a prefix", param: Parameter{"CVMFS_CACHE_BASE", "/cvmfs-localcache2"}, wantErr: true},
+		{name: "cache instance path", param: Parameter{"CVMFS_CACHE_myposix_BASE", "/etc"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]Parameter{tt.param}, opts)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = ValidateMap(map[string]string{tt.param.Name: tt.param.Value}, opts)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("ValidateMap: got error %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "DIRECT", want: "DIRECT"},
+		{value: "http://a:3128;DIRECT", want: `"http://a:3128;DIRECT"`},
+		{value: "http://s1 http://s2", want: `"http://s1 http://s2"`},
+	}
+
+	for _, tt := range tests {
+		if got := Quote(tt.value); got != tt.want {
+			t.Errorf("Quote(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
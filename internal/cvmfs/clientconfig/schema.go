@@ -0,0 +1,238 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package clientconfig
+
+import (
+	"regexp"
+)
+
+var parameterNameRegexp = regexp.MustCompile(`^CVMFS_[A-Za-z0-9_]+$`)
+
+// Known CVMFS client parameters, see
+// https://cvmfs.readthedocs.io/en/stable/apx-parameters.html#client-parameters
+var knownParameters = makeSet(
+	"CVMFS_ALIEN_CACHE",
+	"CVMFS_ALT_ROOT_PATH",
+	"CVMFS_AUTO_UPDATE",
+	"CVMFS_AUTHZ_HELPER",
+	"CVMFS_AUTHZ_SEARCH_PATH",
+	"CVMFS_BACKOFF_INIT",
+	"CVMFS_BACKOFF_MAX",
+	"CVMFS_CACHE_BASE",
+	"CVMFS_CACHE_DIR",
+	"CVMFS_CACHE_PRIMARY",
+	"CVMFS_CACHE_REFCOUNT",
+	"CVMFS_CACHE_SYMLINKS",
+	"CVMFS_CATALOG_WATERMARK",
+	"CVMFS_CHECK_PERMISSIONS",
+	"CVMFS_CLAIM_OWNERSHIP",
+	"CVMFS_CLIENT_PROFILE",
+	"CVMFS_CONFIG_REPO_REQUIRED",
+	"CVMFS_CONFIG_REPOSITORY",
+	"CVMFS_CPU_AFFINITY",
+	"CVMFS_DEBUGLOG",
+	"CVMFS_DEFAULT_DOMAIN",
+	"CVMFS_DNS_MAX_TTL",
+	"CVMFS_DNS_MIN_TTL",
+	"CVMFS_DNS_RETRIES",
+	"CVMFS_DNS_ROAMING",
+	"CVMFS_DNS_SERVER",
+	"CVMFS_DNS_TIMEOUT",
+	"CVMFS_ENFORCE_ACLS",
+	"CVMFS_EXTERNAL_FALLBACK_PROXY",
+	"CVMFS_EXTERNAL_HTTP_PROXY",
+	"CVMFS_EXTERNAL_MAX_SERVERS",
+	"CVMFS_EXTERNAL_TIMEOUT",
+	"CVMFS_EXTERNAL_TIMEOUT_DIRECT",
+	"CVMFS_EXTERNAL_URL",
+	"CVMFS_FALLBACK_PROXY",
+	"CVMFS_FOLLOW_REDIRECTS",
+	"CVMFS_FUSE_NOTIFY_INVALIDATION",
+	"CVMFS_FUSE3_IDLE_THREADS",
+	"CVMFS_FUSE3_MAX_THREADS",
+	"CVMFS_GID_MAP",
+	"CVMFS_HIDE_MAGIC_XATTRS",
+	"CVMFS_HOST_RESET_AFTER",
+	"CVMFS_HTTP_PROXY",
+	"CVMFS_HTTP_TRACING",
+	"CVMFS_HTTP_TRACING_HEADERS",
+	"CVMFS_IGNORE_SIGNATURE",
+	"CVMFS_INITIAL_GENERATION",
+	"CVMFS_INSTRUMENT_FUSE",
+	"CVMFS_IPFAMILY_PREFER",
+	"CVMFS_KCACHE_TIMEOUT",
+	"CVMFS_KEYS_DIR",
+	"CVMFS_LOW_SPEED_LIMIT",
+	"CVMFS_MAGIC_XATTRS_VISIBILITY",
+	"CVMFS_MAX_EXTERNAL_SERVERS",
+	"CVMFS_MAX_IPADDR_PER_PROXY",
+	"CVMFS_MAX_RETRIES",
+	"CVMFS_MAX_SERVERS",
+	"CVMFS_MAX_TTL",
+	"CVMFS_MEMCACHE_SIZE",
+	"CVMFS_MOUNT_RW",
+	"CVMFS_NFILES",
+	"CVMFS_NFS_INTERLEAVED_INODES",
+	"CVMFS_NFS_SHARED",
+	"CVMFS_NFS_SOURCE",
+	"CVMFS_OOM_SCORE_ADJ",
+	"CVMFS_PAC_URLS",
+	"CVMFS_PROXY_RESET_AFTER",
+	"CVMFS_PROXY_SHARDING",
+	"CVMFS_PROXY_TEMPLATE",
+	"CVMFS_PUBLIC_KEY",
+	"CVMFS_QUOTA_LIMIT",
+	"CVMFS_RELOAD_SOCKETS",
+	"CVMFS_REPOSITORIES",
+	"CVMFS_REPOSITORY_DATE",
+	"CVMFS_REPOSITORY_TAG",
+	"CVMFS_ROOT_HASH",
+	"CVMFS_SEND_INFO_HEADER",
+	"CVMFS_SERVER_CACHE_MODE",
+	"CVMFS_SERVER_URL",
+	"CVMFS_SHARED_CACHE",
+	"CVMFS_STATFS_CACHE_TIMEOUT",
+	"CVMFS_STREAMING_CACHE",
+	"CVMFS_STRICT_MOUNT",
+	"CVMFS_SUID",
+	"CVMFS_SYSLOG_FACILITY",
+	"CVMFS_SYSLOG_LEVEL",
+	"CVMFS_SYSTEMD_NOKILL",
+	"CVMFS_TALK_OWNER",
+	"CVMFS_TALK_SOCKET",
+	"CVMFS_TELEMETRY_RATE",
+	"CVMFS_TELEMETRY_SEND",
+	"CVMFS_TIMEOUT",
+	"CVMFS_TIMEOUT_DIRECT",
+	"CVMFS_TRACEBUFFER",
+	"CVMFS_TRACEBUFFER_THRESHOLD",
+	"CVMFS_TRACEFILE",
+	"CVMFS_TRUSTED_CERTS",
+	"CVMFS_UID_MAP",
+	"CVMFS_USE_CDN",
+	"CVMFS_USE_GEOAPI",
+	"CVMFS_USE_SSL_SYSTEM_CA",
+	"CVMFS_USER",
+	"CVMFS_USYSLOG",
+	"CVMFS_WORKSPACE",
+	"CVMFS_WORLD_READABLE",
+	"CVMFS_XATTR_PRIVILEGED_GIDS",
+	"CVMFS_XATTR_PROTECTED_XATTRS",
+)
+
+// Parameters set by singlemount-runner for each mount.
+var reservedParameters = makeSet(
+	"CVMFS_RELOAD_SOCKETS",
+	"CVMFS_TALK_SOCKET",
+)
+
+// Parameters holding paths on the node, which must point
+// into allowed directories.
+var pathParameters = makeSet(
+	"CVMFS_ALIEN_CACHE",
+	"CVMFS_CACHE_BASE",
+	"CVMFS_CACHE_DIR",
+	"CVMFS_DEBUGLOG",
+	"CVMFS_TRACEFILE",
+	"CVMFS_USYSLOG",
+	"CVMFS_WORKSPACE",
+)
+
+var (
+	// Parameters of cache manager instances, CVMFS_CACHE_<instance>_<parameter>.
+	cacheInstanceParameterRegexp = regexp.MustCompile(
+		`^CVMFS_CACHE_[A-Za-z0-9]+_(TYPE|SHARED|ALIEN|BASE|DIR|WORKSPACE|QUOTA_LIMIT|UPPER|LOWER|LOWER_READONLY|CMDLINE|LOCATOR)$`)
+
+	// Cache instance parameters holding paths.
+	cacheInstancePathParameterRegexp = regexp.MustCompile(
+		`^CVMFS_CACHE_[A-Za-z0-9]+_(ALIEN|BASE|DIR|WORKSPACE)$`)
+
+	// Parameters making the client run arbitrary programs on the node.
+	forbiddenParameterRegexp = regexp.MustCompile(
+		`^(CVMFS_AUTHZ_HELPER|CVMFS_AUTHZ_SEARCH_PATH|CVMFS_CACHE_[A-Za-z0-9]+_(CMDLINE|LOCATOR))$`)
+)
+
+func makeSet(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+
+	return m
+}
+
+func isKnownParameter(name string) bool {
+	return knownParameters[name] || cacheInstanceParameterRegexp.MatchString(name)
+}
+
+func isPathParameter(name string) bool {
+	return pathParameters[name] || cacheInstancePathParameterRegexp.MatchString(name)
+}
+
+func isForbiddenParameter(name string) bool {
+	return forbiddenParameterRegexp.MatchString(name)
+}
+
+// suggestParameter returns a known parameter name close to name,
+// to help with typos. Returns empty string if there is none.
+func suggestParameter(name string) string {
+	const maxDistance = 2
+
+	var (
+		best         string
+		bestDistance = maxDistance + 1
+	)
+
+	for known := range knownParameters {
+		if d := editDistance(name, known); d < bestDistance || (d == bestDistance && known < best) {
+			best, bestDistance = known, d
+		}
+	}
+
+	if bestDistance > maxDistance {
+		return ""
+	}
+
+	return best
+}
+
+// editDistance returns Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
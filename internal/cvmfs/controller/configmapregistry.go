@@ -0,0 +1,194 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	configMapRegistryScheme = "configmap://"
+
+	// Key in the ConfigMap's data holding the JSON-encoded records.
+	configMapRegistryKey = "volumes.json"
+
+	configMapRegistryRequestTimeout = 30 * time.Second
+)
+
+// configMapRegistry stores volume records in a ConfigMap. Unlike fileRegistry,
+// the registry is shared by all controller plugin replicas, and survives Pod
+// restarts. Records are always read from the API server, and modifications
+// rely on ConfigMap's resourceVersion to detect concurrent updates. ConfigMaps
+// are limited to 1MiB, which is enough for tens of thousands of volumes.
+type configMapRegistry struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+func newConfigMapRegistry(namespace, name string) (*configMapRegistry, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster Kubernetes client config: %v", err)
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+
+	return &configMapRegistry{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}, nil
+}
+
+// load returns the registry ConfigMap and records stored in it.
+// The returned ConfigMap is nil if it doesn't exist yet.
+func (r *configMapRegistry) load(ctx context.Context) (*corev1.ConfigMap, map[string]*volumeRecord, error) {
+	records := make(map[string]*volumeRecord)
+
+	cm, err := r.client.CoreV1().ConfigMaps(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, records, nil
+		}
+
+		return nil, nil, fmt.Errorf("failed to get volume registry ConfigMap %s/%s: %v", r.namespace, r.name, err)
+	}
+
+	if data := cm.Data[configMapRegistryKey]; data != "" {
+		if err = json.Unmarshal([]byte(data), &records); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse volume registry ConfigMap %s/%s: %v", r.namespace, r.name, err)
+		}
+	}
+
+	return cm, records, nil
+}
+
+// modify applies fn on the stored records, and stores them back.
+// The whole operation is retried if the ConfigMap was modified
+// in the meantime by another replica.
+func (r *configMapRegistry) modify(fn func(records map[string]*volumeRecord) (modified bool)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), configMapRegistryRequestTimeout)
+	defer cancel()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, records, err := r.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !fn(records) {
+			return nil
+		}
+
+		jsonData, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+
+		if cm == nil {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      r.name,
+					Namespace: r.namespace,
+				},
+				Data: map[string]string{
+					configMapRegistryKey: string(jsonData),
+				},
+			}
+
+			_, err = r.client.CoreV1().ConfigMaps(r.namespace).Create(ctx, cm, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created by another replica, retry.
+				return apierrors.NewConflict(corev1.Resource("configmaps"), r.name, err)
+			}
+		} else {
+			if cm.Data == nil {
+				cm.Data = make(map[string]string)
+			}
+			cm.Data[configMapRegistryKey] = string(jsonData)
+
+			_, err = r.client.CoreV1().ConfigMaps(r.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+		}
+
+		return err
+	})
+}
+
+func (r *configMapRegistry) put(rec *volumeRecord) error {
+	return r.modify(func(records map[string]*volumeRecord) bool {
+		records[rec.VolumeID] = rec
+		return true
+	})
+}
+
+func (r *configMapRegistry) get(volumeID string) (*volumeRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), configMapRegistryRequestTimeout)
+	defer cancel()
+
+	_, records, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return records[volumeID], nil
+}
+
+func (r *configMapRegistry) delete(volumeID string) error {
+	return r.modify(func(records map[string]*volumeRecord) bool {
+		if _, ok := records[volumeID]; !ok {
+			return false
+		}
+
+		delete(records, volumeID)
+		return true
+	})
+}
+
+func (r *configMapRegistry) list() ([]*volumeRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), configMapRegistryRequestTimeout)
+	defer cancel()
+
+	_, records, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeIDs := slices.Sorted(maps.Keys(records))
+
+	recs := make([]*volumeRecord, len(volumeIDs))
+	for i := range volumeIDs {
+		recs[i] = records[volumeIDs[i]]
+	}
+
+	return recs, nil
+}
@@ -18,8 +18,18 @@ package controller
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/clientconfig"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/repolist"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/snapshot"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/subpath"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
@@ -30,15 +40,35 @@ import (
 type Server struct {
 	caps []*csi.ControllerServiceCapability
 	csi.UnimplementedControllerServer
+
+	// Registry of created volumes. Nil if volume registry is disabled,
+	// in which case ListVolumes and ControllerGetVolume are not supported.
+	registry volumeRegistry
 }
 
 var _ csi.ControllerServer = (*Server)(nil)
 
-func New() *Server {
+// New creates a new controller server. volumeRegistryURL selects
+// the backend of the volume registry. Volume registry is disabled
+// if volumeRegistryURL is empty.
+func New(volumeRegistryURL string) (*Server, error) {
 	enabledCaps := []csi.ControllerServiceCapability_RPC_Type{
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
 	}
 
+	var registry volumeRegistry
+	if volumeRegistryURL != "" {
+		var err error
+		if registry, err = newVolumeRegistry(volumeRegistryURL); err != nil {
+			return nil, fmt.Errorf("failed to initialize volume registry: %v", err)
+		}
+
+		enabledCaps = append(enabledCaps,
+			csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+			csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		)
+	}
+
 	var caps []*csi.ControllerServiceCapability
 	for _, c := range enabledCaps {
 		caps = append(caps, &csi.ControllerServiceCapability{
@@ -51,8 +81,9 @@ func New() *Server {
 	}
 
 	return &Server{
-		caps: caps,
-	}
+		caps:     caps,
+		registry: registry,
+	}, nil
 }
 
 func (srv *Server) CreateVolume(
@@ -63,11 +94,39 @@ func (srv *Server) CreateVolume(
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	vol := &csi.Volume{
+		VolumeId:      req.GetName(),
+		VolumeContext: req.GetParameters(),
+	}
+
+	if srv.registry != nil {
+		rec, err := srv.registry.get(vol.GetVolumeId())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"failed to look up volume %s in volume registry: %v", vol.GetVolumeId(), err)
+		}
+
+		if rec != nil {
+			if !maps.Equal(rec.VolumeContext, vol.GetVolumeContext()) {
+				return nil, status.Errorf(codes.AlreadyExists,
+					"volume %s already exists with different parameters", vol.GetVolumeId())
+			}
+		} else {
+			rec = &volumeRecord{
+				VolumeID:      vol.GetVolumeId(),
+				VolumeContext: vol.GetVolumeContext(),
+				CreatedAt:     time.Now().UTC(),
+			}
+
+			if err = srv.registry.put(rec); err != nil {
+				return nil, status.Errorf(codes.Internal,
+					"failed to store volume %s in volume registry: %v", vol.GetVolumeId(), err)
+			}
+		}
+	}
+
 	return &csi.CreateVolumeResponse{
-		Volume: &csi.Volume{
-			VolumeId:      req.GetName(),
-			VolumeContext: req.GetParameters(),
-		},
+		Volume: vol,
 	}, nil
 }
 
@@ -79,6 +138,13 @@ func (srv *Server) DeleteVolume(
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
+	if srv.registry != nil {
+		if err := srv.registry.delete(req.GetVolumeId()); err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"failed to delete volume %s from volume registry: %v", req.GetVolumeId(), err)
+		}
+	}
+
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
@@ -126,10 +192,54 @@ func (srv *Server) ValidateVolumeCapabilities(
 }
 
 func (srv *Server) ListVolumes(
-	context.Context,
-	*csi.ListVolumesRequest,
+	ctx context.Context,
+	req *csi.ListVolumesRequest,
 ) (*csi.ListVolumesResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if srv.registry == nil {
+		return nil, status.Error(codes.Unimplemented, "")
+	}
+
+	if err := validateListVolumesRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	recs, err := srv.registry.list()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list volume registry: %v", err)
+	}
+
+	// Records are sorted by volume ID. The starting token encodes the ID
+	// of the last volume returned in the previous page, so that the
+	// pagination is not affected by volumes deleted in the meantime.
+
+	if startingToken := req.GetStartingToken(); startingToken != "" {
+		lastVolumeID, err := decodeListVolumesToken(startingToken)
+		if err != nil {
+			return nil, status.Errorf(codes.Aborted, "invalid starting token: %v", err)
+		}
+
+		recs = recs[sort.Search(len(recs), func(i int) bool {
+			return recs[i].VolumeID > lastVolumeID
+		}):]
+	}
+
+	var nextToken string
+	if maxEntries := int(req.GetMaxEntries()); maxEntries > 0 && len(recs) > maxEntries {
+		recs = recs[:maxEntries]
+		nextToken = encodeListVolumesToken(recs[len(recs)-1].VolumeID)
+	}
+
+	entries := make([]*csi.ListVolumesResponse_Entry, len(recs))
+	for i, rec := range recs {
+		entries[i] = &csi.ListVolumesResponse_Entry{
+			Volume: rec.toCSIVolume(),
+		}
+	}
+
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: nextToken,
+	}, nil
 }
 
 func (srv *Server) GetCapacity(
@@ -177,10 +287,31 @@ func (srv *Server) ControllerExpandVolume(
 }
 
 func (srv *Server) ControllerGetVolume(
-	context.Context,
-	*csi.ControllerGetVolumeRequest,
+	ctx context.Context,
+	req *csi.ControllerGetVolumeRequest,
 ) (*csi.ControllerGetVolumeResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if srv.registry == nil {
+		return nil, status.Error(codes.Unimplemented, "")
+	}
+
+	if err := validateControllerGetVolumeRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	rec, err := srv.registry.get(req.GetVolumeId())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to look up volume %s in volume registry: %v", req.GetVolumeId(), err)
+	}
+
+	if rec == nil {
+		return nil, status.Errorf(codes.NotFound, "volume %s not found", req.GetVolumeId())
+	}
+
+	return &csi.ControllerGetVolumeResponse{
+		Volume: rec.toCSIVolume(),
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{},
+	}, nil
 }
 
 func (srv *Server) ControllerModifyVolume(
@@ -239,15 +370,78 @@ func validateValidateVolumeCapabilitiesRequest(req *csi.ValidateVolumeCapabiliti
 	return nil
 }
 
+func validateListVolumesRequest(req *csi.ListVolumesRequest) error {
+	if req.GetMaxEntries() < 0 {
+		return errors.New("max entries cannot be negative")
+	}
+
+	return nil
+}
+
+// ListVolumes tokens are base64-encoded volume IDs prefixed with
+// listVolumesTokenPrefix, so that tokens not issued by us are rejected.
+const listVolumesTokenPrefix = "v1:"
+
+func encodeListVolumesToken(lastVolumeID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(listVolumesTokenPrefix + lastVolumeID))
+}
+
+func decodeListVolumesToken(token string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+
+	lastVolumeID, ok := strings.CutPrefix(string(decoded), listVolumesTokenPrefix)
+	if !ok || lastVolumeID == "" {
+		return "", fmt.Errorf("token %s was not issued by this plugin", token)
+	}
+
+	return lastVolumeID, nil
+}
+
+func validateControllerGetVolumeRequest(req *csi.ControllerGetVolumeRequest) error {
+	if req.GetVolumeId() == "" {
+		return errors.New("volume ID missing in request")
+	}
+
+	return nil
+}
+
 func validateVolumeParameters(volParams map[string]string) error {
-	if len(volParams) > 0 {
-		unsupportedVolumeParams := []string{"hash", "tag"}
+	repositories, err := repolist.FromVolumeParameters(volParams)
+	if err != nil {
+		return err
+	}
 
-		for _, volParam := range unsupportedVolumeParams {
-			if _, ok := volParams[volParam]; ok {
-				return fmt.Errorf("volume parameter %s is not supported", volParam)
-			}
-		}
+	if repositories != nil && volParams["repository"] != "" {
+		return fmt.Errorf("only one of volume parameters repository and %s may be defined", repolist.Key)
+	}
+
+	snap, err := snapshot.FromVolumeParameters(volParams)
+	if err != nil {
+		return err
+	}
+
+	if snap != nil && volParams["repository"] == "" {
+		return fmt.Errorf("volume parameter repository must be set too when pinning a snapshot with %s, %s or %s",
+			snapshot.TagKey, snapshot.HashKey, snapshot.RevisionKey)
+	}
+
+	subPath, err := subpath.FromVolumeParameters(volParams)
+	if err != nil {
+		return err
+	}
+
+	if subPath != "" && volParams["repository"] == "" {
+		return fmt.Errorf("volume parameter repository must be set too when specifying %s", subpath.Key)
+	}
+
+	// Parameter names and values are validated by singlemount-runner,
+	// which knows which paths and extra parameters are allowed.
+	// Check only the syntax here.
+	if _, err = clientconfig.Parse(volParams[clientconfig.OverridesKey]); err != nil {
+		return fmt.Errorf("invalid volume parameter %s: %v", clientconfig.OverridesKey, err)
 	}
 
 	return nil
@@ -0,0 +1,221 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+type (
+	// volumeRecord describes a volume created by CreateVolume.
+	volumeRecord struct {
+		VolumeID      string
+		VolumeContext map[string]string
+		CreatedAt     time.Time
+	}
+
+	// volumeRegistry stores records of created volumes.
+	volumeRegistry interface {
+		// put stores rec, overwriting any existing record with the same volume ID.
+		put(rec *volumeRecord) error
+
+		// get returns record for volumeID, or nil if there is none.
+		get(volumeID string) (*volumeRecord, error)
+
+		// delete removes record for volumeID. Deleting a missing record is not an error.
+		delete(volumeID string) error
+
+		// list returns all records sorted by volume ID.
+		list() ([]*volumeRecord, error)
+	}
+)
+
+func (rec *volumeRecord) toCSIVolume() *csi.Volume {
+	return &csi.Volume{
+		VolumeId:      rec.VolumeID,
+		VolumeContext: rec.VolumeContext,
+	}
+}
+
+const (
+	fileRegistryScheme = "file://"
+)
+
+// newVolumeRegistry creates a volume registry backend from its URL.
+// Supported backends are:
+//
+//   - configmap://<namespace>/<name>: registry is stored in a ConfigMap,
+//     and is shared by all controller plugin replicas.
+//   - file://<absolute path to JSON file>: registry is stored in a local file.
+func newVolumeRegistry(registryURL string) (volumeRegistry, error) {
+	switch {
+	case strings.HasPrefix(registryURL, configMapRegistryScheme):
+		namespace, name, ok := strings.Cut(registryURL[len(configMapRegistryScheme):], "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("expected a ConfigMap registry URL configmap://<namespace>/<name>, got %s", registryURL)
+		}
+
+		return newConfigMapRegistry(namespace, name)
+	case strings.HasPrefix(registryURL, fileRegistryScheme):
+		filepath := registryURL[len(fileRegistryScheme):]
+		if !path.IsAbs(filepath) {
+			return nil, fmt.Errorf("expected a file registry URL file://<absolute path to file>, got %s", registryURL)
+		}
+
+		return newFileRegistry(filepath)
+	default:
+		return nil, fmt.Errorf("unsupported volume registry URL %s", registryURL)
+	}
+}
+
+// fileRegistry stores volume records in a single JSON file. The whole file
+// is rewritten on each modification, and it's expected to be small enough
+// for that: CVMFS volumes are only references to CVMFS repositories.
+type fileRegistry struct {
+	filepath string
+
+	mtx     sync.Mutex
+	records map[string]*volumeRecord
+}
+
+func newFileRegistry(filepath string) (*fileRegistry, error) {
+	if err := os.MkdirAll(path.Dir(filepath), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create volume registry directory: %v", err)
+	}
+
+	r := &fileRegistry{
+		filepath: filepath,
+		records:  make(map[string]*volumeRecord),
+	}
+
+	jsonData, err := os.ReadFile(filepath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+
+		return nil, fmt.Errorf("failed to read volume registry %s: %v", filepath, err)
+	}
+
+	if err = json.Unmarshal(jsonData, &r.records); err != nil {
+		return nil, fmt.Errorf("failed to parse volume registry %s: %v", filepath, err)
+	}
+
+	if r.records == nil {
+		r.records = make(map[string]*volumeRecord)
+	}
+
+	return r, nil
+}
+
+func (r *fileRegistry) put(rec *volumeRecord) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	records := maps.Clone(r.records)
+	records[rec.VolumeID] = rec
+
+	if err := r.store(records); err != nil {
+		return err
+	}
+
+	r.records = records
+
+	return nil
+}
+
+func (r *fileRegistry) get(volumeID string) (*volumeRecord, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.records[volumeID], nil
+}
+
+func (r *fileRegistry) delete(volumeID string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.records[volumeID]; !ok {
+		return nil
+	}
+
+	records := maps.Clone(r.records)
+	delete(records, volumeID)
+
+	if err := r.store(records); err != nil {
+		return err
+	}
+
+	r.records = records
+
+	return nil
+}
+
+func (r *fileRegistry) list() ([]*volumeRecord, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	volumeIDs := slices.Sorted(maps.Keys(r.records))
+
+	records := make([]*volumeRecord, len(volumeIDs))
+	for i := range volumeIDs {
+		records[i] = r.records[volumeIDs[i]]
+	}
+
+	return records, nil
+}
+
+// store atomically replaces the registry file with records.
+func (r *fileRegistry) store(records map[string]*volumeRecord) error {
+	jsonData, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(path.Dir(r.filepath), path.Base(r.filepath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for volume registry: %v", err)
+	}
+	tmpFilepath := f.Name()
+
+	if _, err = f.Write(jsonData); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFilepath, r.filepath)
+	}
+
+	if err != nil {
+		os.Remove(tmpFilepath)
+		return fmt.Errorf("failed to write volume registry %s: %v", r.filepath, err)
+	}
+
+	return nil
+}
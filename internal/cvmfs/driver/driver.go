@@ -63,6 +63,18 @@ type (
 		// How many seconds to wait for automount daemon to start up.
 		// Zero means no timeout.
 		AutomountDaemonStartupTimeoutSeconds int
+
+		// NamespacePolicyFilepath is path to the file with namespace policy
+		// for the node service. Empty value disables the policy.
+		NamespacePolicyFilepath string
+
+		// KubeletPodsDir is kubelet's pods directory, used to rebuild
+		// missing publish records at startup. Empty value disables this.
+		KubeletPodsDir string
+
+		// VolumeRegistryURL selects the backend of the controller's
+		// registry of created volumes. Empty value disables the registry.
+		VolumeRegistryURL string
 	}
 
 	// Driver holds CVMFS-CSI driver runtime state.
@@ -185,9 +197,22 @@ func setupNodeServiceRole(s *grpc.Server, d *Driver) error {
 		return err
 	}
 
+	// Rebuild publish records of volumes that were published
+	// without the node keeping a record of them.
+
+	if d.Opts.KubeletPodsDir != "" {
+		if err := node.RecoverPublishRecords(d.Opts.KubeletPodsDir, d.DriverName); err != nil {
+			log.Errorf("Failed to recover publish records: %v", err)
+		}
+	}
+
 	// We can register node server now.
 
-	ns := node.New(d.NodeID, d.Opts.SinglemountRunnerEndpoint)
+	ns := node.New(&node.Opts{
+		NodeID:                    d.NodeID,
+		SinglemountRunnerEndpoint: d.Opts.SinglemountRunnerEndpoint,
+		NamespacePolicyFilepath:   d.Opts.NamespacePolicyFilepath,
+	})
 
 	caps, err := ns.NodeGetCapabilities(
 		context.TODO(),
@@ -204,7 +229,10 @@ func setupNodeServiceRole(s *grpc.Server, d *Driver) error {
 }
 
 func setupControllerServiceRole(s *grpc.Server, d *Driver) error {
-	cs := controller.New()
+	cs, err := controller.New(d.Opts.VolumeRegistryURL)
+	if err != nil {
+		return err
+	}
 
 	caps, err := cs.ControllerGetCapabilities(
 		context.TODO(),
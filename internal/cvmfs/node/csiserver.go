@@ -25,6 +25,7 @@ import (
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount"
 	singlemountv1 "github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount/pb/v1"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -32,24 +33,43 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// Server implements csi.NodeServer interface.
-type Server struct {
-	nodeID                    string
-	singlemountRunnerEndpoint string
-	caps                      []*csi.NodeServiceCapability
-	csi.UnimplementedNodeServer
-}
+type (
+	// Opts holds init-time node server configuration.
+	Opts struct {
+		// NodeID is unique identifier of the node.
+		NodeID string
+
+		// SinglemountRunnerEndpoint is URL path to the UNIX socket
+		// for connecting to the singlemount-runner.
+		SinglemountRunnerEndpoint string
+
+		// NamespacePolicyFilepath is path to the namespace policy file.
+		// The policy is disabled if empty.
+		NamespacePolicyFilepath string
+	}
 
-const (
-	// autofs-managed CVMFS root mountpoint.
-	cvmfsRoot = "/cvmfs"
+	// Server implements csi.NodeServer interface.
+	Server struct {
+		*Opts
+		caps []*csi.NodeServiceCapability
+		csi.UnimplementedNodeServer
+	}
 )
 
+// autofs-managed CVMFS root mountpoint. It is a variable
+// only so that tests can point it to a temporary directory.
+var cvmfsRoot = "/cvmfs"
+
 var _ csi.NodeServer = (*Server)(nil)
 
-func New(nodeID, singlemountRunnerEndpoint string) *Server {
+// New creates a new node server. Policy files are read on each
+// NodeStageVolume and NodePublishVolume call, so that changes in
+// them are picked up without restarting the node plugin.
+func New(opts *Opts) *Server {
 	enabledCaps := []csi.NodeServiceCapability_RPC_Type{
 		csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
+		csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+		csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
 	}
 
 	var caps []*csi.NodeServiceCapability
@@ -64,9 +84,8 @@ func New(nodeID, singlemountRunnerEndpoint string) *Server {
 	}
 
 	return &Server{
-		nodeID:                    nodeID,
-		singlemountRunnerEndpoint: singlemountRunnerEndpoint,
-		caps:                      caps,
+		Opts: opts,
+		caps: caps,
 	}
 }
 
@@ -84,7 +103,7 @@ func (srv *Server) NodeGetInfo(
 	req *csi.NodeGetInfoRequest,
 ) (*csi.NodeGetInfoResponse, error) {
 	return &csi.NodeGetInfoResponse{
-		NodeId: srv.nodeID,
+		NodeId: srv.NodeID,
 	}, nil
 }
 
@@ -103,6 +122,26 @@ func (srv *Server) NodePublishVolume(
 			"failed to parse volume context: %v", err)
 	}
 
+	if err = srv.checkPublishPolicies(volCtx, req.GetVolumeContext()); err != nil {
+		return nil, err
+	}
+
+	if stagingPath := req.GetStagingTargetPath(); stagingPath != "" {
+		rec, err := readStageRecord(stagingPath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"failed to read stage record for %s: %v", stagingPath, err)
+		}
+
+		// Volumes staged by older versions of the driver have no record.
+		if rec != nil {
+			if err = rec.checkMatches(req.GetVolumeId(), volCtx); err != nil {
+				return nil, status.Errorf(codes.FailedPrecondition,
+					"volume staged in %s doesn't match the volume being published: %v", stagingPath, err)
+			}
+		}
+	}
+
 	if err := os.MkdirAll(targetPath, 0o700); err != nil {
 		return nil, status.Errorf(codes.Internal,
 			"failed to create mountpoint directory at %s: %v", targetPath, err)
@@ -116,11 +155,30 @@ func (srv *Server) NodePublishVolume(
 
 	switch mntState {
 	case mountutils.StNotMounted:
-		if err := srv.doVolumePublish(ctx, req, volCtx); err != nil {
+		publish := srv.doVolumePublish
+		if volCtx.ephemeral {
+			publish = srv.doEphemeralVolumePublish
+		}
+
+		if err := publish(ctx, req, volCtx); err != nil {
+			if _, ok := status.FromError(err); ok {
+				// Keep the status code and details of errors coming
+				// from singlemount-runner, so that the CO can tell
+				// which of them are worth retrying.
+				return nil, err
+			}
 			return nil, status.Errorf(codes.Internal, "failed to bind mount: %v", err)
 		}
 		fallthrough
 	case mountutils.StMounted:
+		rec := newPublishRecord(req.GetVolumeId(), targetPath, volCtx)
+		if err := writePublishRecord(rec); err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"failed to write publish record for %s: %v", targetPath, err)
+		}
+
+		log.InfoSWithContext(ctx, "Published volume", rec.logValues()...)
+
 		return &csi.NodePublishVolumeResponse{}, nil
 	default:
 		return nil, status.Errorf(codes.Internal,
@@ -138,29 +196,171 @@ func (srv *Server) doVolumePublish(
 		// When client config is set, we assume the CVMFS repo was mounted
 		// by singlemount-runner into stagingTargetPath.
 
-		client, err := singlemount.NewClient(ctx, srv.singlemountRunnerEndpoint)
+		client, err := singlemount.NewClient(ctx, srv.SinglemountRunnerEndpoint)
 		if err != nil {
 			return fmt.Errorf("failed to initialize client for singlemount-runner: %v", err)
 		}
 		defer client.Close()
 
+		if len(volCtx.repositories) > 0 {
+			// Multi-repository volumes have each repository mounted
+			// in its own subdirectory of stagingTargetPath.
+			for _, repository := range volCtx.repositories {
+				err = srv.ensureMountInStagingTargetPath(ctx, client,
+					path.Join(req.GetStagingTargetPath(), repository), volCtx.forRepository(repository))
+				if err != nil {
+					return err
+				}
+			}
+
+			return slaveRecursiveBind(ctx, req.GetStagingTargetPath(), req.GetTargetPath())
+		}
+
 		err = srv.ensureMountInStagingTargetPath(ctx, client, req.GetStagingTargetPath(), volCtx)
 		if err != nil {
 			return err
 		}
 
-		return bindMount(req.GetStagingTargetPath(), req.GetTargetPath())
+		return bindSubPath(ctx, req.GetStagingTargetPath(), volCtx.subPath, req.GetTargetPath())
 	}
 
 	// Otherwise we assume autofs-managed mounts.
 
 	if volCtx.repository != "" {
-		// Mount a single repository.
-		return bindMount(path.Join(cvmfsRoot, volCtx.repository), req.TargetPath)
+		// Mount a single repository, or its subpath.
+		return bindSubPath(ctx, path.Join(cvmfsRoot, volCtx.repository), volCtx.subPath, req.GetTargetPath())
+	}
+
+	if len(volCtx.repositories) > 0 {
+		// Mount only the listed repositories. Unlike when exposing
+		// the root restricted by namespace policy, all of them
+		// need to be available.
+		return restrictedRootBind(ctx, cvmfsRoot, volCtx.repositories, req.GetTargetPath(), false)
+	}
+
+	// Mount the whole autofs-CVMFS root, or the part of it
+	// that is permitted by the namespace policy.
+	return srv.exposeAutofsRoot(ctx, req.GetTargetPath(), volCtx, req.GetVolumeContext())
+}
+
+// checkPublishPolicies returns PermissionDenied error if namespace
+// policy doesn't permit the volume to be published. Multi-repository
+// volumes are checked for each of their repositories. Volumes exposing
+// the whole autofs-CVMFS root are checked against the policy rules later,
+// in exposeAutofsRoot.
+func (srv *Server) checkPublishPolicies(volCtx *volumeContext, volAttrs map[string]string) error {
+	if srv.NamespacePolicyFilepath == "" {
+		return nil
+	}
+
+	policy, err := loadNamespacePolicy(srv.NamespacePolicyFilepath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to load namespace policy: %v", err)
+	}
+
+	if err = policy.checkPublish(volCtx.repositoryList(), volCtx, volAttrs); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	return nil
+}
+
+func (srv *Server) exposeAutofsRoot(ctx context.Context, targetPath string, volCtx *volumeContext, volAttrs map[string]string) error {
+	if srv.NamespacePolicyFilepath == "" {
+		return slaveRecursiveBind(ctx, cvmfsRoot, targetPath)
+	}
+
+	policy, err := loadNamespacePolicy(srv.NamespacePolicyFilepath)
+	if err != nil {
+		return fmt.Errorf("failed to load namespace policy: %v", err)
+	}
+
+	if len(policy.Rules) == 0 {
+		return slaveRecursiveBind(ctx, cvmfsRoot, targetPath)
+	}
+
+	rule, err := policy.ruleFor(volCtx, volAttrs)
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if rule.unrestricted() {
+		return slaveRecursiveBind(ctx, cvmfsRoot, targetPath)
+	}
+
+	repositories, err := rule.permittedRepositories(cvmfsRoot)
+	if err != nil {
+		return fmt.Errorf("failed to list permitted repositories: %v", err)
+	}
+
+	log.Infof("Exposing repositories %v permitted by rule %s in namespace policy in %s",
+		repositories, rule, targetPath)
+
+	return restrictedRootBind(ctx, cvmfsRoot, repositories, targetPath, true)
+}
+
+// doEphemeralVolumePublish publishes an ephemeral inline volume. There is
+// no NodeStageVolume call for these, so volumes with client config are
+// mounted by singlemount-runner directly into the target path. Same as
+// for staged volumes, a record is kept next to the target path, so that
+// NodeUnpublishVolume knows to call singlemount-runner. Volumes publishing
+// only a subpath of the repository have the repository mounted next to
+// the target path, and the subpath is then bindmounted into the target path.
+func (srv *Server) doEphemeralVolumePublish(
+	ctx context.Context,
+	req *csi.NodePublishVolumeRequest,
+	volCtx *volumeContext,
+) (err error) {
+	if !volCtx.hasVolumeConfig() {
+		// Automounted volumes are only bindmounted,
+		// same as persistent volumes.
+		return srv.doVolumePublish(ctx, req, volCtx)
+	}
+
+	targetPath := req.GetTargetPath()
+
+	if err = writeStageRecord(targetPath, newStageRecord(req.GetVolumeId(), volCtx)); err != nil {
+		return fmt.Errorf("failed to write stage record for %s: %v", targetPath, err)
+	}
+
+	defer func() {
+		if err != nil {
+			if err2 := deleteStageRecord(targetPath); err2 != nil {
+				log.Errorf("failed to clean up stage record for %s: %v", targetPath, err2)
+			}
+		}
+	}()
+
+	if volCtx.subPath == "" {
+		return srv.doSingleMountVolume(ctx, targetPath, volCtx)
 	}
 
-	// Mount the whole autofs-CVMFS root.
-	return slaveRecursiveBind(cvmfsRoot, req.GetTargetPath())
+	mountpoint := fmtEphemeralRepositoryPath(targetPath)
+
+	if err = os.MkdirAll(mountpoint, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", mountpoint, err)
+	}
+
+	if err = srv.doSingleMountVolume(ctx, mountpoint, volCtx); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if err2 := srv.doSingleUnmountVolume(ctx, mountpoint, nil); err2 != nil {
+				log.Errorf("failed to clean up repository mounted in %s: %v", mountpoint, err2)
+			}
+		}
+	}()
+
+	return bindSubPath(ctx, mountpoint, volCtx.subPath, targetPath)
+}
+
+// fmtEphemeralRepositoryPath returns the path where the repository of an ephemeral
+// volume with subpath is mounted. Same as the stage record, it's stored next to
+// the target path, in the per-volume directory that the CO creates for it.
+func fmtEphemeralRepositoryPath(targetPath string) string {
+	return path.Clean(targetPath) + ".cvmfs-repository"
 }
 
 func (srv *Server) ensureMountInStagingTargetPath(
@@ -187,7 +387,7 @@ func (srv *Server) ensureMountInStagingTargetPath(
 	case mountutils.StNotMounted:
 		_, err := cl.Mount(ctx, mountSingleRequestFromVolCtx(stagingPath, volCtx))
 		if err != nil {
-			return fmt.Errorf("failed to bind mount: %v", err)
+			return fmt.Errorf("failed to mount %s: %w", stagingPath, err)
 		}
 		fallthrough
 	case mountutils.StMounted:
@@ -208,11 +408,42 @@ func (srv *Server) NodeUnpublishVolume(
 
 	targetPath := req.GetTargetPath()
 
+	// Ephemeral inline volumes with client config were mounted
+	// by singlemount-runner in NodePublishVolume, and need to be
+	// unmounted by it too.
+
+	rec, err := readStageRecord(targetPath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to read stage record for %s: %v", targetPath, err)
+	}
+
+	if rec != nil && rec.Singlemount {
+		if rec.SubPath == "" {
+			err = srv.doSingleUnmountVolume(ctx, targetPath, rec)
+		} else {
+			err = srv.doEphemeralSubPathUnpublish(ctx, targetPath, rec)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err = deleteStageRecord(targetPath); err != nil {
+			return nil, status.Errorf(codes.Internal,
+				"failed to delete stage record for %s: %v", targetPath, err)
+		}
+	}
+
 	mntState, err := mountutils.GetState(targetPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// This can happen e.g. when a node was rebooted.
 			// The volume is no longer mounted, so return success.
+			if err = srv.unpublishRecord(targetPath); err != nil {
+				return nil, status.Errorf(codes.Internal,
+					"failed to delete publish record for %s: %v", targetPath, err)
+			}
+
 			return &csi.NodeUnpublishVolumeResponse{}, nil
 		}
 
@@ -232,9 +463,61 @@ func (srv *Server) NodeUnpublishVolume(
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	if err = srv.unpublishRecord(targetPath); err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to delete publish record for %s: %v", targetPath, err)
+	}
+
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+// doEphemeralSubPathUnpublish unmounts the subpath bindmount in targetPath
+// first, and only then the repository mounted next to it.
+func (srv *Server) doEphemeralSubPathUnpublish(ctx context.Context, targetPath string, rec *stageRecord) error {
+	mntState, err := mountutils.GetState(targetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return status.Errorf(codes.Internal, "failed to probe for mountpoint %s: %v", targetPath, err)
+	}
+
+	if err == nil && mntState != mountutils.StNotMounted {
+		if err = recursiveUnmount(targetPath); err != nil {
+			return status.Errorf(codes.Internal, "failed to unmount %s: %v", targetPath, err)
+		}
+	}
+
+	mountpoint := fmtEphemeralRepositoryPath(targetPath)
+
+	if err = srv.doSingleUnmountVolume(ctx, mountpoint, rec); err != nil {
+		return err
+	}
+
+	if err = os.Remove(mountpoint); err != nil && !os.IsNotExist(err) {
+		return status.Errorf(codes.Internal, "failed to remove directory %s: %v", mountpoint, err)
+	}
+
+	return nil
+}
+
+func (srv *Server) unpublishRecord(targetPath string) error {
+	rec, err := readPublishRecord(targetPath)
+	if err != nil {
+		return err
+	}
+
+	if rec == nil {
+		// Published by an older version of the driver.
+		return nil
+	}
+
+	if err = deletePublishRecord(targetPath); err != nil {
+		return err
+	}
+
+	log.InfoS("Unpublished volume", rec.logValues()...)
+
+	return nil
+}
+
 func (srv *Server) NodeStageVolume(
 	ctx context.Context,
 	req *csi.NodeStageVolumeRequest,
@@ -249,26 +532,47 @@ func (srv *Server) NodeStageVolume(
 			"failed to parse volume context: %v", err)
 	}
 
+	// Pod namespace is not known until NodePublishVolume, so here we
+	// only reject volumes whose repository is not permitted in any namespace.
+
+	if srv.NamespacePolicyFilepath != "" {
+		policy, err := loadNamespacePolicy(srv.NamespacePolicyFilepath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to load namespace policy: %v", err)
+		}
+
+		for _, repository := range volCtx.repositoryList() {
+			if err = policy.checkStage(repository, req.GetVolumeContext()); err != nil {
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			}
+		}
+	}
+
 	// When client config is set, we cannot use automounts and need
 	// to delegate the mount to its own cvmfs2 call instead. We use
 	// the singlemount-runner for that.
 
-	if !volCtx.hasVolumeConfig() {
-		// No client config in volume context means we can proceed
-		// to bindmounting the autofs-CVMFS root.
-		return &csi.NodeStageVolumeResponse{}, nil
+	if volCtx.hasVolumeConfig() {
+		if err = srv.doSingleMountVolume(ctx, req.GetStagingTargetPath(), volCtx); err != nil {
+			return nil, err
+		}
 	}
 
-	err = srv.doSingleMount(ctx, mountSingleRequestFromVolCtx(req.StagingTargetPath, volCtx))
-	if err != nil {
-		return nil, err
+	// No client config in volume context means we can proceed
+	// to bindmounting the autofs-CVMFS root. In both cases we record
+	// how the volume was staged, so that NodeUnstageVolume and
+	// NodePublishVolume know what to expect.
+
+	if err = writeStageRecord(req.GetStagingTargetPath(), newStageRecord(req.GetVolumeId(), volCtx)); err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to write stage record for %s: %v", req.GetStagingTargetPath(), err)
 	}
 
 	return &csi.NodeStageVolumeResponse{}, nil
 }
 
 func (srv *Server) doSingleMount(ctx context.Context, req *singlemountv1.MountSingleRequest) error {
-	client, err := singlemount.NewClient(ctx, srv.singlemountRunnerEndpoint)
+	client, err := singlemount.NewClient(ctx, srv.SinglemountRunnerEndpoint)
 	if err != nil {
 		return status.Errorf(codes.Internal, "failed to initialize client for singlemount-runner: %v", err)
 	}
@@ -280,7 +584,7 @@ func (srv *Server) doSingleMount(ctx context.Context, req *singlemountv1.MountSi
 }
 
 func (srv *Server) doSingleUnmount(ctx context.Context, req *singlemountv1.UnmountSingleRequest) error {
-	client, err := singlemount.NewClient(ctx, srv.singlemountRunnerEndpoint)
+	client, err := singlemount.NewClient(ctx, srv.SinglemountRunnerEndpoint)
 	if err != nil {
 		return status.Errorf(codes.Internal, "failed to initialize client for singlemount-runner: %v", err)
 	}
@@ -291,6 +595,70 @@ func (srv *Server) doSingleUnmount(ctx context.Context, req *singlemountv1.Unmou
 	return err
 }
 
+// doSingleMountVolume mounts the volume into mountpoint using singlemount-runner.
+// Repositories of a multi-repository volume are each mounted into their own
+// subdirectory of mountpoint.
+func (srv *Server) doSingleMountVolume(ctx context.Context, mountpoint string, volCtx *volumeContext) (err error) {
+	if len(volCtx.repositories) == 0 {
+		return srv.doSingleMount(ctx, mountSingleRequestFromVolCtx(mountpoint, volCtx))
+	}
+
+	var mounted []string
+
+	defer func() {
+		if err != nil {
+			if err2 := srv.doSingleUnmountVolume(ctx, mountpoint, &stageRecord{Repositories: mounted}); err2 != nil {
+				log.Errorf("failed to clean up repositories mounted in %s: %v", mountpoint, err2)
+			}
+		}
+	}()
+
+	for _, repository := range volCtx.repositories {
+		repoPath := path.Join(mountpoint, repository)
+
+		if err = os.MkdirAll(repoPath, 0o755); err != nil {
+			return status.Errorf(codes.Internal, "failed to create directory %s: %v", repoPath, err)
+		}
+
+		err = srv.doSingleMount(ctx, mountSingleRequestFromVolCtx(repoPath, volCtx.forRepository(repository)))
+		if err != nil {
+			return err
+		}
+
+		mounted = append(mounted, repository)
+	}
+
+	return nil
+}
+
+// doSingleUnmountVolume unmounts a volume mounted by doSingleMountVolume,
+// as described by its stage record. Volume with no record is assumed
+// to be mounted directly in mountpoint.
+func (srv *Server) doSingleUnmountVolume(ctx context.Context, mountpoint string, rec *stageRecord) error {
+	if rec == nil || len(rec.Repositories) == 0 {
+		return srv.doSingleUnmount(ctx, &singlemountv1.UnmountSingleRequest{
+			Mountpoint: mountpoint,
+		})
+	}
+
+	for _, repository := range rec.Repositories {
+		repoPath := path.Join(mountpoint, repository)
+
+		err := srv.doSingleUnmount(ctx, &singlemountv1.UnmountSingleRequest{
+			Mountpoint: repoPath,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = os.Remove(repoPath); err != nil && !os.IsNotExist(err) {
+			return status.Errorf(codes.Internal, "failed to remove directory %s: %v", repoPath, err)
+		}
+	}
+
+	return nil
+}
+
 func (srv *Server) NodeUnstageVolume(
 	ctx context.Context,
 	req *csi.NodeUnstageVolumeRequest,
@@ -299,15 +667,29 @@ func (srv *Server) NodeUnstageVolume(
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	// Only volumes that have client config defined in their volume context
-	// have been mounted in NodeStageVolume. We have no way of knowing if this
-	// is such a volume (the request doesn't contain vol ctx). Try to unmount it.
+	stagingPath := req.GetStagingTargetPath()
 
-	err := srv.doSingleUnmount(ctx, &singlemountv1.UnmountSingleRequest{
-		Mountpoint: req.StagingTargetPath,
-	})
+	rec, err := readStageRecord(stagingPath)
 	if err != nil {
-		return nil, err
+		return nil, status.Errorf(codes.Internal,
+			"failed to read stage record for %s: %v", stagingPath, err)
+	}
+
+	// Only volumes that have client config defined in their volume context
+	// have been mounted in NodeStageVolume. The stage record tells us if this
+	// is such a volume. If there is no record (e.g. the volume was staged by
+	// an older version of the driver), we have no way of knowing, so we try
+	// to unmount it anyway.
+
+	if rec == nil || rec.Singlemount {
+		if err = srv.doSingleUnmountVolume(ctx, stagingPath, rec); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = deleteStageRecord(stagingPath); err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to delete stage record for %s: %v", stagingPath, err)
 	}
 
 	return &csi.NodeUnstageVolumeResponse{}, nil
@@ -317,7 +699,77 @@ func (srv *Server) NodeGetVolumeStats(
 	ctx context.Context,
 	req *csi.NodeGetVolumeStatsRequest,
 ) (*csi.NodeGetVolumeStatsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "")
+	if err := validateNodeGetVolumeStatsRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	volumePath := req.GetVolumePath()
+
+	mntState, err := mountutils.GetState(volumePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, status.Errorf(codes.NotFound, "volume path %s does not exist", volumePath)
+		}
+
+		return nil, status.Errorf(codes.Internal,
+			"failed to probe mountpoint %s: %v", volumePath, err)
+	}
+
+	switch mntState {
+	case mountutils.StNotMounted:
+		return nil, status.Errorf(codes.NotFound, "volume path %s is not mounted", volumePath)
+	case mountutils.StCorrupted:
+		return &csi.NodeGetVolumeStatsResponse{
+			VolumeCondition: abnormalVolumeCondition(volumePath),
+		}, nil
+	case mountutils.StMounted:
+	default:
+		return nil, status.Errorf(codes.Internal,
+			"unexpected mountpoint state in %s: expected %s or %s, got %s",
+			volumePath, mountutils.StMounted, mountutils.StCorrupted, mntState)
+	}
+
+	// Volumes mounted by singlemount-runner are bindmounted from the staging
+	// path, and that's where a crashed cvmfs2 process would show first.
+
+	if stagingPath := req.GetStagingTargetPath(); stagingPath != "" {
+		stagingMntState, err := mountutils.GetState(stagingPath)
+		if err == nil && stagingMntState == mountutils.StCorrupted {
+			return &csi.NodeGetVolumeStatsResponse{
+				VolumeCondition: abnormalVolumeCondition(stagingPath),
+			}, nil
+		}
+	}
+
+	// Volumes exposing a restricted CVMFS root have the repositories
+	// bindmounted inside of the target path.
+
+	if mountpoint, err := corruptedMountInside(volumePath); err == nil && mountpoint != "" {
+		return &csi.NodeGetVolumeStatsResponse{
+			VolumeCondition: abnormalVolumeCondition(mountpoint),
+		}, nil
+	}
+
+	usage, err := getVolumeUsage(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal,
+			"failed to get volume stats for %s: %v", volumePath, err)
+	}
+
+	if cond := repairedVolumeCondition(volumePath); cond != nil {
+		return &csi.NodeGetVolumeStatsResponse{
+			Usage:           usage,
+			VolumeCondition: cond,
+		}, nil
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: usage,
+		VolumeCondition: &csi.VolumeCondition{
+			Abnormal: false,
+			Message:  "volume is mounted",
+		},
+	}, nil
 }
 
 func (srv *Server) NodeExpandVolume(
@@ -351,19 +803,24 @@ func validateNodePublishVolumeRequest(req *csi.NodePublishVolumeRequest) error {
 	// We're not checking for staging target path, as older versions
 	// of the driver didn't support STAGE_UNSTAGE_VOLUME capability.
 
-	if req.GetVolumeCapability().GetAccessMode().GetMode() !=
-		csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
+	// Kubelet requests ephemeral inline volumes with ReadWriteOnce access
+	// mode. CVMFS volumes are always read-only, regardless of the mode.
+	if req.GetVolumeContext()[ephemeralVolumeKey] != "true" &&
+		req.GetVolumeCapability().GetAccessMode().GetMode() !=
+			csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
 		return fmt.Errorf("volume access mode must be ReadOnlyMany")
 	}
 
-	if volCtx := req.GetVolumeContext(); len(volCtx) > 0 {
-		unsupportedVolumeParams := []string{"hash", "tag"}
+	return nil
+}
 
-		for _, volParam := range unsupportedVolumeParams {
-			if _, ok := volCtx[volParam]; ok {
-				return fmt.Errorf("volume parameter %s is not supported, please use clientConfig instead", volParam)
-			}
-		}
+func validateNodeGetVolumeStatsRequest(req *csi.NodeGetVolumeStatsRequest) error {
+	if req.GetVolumeId() == "" {
+		return errors.New("volume ID missing in request")
+	}
+
+	if req.GetVolumePath() == "" {
+		return errors.New("volume path missing in request")
 	}
 
 	return nil
@@ -407,16 +864,6 @@ func validateNodeStageVolumeRequest(req *csi.NodeStageVolumeRequest) error {
 		return fmt.Errorf("volume access mode must be ReadOnlyMany")
 	}
 
-	if volCtx := req.GetVolumeContext(); len(volCtx) > 0 {
-		unsupportedVolumeParams := []string{"hash", "tag"}
-
-		for _, volParam := range unsupportedVolumeParams {
-			if _, ok := volCtx[volParam]; ok {
-				return fmt.Errorf("volume parameter %s is not supported", volParam)
-			}
-		}
-	}
-
 	return nil
 }
 
@@ -439,5 +886,7 @@ func mountSingleRequestFromVolCtx(mountPath string, volCtx *volumeContext) *sing
 		ConfigFilepath: volCtx.clientConfigFilepath,
 		Repository:     volCtx.repository,
 		Target:         mountPath,
+
+		ConfigParameters: volCtx.configParameters(),
 	}
 }
@@ -0,0 +1,1039 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"context"
+	"maps"
+	"net"
+	"os"
+	goexec "os/exec"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	singlemountv1 "github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount/pb/v1"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/moby/sys/mountinfo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSinglemount is a singlemount-runner that records the requests it gets.
+// If mount is set, each Mount call mounts a tmpfs in the target, with
+// a file named after the repository and the directories in repositoryDirs
+// in it, standing in for the repository. Mount calls for repositories
+// in failRepositories fail.
+type fakeSinglemount struct {
+	singlemountv1.UnimplementedSingleServer
+
+	mount            bool
+	repositoryDirs   []string
+	failRepositories []string
+
+	mu       sync.Mutex
+	mounts   []*singlemountv1.MountSingleRequest
+	unmounts []string
+}
+
+func (f *fakeSinglemount) Mount(ctx context.Context, req *singlemountv1.MountSingleRequest) (*singlemountv1.MountSingleResponse, error) {
+	f.mu.Lock()
+	f.mounts = append(f.mounts, req)
+	f.mu.Unlock()
+
+	if slices.Contains(f.failRepositories, req.Repository) {
+		return nil, status.Errorf(codes.Unavailable, "failed to mount repository %s", req.Repository)
+	}
+
+	if !f.mount {
+		return &singlemountv1.MountSingleResponse{}, nil
+	}
+
+	out, err := goexec.Command("mount", "-t", "tmpfs", "cvmfs-csi-test", req.Target).CombinedOutput()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to mount tmpfs: %v: %s", err, out)
+	}
+
+	if err = os.WriteFile(path.Join(req.Target, req.Repository), nil, 0o644); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	for _, dir := range f.repositoryDirs {
+		if err = os.MkdirAll(path.Join(req.Target, dir), 0o755); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return &singlemountv1.MountSingleResponse{}, nil
+}
+
+func (f *fakeSinglemount) Unmount(ctx context.Context, req *singlemountv1.UnmountSingleRequest) (*singlemountv1.UnmountSingleResponse, error) {
+	f.mu.Lock()
+	f.unmounts = append(f.unmounts, req.Mountpoint)
+	f.mu.Unlock()
+
+	if f.mount {
+		goexec.Command("umount", req.Mountpoint).Run()
+	}
+
+	return &singlemountv1.UnmountSingleResponse{}, nil
+}
+
+func (f *fakeSinglemount) mountRequests() []*singlemountv1.MountSingleRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.mounts)
+}
+
+func (f *fakeSinglemount) unmountRequests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.unmounts)
+}
+
+// startFakeSinglemount serves f on a UNIX socket, and returns its endpoint.
+func startFakeSinglemount(t *testing.T, f *fakeSinglemount) string {
+	t.Helper()
+
+	socketPath := path.Join(t.TempDir(), "singlemount.sock")
+
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer()
+	singlemountv1.RegisterSingleServer(s, f)
+
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	return "unix://" + socketPath
+}
+
+func requireRoot(t *testing.T) {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("test needs to mount filesystems, run it as root")
+	}
+}
+
+// newTestDir returns a temporary directory. Anything mounted
+// inside of it is unmounted when the test finishes.
+func newTestDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	t.Cleanup(func() {
+		mounts, err := mountinfo.GetMounts(mountinfo.PrefixFilter(dir))
+		if err != nil {
+			t.Errorf("failed to list mounts in %s: %v", dir, err)
+			return
+		}
+
+		// Unmount the deepest mounts first.
+		sort.Slice(mounts, func(i, j int) bool { return mounts[i].Mountpoint > mounts[j].Mountpoint })
+
+		for _, m := range mounts {
+			if out, err := goexec.Command("umount", "--lazy", m.Mountpoint).CombinedOutput(); err != nil {
+				t.Errorf("failed to unmount %s: %v: %s", m.Mountpoint, err, out)
+			}
+		}
+	})
+
+	return dir
+}
+
+// newTestServer returns a node server talking to f, with publish
+// records stored in a temporary directory.
+func newTestServer(t *testing.T, f *fakeSinglemount) *Server {
+	t.Helper()
+
+	origPublishRecordsDir := PublishRecordsDir
+	PublishRecordsDir = t.TempDir()
+	t.Cleanup(func() { PublishRecordsDir = origPublishRecordsDir })
+
+	return New(&Opts{
+		NodeID:                    "test-node",
+		SinglemountRunnerEndpoint: startFakeSinglemount(t, f),
+	})
+}
+
+// useTempCVMFSRoot points cvmfsRoot to a temporary directory with
+// a subdirectory for each of the repositories, standing in for
+// the autofs-managed mounts. Each contains a file named after
+// the repository, and the directories in repositoryDirs.
+func useTempCVMFSRoot(t *testing.T, repositories []string, repositoryDirs ...string) {
+	t.Helper()
+
+	origCVMFSRoot := cvmfsRoot
+	cvmfsRoot = t.TempDir()
+	t.Cleanup(func() { cvmfsRoot = origCVMFSRoot })
+
+	for _, repository := range repositories {
+		for _, dir := range append([]string{""}, repositoryDirs...) {
+			if err := os.MkdirAll(path.Join(cvmfsRoot, repository, dir), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := os.WriteFile(path.Join(cvmfsRoot, repository, repository), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func roxCapability() *csi.VolumeCapability {
+	return &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		},
+	}
+}
+
+// stageVolume creates the staging directory in dir, and stages
+// the volume in there.
+func stageVolume(t *testing.T, srv *Server, dir, volumeID string, volCtx map[string]string) (string, error) {
+	t.Helper()
+
+	stagingPath := path.Join(dir, volumeID, "globalmount")
+	if err := os.MkdirAll(stagingPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := srv.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          volumeID,
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  roxCapability(),
+		VolumeContext:     volCtx,
+	})
+
+	return stagingPath, err
+}
+
+// publishVolume publishes the volume into a target path in dir,
+// the same way kubelet does, i.e. the parent directory of the
+// target path exists, but the target path itself doesn't.
+func publishVolume(t *testing.T, srv *Server, dir, volumeID, stagingPath string, volCtx map[string]string) (string, error) {
+	t.Helper()
+
+	podDir := path.Join(dir, "pod", volumeID)
+	if err := os.MkdirAll(podDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	targetPath := path.Join(podDir, "mount")
+
+	_, err := srv.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+		VolumeId:          volumeID,
+		StagingTargetPath: stagingPath,
+		TargetPath:        targetPath,
+		VolumeCapability:  roxCapability(),
+		VolumeContext:     volCtx,
+		Readonly:          true,
+	})
+
+	return targetPath, err
+}
+
+func requireCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if got := status.Code(err); got != code {
+		t.Fatalf("got code %s (%v), want %s", got, err, code)
+	}
+}
+
+// listDir returns sorted names of entries in dir.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	return names
+}
+
+// withPodInfo returns a copy of volCtx with Pod information
+// added by kubelet when podInfoOnMount is enabled.
+func withPodInfo(volCtx map[string]string, namespace, serviceAccount string) map[string]string {
+	m := maps.Clone(volCtx)
+	m[podNameKey] = "pod-1"
+	m[podNamespaceKey] = namespace
+	m[podUIDKey] = "uid-1"
+	m[podServiceAccountKey] = serviceAccount
+
+	return m
+}
+
+const testRootHash = "0123456789abcdef0123456789abcdef01234567"
+
+func TestNodeStageVolumeSnapshot(t *testing.T) {
+	tests := []struct {
+		name           string
+		volCtx         map[string]string
+		wantParameters map[string]string
+		wantCode       codes.Code
+	}{
+		{
+			name:           "tag",
+			volCtx:         map[string]string{"repository": "sft.cern.ch", "tag": "release-1"},
+			wantParameters: map[string]string{"CVMFS_REPOSITORY_TAG": "release-1"},
+		},
+		{
+			name:           "hash",
+			volCtx:         map[string]string{"repository": "sft.cern.ch", "hash": testRootHash},
+			wantParameters: map[string]string{"CVMFS_ROOT_HASH": testRootHash},
+		},
+		{
+			name:           "revision",
+			volCtx:         map[string]string{"repository": "sft.cern.ch", "revision": "2022-03-01T12:00:00+01:00"},
+			wantParameters: map[string]string{"CVMFS_REPOSITORY_DATE": "2022-03-01T11:00:00Z"},
+		},
+		{
+			name: "tag with client config overrides",
+			volCtx: map[string]string{
+				"repository":            "sft.cern.ch",
+				"tag":                   "release-1",
+				"clientConfigOverrides": "CVMFS_REPOSITORY_TAG=other\nCVMFS_HTTP_PROXY=DIRECT",
+			},
+			wantParameters: map[string]string{"CVMFS_REPOSITORY_TAG": "release-1", "CVMFS_HTTP_PROXY": "DIRECT"},
+		},
+		{
+			name:     "tag and hash",
+			volCtx:   map[string]string{"repository": "sft.cern.ch", "tag": "release-1", "hash": testRootHash},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "invalid hash",
+			volCtx:   map[string]string{"repository": "sft.cern.ch", "hash": "abc"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "tag without repository",
+			volCtx:   map[string]string{"tag": "release-1"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeSinglemount{}
+			srv := newTestServer(t, f)
+
+			stagingPath, err := stageVolume(t, srv, t.TempDir(), "vol-1", tt.volCtx)
+			requireCode(t, err, tt.wantCode)
+
+			if tt.wantCode != codes.OK {
+				if len(f.mountRequests()) > 0 {
+					t.Errorf("singlemount-runner was called for a rejected volume")
+				}
+				return
+			}
+
+			mounts := f.mountRequests()
+			if len(mounts) != 1 {
+				t.Fatalf("got %d Mount calls, want 1", len(mounts))
+			}
+
+			req := mounts[0]
+			if req.Repository != "sft.cern.ch" || req.Target != stagingPath || req.MountId != "vol-1" {
+				t.Errorf("unexpected Mount request %v", req)
+			}
+			if !maps.Equal(req.ConfigParameters, tt.wantParameters) {
+				t.Errorf("got config parameters %v, want %v", req.ConfigParameters, tt.wantParameters)
+			}
+
+			rec, err := readStageRecord(stagingPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec == nil || !rec.Singlemount || !maps.Equal(rec.ConfigParameters, tt.wantParameters) {
+				t.Errorf("unexpected stage record %+v", rec)
+			}
+		})
+	}
+}
+
+func TestNodePublishVolumeSnapshot(t *testing.T) {
+	requireRoot(t)
+
+	f := &fakeSinglemount{mount: true}
+	srv := newTestServer(t, f)
+	dir := newTestDir(t)
+
+	volCtx := map[string]string{"repository": "sft.cern.ch", "tag": "release-1"}
+
+	stagingPath, err := stageVolume(t, srv, dir, "vol-1", volCtx)
+	requireCode(t, err, codes.OK)
+
+	targetPath, err := publishVolume(t, srv, dir, "vol-1", stagingPath, volCtx)
+	requireCode(t, err, codes.OK)
+
+	if got := listDir(t, targetPath); !slices.Equal(got, []string{"sft.cern.ch"}) {
+		t.Errorf("target path contains %v, want the staged repository", got)
+	}
+
+	// The same PV can't be published with a different snapshot
+	// than it was staged with.
+
+	for _, otherCtx := range []map[string]string{
+		{"repository": "sft.cern.ch", "tag": "release-2"},
+		{"repository": "sft.cern.ch", "hash": testRootHash},
+		{"repository": "sft.cern.ch"},
+	} {
+		_, err = publishVolume(t, srv, dir, "vol-1", stagingPath, otherCtx)
+		requireCode(t, err, codes.FailedPrecondition)
+
+		if !strings.Contains(err.Error(), "mismatch") {
+			t.Errorf("unexpected error for %v: %v", otherCtx, err)
+		}
+	}
+}
+
+func TestNodePublishVolumePublishRecord(t *testing.T) {
+	requireRoot(t)
+
+	f := &fakeSinglemount{mount: true}
+	srv := newTestServer(t, f)
+	dir := newTestDir(t)
+
+	volCtx := map[string]string{"repository": "sft.cern.ch", "clientConfig": "CVMFS_HTTP_PROXY=DIRECT"}
+
+	stagingPath, err := stageVolume(t, srv, dir, "vol-1", volCtx)
+	requireCode(t, err, codes.OK)
+
+	targetPath, err := publishVolume(t, srv, dir, "vol-1", stagingPath, withPodInfo(volCtx, "atlas-prod", "default"))
+	requireCode(t, err, codes.OK)
+
+	rec, err := readPublishRecord(targetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec == nil {
+		t.Fatal("publish record was not written")
+	}
+
+	want := PublishRecord{
+		VolumeID:       "vol-1",
+		Repository:     "sft.cern.ch",
+		TargetPath:     targetPath,
+		PodName:        "pod-1",
+		PodNamespace:   "atlas-prod",
+		PodUID:         "uid-1",
+		ServiceAccount: "default",
+		PublishedAt:    rec.PublishedAt,
+	}
+	if !reflect.DeepEqual(*rec, want) {
+		t.Errorf("got publish record %+v, want %+v", *rec, want)
+	}
+
+	recs, err := ListPublishRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].TargetPath != targetPath {
+		t.Errorf("got publish records %v, want the published volume", recs)
+	}
+
+	_, err = srv.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "vol-1",
+		TargetPath: targetPath,
+	})
+	requireCode(t, err, codes.OK)
+
+	if rec, err = readPublishRecord(targetPath); err != nil || rec != nil {
+		t.Errorf("publish record was not deleted: %+v, %v", rec, err)
+	}
+}
+
+func TestNodePublishVolumeNamespacePolicy(t *testing.T) {
+	const policy = `{"repositories": {"atlas.cern.ch": ["atlas-*"], "cms.cern.ch": ["cms"]}}`
+
+	tests := []struct {
+		name      string
+		volCtx    map[string]string
+		namespace string
+		noPodInfo bool
+		wantCode  codes.Code
+	}{
+		{
+			name:      "allowed namespace",
+			volCtx:    map[string]string{"repository": "atlas.cern.ch", "clientConfig": "CVMFS_HTTP_PROXY=DIRECT"},
+			namespace: "atlas-prod",
+		},
+		{
+			name:      "unlisted repository",
+			volCtx:    map[string]string{"repository": "sft.cern.ch", "clientConfig": "CVMFS_HTTP_PROXY=DIRECT"},
+			namespace: "default",
+		},
+		{
+			name:      "namespace not allowed",
+			volCtx:    map[string]string{"repository": "atlas.cern.ch", "clientConfig": "CVMFS_HTTP_PROXY=DIRECT"},
+			namespace: "cms",
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			name:      "missing pod info",
+			volCtx:    map[string]string{"repository": "sft.cern.ch", "clientConfig": "CVMFS_HTTP_PROXY=DIRECT"},
+			noPodInfo: true,
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			name:      "whole root with restricted repositories",
+			volCtx:    map[string]string{},
+			namespace: "atlas-prod",
+			wantCode:  codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantCode == codes.OK {
+				requireRoot(t)
+			}
+
+			f := &fakeSinglemount{mount: true}
+			srv := newTestServer(t, f)
+			srv.NamespacePolicyFilepath = writePolicyFile(t, policy)
+			dir := newTestDir(t)
+
+			stagingPath, err := stageVolume(t, srv, dir, "vol-1", tt.volCtx)
+			requireCode(t, err, codes.OK)
+
+			publishCtx := tt.volCtx
+			if !tt.noPodInfo {
+				publishCtx = withPodInfo(tt.volCtx, tt.namespace, "default")
+			}
+
+			targetPath, err := publishVolume(t, srv, dir, "vol-1", stagingPath, publishCtx)
+			requireCode(t, err, tt.wantCode)
+
+			if tt.wantCode == codes.OK {
+				return
+			}
+
+			if _, err = os.Stat(targetPath); !os.IsNotExist(err) {
+				t.Errorf("target path of a denied volume was created: %v", err)
+			}
+
+			if rec, err := readPublishRecord(targetPath); err != nil || rec != nil {
+				t.Errorf("publish record of a denied volume was written: %+v, %v", rec, err)
+			}
+		})
+	}
+}
+
+const testRulesPolicy = `{
+  "rules": [
+    {
+      "name": "atlas",
+      "namespaces": ["atlas-*"],
+      "allow": ["atlas*.cern.ch", "sft.cern.ch"],
+      "deny": ["atlas-condb.cern.ch"]
+    },
+    {
+      "name": "restricted-class",
+      "volumeAttributes": {"policyClass": "restricted"},
+      "allow": ["sft.cern.ch"]
+    }
+  ]
+}`
+
+func TestNodeStageVolumeNamespacePolicyRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		volCtx   map[string]string
+		wantCode codes.Code
+	}{
+		{
+			name:   "permitted in some namespace",
+			volCtx: map[string]string{"repository": "atlas.cern.ch"},
+		},
+		{
+			name:   "permitted by volume attributes",
+			volCtx: map[string]string{"repository": "sft.cern.ch", "policyClass": "restricted"},
+		},
+		{
+			name:   "whole root is checked at publish",
+			volCtx: map[string]string{},
+		},
+		{
+			name:     "not permitted in any namespace",
+			volCtx:   map[string]string{"repository": "cms.cern.ch"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "denied",
+			volCtx:   map[string]string{"repository": "atlas-condb.cern.ch"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "one of repositories not permitted",
+			volCtx:   map[string]string{"repositories": "atlas.cern.ch,cms.cern.ch"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name: "singlemount volume not permitted",
+			volCtx: map[string]string{
+				"repository":   "cms.cern.ch",
+				"clientConfig": "CVMFS_HTTP_PROXY=DIRECT",
+			},
+			wantCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeSinglemount{}
+			srv := newTestServer(t, f)
+			srv.NamespacePolicyFilepath = writePolicyFile(t, testRulesPolicy)
+
+			stagingPath, err := stageVolume(t, srv, t.TempDir(), "vol-1", tt.volCtx)
+			requireCode(t, err, tt.wantCode)
+
+			if tt.wantCode == codes.OK {
+				return
+			}
+
+			if len(f.mountRequests()) > 0 {
+				t.Errorf("singlemount-runner was called for a denied volume")
+			}
+
+			if rec, err := readStageRecord(stagingPath); err != nil || rec != nil {
+				t.Errorf("stage record of a denied volume was written: %+v, %v", rec, err)
+			}
+		})
+	}
+}
+
+func TestNodePublishVolumeNamespacePolicyRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		volCtx    map[string]string
+		namespace string
+		wantCode  codes.Code
+		wantRepos []string
+	}{
+		{
+			name:      "permitted repository",
+			volCtx:    map[string]string{"repository": "sft.cern.ch"},
+			namespace: "atlas-prod",
+			wantRepos: []string{"sft.cern.ch"},
+		},
+		{
+			name:      "not permitted in namespace",
+			volCtx:    map[string]string{"repository": "sft.cern.ch"},
+			namespace: "batch",
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			name:      "denied repository",
+			volCtx:    map[string]string{"repository": "atlas-condb.cern.ch", "policyClass": "restricted"},
+			namespace: "cms",
+			wantCode:  codes.PermissionDenied,
+		},
+		{
+			name:      "whole root exposes permitted repositories",
+			volCtx:    map[string]string{},
+			namespace: "atlas-prod",
+			wantRepos: []string{"atlas.cern.ch", "sft.cern.ch"},
+		},
+		{
+			name:      "whole root with volume attributes",
+			volCtx:    map[string]string{"policyClass": "restricted"},
+			namespace: "cms",
+			wantRepos: []string{"sft.cern.ch"},
+		},
+		{
+			name:      "whole root not permitted in namespace",
+			volCtx:    map[string]string{},
+			namespace: "batch",
+			wantCode:  codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantCode == codes.OK {
+				requireRoot(t)
+			}
+
+			useTempCVMFSRoot(t, []string{"atlas.cern.ch", "atlas-condb.cern.ch", "cms.cern.ch", "sft.cern.ch"})
+
+			srv := newTestServer(t, &fakeSinglemount{})
+			srv.NamespacePolicyFilepath = writePolicyFile(t, testRulesPolicy)
+			dir := newTestDir(t)
+
+			stagingPath := path.Join(dir, "vol-1", "globalmount")
+			if err := os.MkdirAll(stagingPath, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			targetPath, err := publishVolume(t, srv, dir, "vol-1", stagingPath, withPodInfo(tt.volCtx, tt.namespace, "default"))
+			requireCode(t, err, tt.wantCode)
+
+			if tt.wantCode != codes.OK {
+				if mounted, _ := mountinfo.Mounted(targetPath); mounted {
+					t.Errorf("denied volume was mounted in %s", targetPath)
+				}
+				if rec, err := readPublishRecord(targetPath); err != nil || rec != nil {
+					t.Errorf("publish record of a denied volume was written: %+v, %v", rec, err)
+				}
+				return
+			}
+
+			// Single repository volumes have the repository itself
+			// in the target path, with a file named after it.
+			if got := listDir(t, targetPath); !slices.Equal(got, tt.wantRepos) {
+				t.Errorf("target path contains %v, want %v", got, tt.wantRepos)
+			}
+		})
+	}
+}
+
+func TestNodeStageVolumeMultiRepository(t *testing.T) {
+	requireRoot(t)
+
+	f := &fakeSinglemount{mount: true}
+	srv := newTestServer(t, f)
+	dir := newTestDir(t)
+
+	volCtx := map[string]string{
+		"repositories": "atlas.cern.ch,sft.cern.ch",
+		"clientConfig": "CVMFS_HTTP_PROXY=DIRECT",
+	}
+
+	stagingPath, err := stageVolume(t, srv, dir, "vol-1", volCtx)
+	requireCode(t, err, codes.OK)
+
+	// Each repository is mounted by singlemount-runner
+	// in its own subdirectory of the staging path.
+
+	var gotMounts []string
+	for _, req := range f.mountRequests() {
+		gotMounts = append(gotMounts, req.Repository+" "+req.MountId+" "+req.Target)
+	}
+
+	wantMounts := []string{
+		"atlas.cern.ch vol-1-atlas.cern.ch " + path.Join(stagingPath, "atlas.cern.ch"),
+		"sft.cern.ch vol-1-sft.cern.ch " + path.Join(stagingPath, "sft.cern.ch"),
+	}
+	if !slices.Equal(gotMounts, wantMounts) {
+		t.Errorf("got Mount calls %q, want %q", gotMounts, wantMounts)
+	}
+
+	// The target path holds just the listed repositories.
+
+	targetPath, err := publishVolume(t, srv, dir, "vol-1", stagingPath, volCtx)
+	requireCode(t, err, codes.OK)
+
+	if got := listDir(t, targetPath); !slices.Equal(got, []string{"atlas.cern.ch", "sft.cern.ch"}) {
+		t.Errorf("target path contains %v, want the listed repositories", got)
+	}
+
+	for _, repository := range []string{"atlas.cern.ch", "sft.cern.ch"} {
+		if got := listDir(t, path.Join(targetPath, repository)); !slices.Equal(got, []string{repository}) {
+			t.Errorf("repository %s in target path contains %v, want the mounted repository", repository, got)
+		}
+	}
+
+	_, err = srv.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "vol-1",
+		TargetPath: targetPath,
+	})
+	requireCode(t, err, codes.OK)
+
+	_, err = srv.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          "vol-1",
+		StagingTargetPath: stagingPath,
+	})
+	requireCode(t, err, codes.OK)
+
+	wantUnmounts := []string{path.Join(stagingPath, "atlas.cern.ch"), path.Join(stagingPath, "sft.cern.ch")}
+	if got := f.unmountRequests(); !slices.Equal(got, wantUnmounts) {
+		t.Errorf("got Unmount calls %q, want %q", got, wantUnmounts)
+	}
+
+	if got := listDir(t, stagingPath); len(got) > 0 {
+		t.Errorf("staging path still contains %v after unstaging", got)
+	}
+}
+
+func TestNodeStageVolumeMultiRepositoryFailedMount(t *testing.T) {
+	f := &fakeSinglemount{failRepositories: []string{"sft.cern.ch"}}
+	srv := newTestServer(t, f)
+
+	stagingPath, err := stageVolume(t, srv, t.TempDir(), "vol-1", map[string]string{
+		"repositories": "atlas.cern.ch,sft.cern.ch,grid.cern.ch",
+		"clientConfig": "CVMFS_HTTP_PROXY=DIRECT",
+	})
+	requireCode(t, err, codes.Unavailable)
+
+	// Repositories mounted before the failure are unmounted,
+	// and the following ones are not mounted at all.
+
+	if got := len(f.mountRequests()); got != 2 {
+		t.Errorf("got %d Mount calls, want 2", got)
+	}
+
+	wantUnmounts := []string{path.Join(stagingPath, "atlas.cern.ch")}
+	if got := f.unmountRequests(); !slices.Equal(got, wantUnmounts) {
+		t.Errorf("got Unmount calls %q, want %q", got, wantUnmounts)
+	}
+
+	if rec, err := readStageRecord(stagingPath); err != nil || rec != nil {
+		t.Errorf("stage record of a failed volume was written: %+v, %v", rec, err)
+	}
+}
+
+func TestNodePublishVolumeMultiRepositoryAutomounted(t *testing.T) {
+	requireRoot(t)
+
+	useTempCVMFSRoot(t, []string{"atlas.cern.ch", "cms.cern.ch", "sft.cern.ch"})
+
+	srv := newTestServer(t, &fakeSinglemount{})
+	dir := newTestDir(t)
+
+	volCtx := map[string]string{"repositories": "sft.cern.ch,atlas.cern.ch"}
+
+	stagingPath, err := stageVolume(t, srv, dir, "vol-1", volCtx)
+	requireCode(t, err, codes.OK)
+
+	targetPath, err := publishVolume(t, srv, dir, "vol-1", stagingPath, volCtx)
+	requireCode(t, err, codes.OK)
+
+	if got := listDir(t, targetPath); !slices.Equal(got, []string{"atlas.cern.ch", "sft.cern.ch"}) {
+		t.Errorf("target path contains %v, want the listed repositories", got)
+	}
+
+	if got := listDir(t, path.Join(targetPath, "sft.cern.ch")); !slices.Equal(got, []string{"sft.cern.ch"}) {
+		t.Errorf("repository in target path contains %v, want the autofs-managed repository", got)
+	}
+
+	if err = os.WriteFile(path.Join(targetPath, "cms.cern.ch"), nil, 0o644); err == nil {
+		t.Errorf("target path is writable")
+	}
+
+	// All listed repositories must be available.
+
+	missingCtx := map[string]string{"repositories": "atlas.cern.ch,lhcb.cern.ch"}
+
+	stagingPath, err = stageVolume(t, srv, dir, "vol-2", missingCtx)
+	requireCode(t, err, codes.OK)
+
+	targetPath, err = publishVolume(t, srv, dir, "vol-2", stagingPath, missingCtx)
+	requireCode(t, err, codes.Internal)
+
+	if !strings.Contains(err.Error(), "lhcb.cern.ch") {
+		t.Errorf("error doesn't mention the missing repository: %v", err)
+	}
+
+	if mounted, _ := mountinfo.Mounted(targetPath); mounted {
+		t.Errorf("volume with a missing repository was mounted in %s", targetPath)
+	}
+}
+
+// requireSameDir fails the test if dir is not the same directory as want,
+// e.g. when want is not bindmounted in dir.
+func requireSameDir(t *testing.T, dir, want string) {
+	t.Helper()
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFi, err := os.Stat(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(fi, wantFi) {
+		t.Fatalf("%s is not bindmounted from %s", dir, want)
+	}
+}
+
+func TestNodePublishVolumeSubPath(t *testing.T) {
+	requireRoot(t)
+
+	tests := []struct {
+		name     string
+		volCtx   map[string]string
+		wantCode codes.Code
+	}{
+		{
+			name:   "automounted",
+			volCtx: map[string]string{"repository": "sft.cern.ch", "subPath": "lcg/views"},
+		},
+		{
+			name:   "singlemount",
+			volCtx: map[string]string{"repository": "sft.cern.ch", "subPath": "lcg/views", "clientConfig": "CVMFS_HTTP_PROXY=DIRECT"},
+		},
+		{
+			name:     "automounted missing path",
+			volCtx:   map[string]string{"repository": "sft.cern.ch", "subPath": "lcg/releases"},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "singlemount missing path",
+			volCtx:   map[string]string{"repository": "sft.cern.ch", "subPath": "lcg/releases", "clientConfig": "CVMFS_HTTP_PROXY=DIRECT"},
+			wantCode: codes.NotFound,
+		},
+		{
+			name:     "symlink outside of the repository",
+			volCtx:   map[string]string{"repository": "sft.cern.ch", "subPath": "escape"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "not a directory",
+			volCtx:   map[string]string{"repository": "sft.cern.ch", "subPath": "sft.cern.ch"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempCVMFSRoot(t, []string{"sft.cern.ch"}, "lcg/views")
+
+			if err := os.Symlink("/", path.Join(cvmfsRoot, "sft.cern.ch", "escape")); err != nil {
+				t.Fatal(err)
+			}
+
+			srv := newTestServer(t, &fakeSinglemount{mount: true, repositoryDirs: []string{"lcg/views"}})
+			dir := newTestDir(t)
+
+			stagingPath, err := stageVolume(t, srv, dir, "vol-1", tt.volCtx)
+			requireCode(t, err, codes.OK)
+
+			targetPath, err := publishVolume(t, srv, dir, "vol-1", stagingPath, tt.volCtx)
+			requireCode(t, err, tt.wantCode)
+
+			if tt.wantCode != codes.OK {
+				if mounted, _ := mountinfo.Mounted(targetPath); mounted {
+					t.Errorf("volume with invalid subPath was mounted in %s", targetPath)
+				}
+				return
+			}
+
+			repositoryRoot := path.Join(cvmfsRoot, "sft.cern.ch")
+			if tt.volCtx["clientConfig"] != "" {
+				repositoryRoot = stagingPath
+			}
+
+			requireSameDir(t, targetPath, path.Join(repositoryRoot, "lcg/views"))
+		})
+	}
+}
+
+func TestNodePublishVolumeSubPathTraversal(t *testing.T) {
+	srv := newTestServer(t, &fakeSinglemount{})
+
+	for _, subPath := range []string{"../atlas.cern.ch", "lcg/../../atlas.cern.ch", "/lcg"} {
+		volCtx := map[string]string{"repository": "sft.cern.ch", "subPath": subPath}
+
+		_, err := stageVolume(t, srv, t.TempDir(), "vol-1", volCtx)
+		requireCode(t, err, codes.InvalidArgument)
+
+		_, err = publishVolume(t, srv, t.TempDir(), "vol-1", "", volCtx)
+		requireCode(t, err, codes.InvalidArgument)
+	}
+}
+
+func TestNodePublishVolumeEphemeralSubPath(t *testing.T) {
+	requireRoot(t)
+
+	f := &fakeSinglemount{mount: true, repositoryDirs: []string{"lcg/views"}}
+	srv := newTestServer(t, f)
+	dir := newTestDir(t)
+
+	volCtx := map[string]string{
+		"repository":                   "sft.cern.ch",
+		"subPath":                      "lcg/views",
+		"clientConfig":                 "CVMFS_HTTP_PROXY=DIRECT",
+		"csi.storage.k8s.io/ephemeral": "true",
+	}
+
+	// Ephemeral volumes are not staged. The repository is mounted
+	// next to the target path, and the subpath is bindmounted from it.
+
+	targetPath, err := publishVolume(t, srv, dir, "vol-1", "", volCtx)
+	requireCode(t, err, codes.OK)
+
+	repositoryPath := fmtEphemeralRepositoryPath(targetPath)
+
+	mounts := f.mountRequests()
+	if len(mounts) != 1 || mounts[0].Target != repositoryPath {
+		t.Fatalf("got Mount calls %v, want one into %s", mounts, repositoryPath)
+	}
+
+	requireSameDir(t, targetPath, path.Join(repositoryPath, "lcg/views"))
+
+	_, err = srv.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "vol-1",
+		TargetPath: targetPath,
+	})
+	requireCode(t, err, codes.OK)
+
+	if got := f.unmountRequests(); !slices.Equal(got, []string{repositoryPath}) {
+		t.Errorf("got Unmount calls %q, want %q", got, repositoryPath)
+	}
+
+	for _, p := range []string{targetPath, repositoryPath} {
+		if _, err = os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", p, err)
+		}
+	}
+
+	// Missing subpath fails with NotFound, and the repository
+	// mounted for it is unmounted.
+
+	volCtx["subPath"] = "lcg/releases"
+
+	targetPath, err = publishVolume(t, srv, dir, "vol-2", "", volCtx)
+	requireCode(t, err, codes.NotFound)
+
+	repositoryPath = fmtEphemeralRepositoryPath(targetPath)
+
+	if got := f.unmountRequests(); !slices.Contains(got, repositoryPath) {
+		t.Errorf("repository in %s was not unmounted, got Unmount calls %q", repositoryPath, got)
+	}
+
+	if rec, err := readStageRecord(targetPath); err != nil || rec != nil {
+		t.Errorf("stage record of a failed volume was kept: %+v, %v", rec, err)
+	}
+}
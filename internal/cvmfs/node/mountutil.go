@@ -17,19 +17,24 @@
 package node
 
 import (
+	"context"
+	"fmt"
+	"os"
 	goexec "os/exec"
+	"path"
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
 )
 
-func bindMount(from, to string) error {
-	_, err := exec.CombinedOutput(goexec.Command("mount", "--bind", from, to))
+func bindMount(ctx context.Context, from, to string) error {
+	_, err := exec.CombinedOutputContext(ctx, goexec.Command("mount", "--bind", from, to))
 	return err
 }
 
-func slaveRecursiveBind(from, to string) error {
-	_, err := exec.CombinedOutput(goexec.Command(
+func slaveRecursiveBind(ctx context.Context, from, to string) error {
+	_, err := exec.CombinedOutputContext(ctx, goexec.Command(
 		"mount",
 		from,
 		to,
@@ -52,6 +57,52 @@ func slaveRecursiveBind(from, to string) error {
 	return err
 }
 
+// restrictedRootBind exposes only selected repositories of the autofs root.
+// A read-only tmpfs is mounted at to, and each repository is bindmounted
+// into its own directory in there. If skipUnavailable is set, repositories
+// that fail to mount (e.g. because they don't exist) are skipped, otherwise
+// the whole mount fails.
+func restrictedRootBind(ctx context.Context, from string, repositories []string, to string, skipUnavailable bool) (err error) {
+	_, err = exec.CombinedOutputContext(ctx, goexec.Command(
+		"mount", "-t", "tmpfs", "-o", "mode=0755,size=1m", RestrictedRootSource, to,
+	))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if err2 := recursiveUnmount(to); err2 != nil {
+				log.Errorf("failed to clean up restricted CVMFS root in %s: %v", to, err2)
+			}
+		}
+	}()
+
+	for _, repository := range repositories {
+		repoTarget := path.Join(to, repository)
+
+		if err = os.Mkdir(repoTarget, 0o755); err != nil {
+			return err
+		}
+
+		if err = slaveRecursiveBind(ctx, path.Join(from, repository), repoTarget); err != nil {
+			if !skipUnavailable {
+				return fmt.Errorf("failed to mount repository %s: %v", repository, err)
+			}
+
+			log.Infof("Skipping repository %s when exposing restricted CVMFS root in %s: %v",
+				repository, to, err)
+
+			if err = os.Remove(repoTarget); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = exec.CombinedOutputContext(ctx, goexec.Command("mount", "-o", "remount,ro", to))
+	return err
+}
+
 func recursiveUnmount(mountpoint string) error {
 	// We need recursive unmount because there are live mounts inside the bindmount.
 	// Unmounting only the upper autofs mount would result in EBUSY.
@@ -0,0 +1,402 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// namespacePolicy restricts which CVMFS repositories volumes may expose,
+// depending on the Pod they are published to. It has two parts, and a volume
+// is permitted only if both of them permit each of its repositories:
+//
+//   - Repositories maps repository names to namespaces (glob patterns as
+//     accepted by path.Match) whose Pods are allowed to mount them.
+//     Repositories not listed there may be mounted by any Pod.
+//   - Rules are evaluated in order, and the first rule that matches the
+//     volume lists the repositories it may expose. Volumes not matched by
+//     any rule are denied. A policy without rules doesn't restrict volumes
+//     in this way.
+//
+// Example:
+//
+//	{
+//	  "repositories": {
+//	    "atlas.cern.ch": ["atlas-*"],
+//	    "cms.cern.ch": ["cms", "cms-*"]
+//	  },
+//	  "rules": [
+//	    {
+//	      "name": "atlas",
+//	      "namespaces": ["atlas-*"],
+//	      "allow": ["atlas*.cern.ch", "sft.cern.ch"],
+//	      "deny": ["atlas-condb.cern.ch"]
+//	    },
+//	    {
+//	      "name": "prefetch",
+//	      "serviceAccounts": ["*/cvmfs-prefetch"],
+//	      "allow": ["*"]
+//	    },
+//	    {
+//	      "name": "restricted-class",
+//	      "volumeAttributes": {"policyClass": "restricted"},
+//	      "allow": ["sft.cern.ch"]
+//	    },
+//	    {
+//	      "name": "default",
+//	      "allow": ["*"]
+//	    }
+//	  ]
+//	}
+type namespacePolicy struct {
+	Repositories map[string][]string   `json:"repositories"`
+	Rules        []namespacePolicyRule `json:"rules"`
+}
+
+type namespacePolicyRule struct {
+	// Name of the rule, used in error messages.
+	Name string `json:"name"`
+
+	// Rule matches Pods in these namespaces (glob patterns). If empty,
+	// the rule matches regardless of the namespace.
+	Namespaces []string `json:"namespaces"`
+
+	// Rule matches Pods running as these service accounts, given as
+	// <namespace>/<name> glob patterns. If empty, the rule matches
+	// regardless of the service account.
+	ServiceAccounts []string `json:"serviceAccounts"`
+
+	// Rule matches volumes whose volume attributes (i.e. StorageClass
+	// parameters or PersistentVolume.spec.csi.volumeAttributes) contain
+	// all of these key-value pairs. Attributes of ephemeral inline volumes
+	// are set by the Pod author, and so rules with volume attributes never
+	// match them.
+	VolumeAttributes map[string]string `json:"volumeAttributes"`
+
+	// Repositories (glob patterns) the matched volumes may expose,
+	// unless they are also listed in Deny.
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+func loadNamespacePolicy(filepath string) (*namespacePolicy, error) {
+	policyJSON, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy namespacePolicy
+	if err = json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse namespace policy %s: %v", filepath, err)
+	}
+
+	for repository, patterns := range policy.Repositories {
+		if err = validatePatterns(patterns); err != nil {
+			return nil, fmt.Errorf("invalid namespaces for repository %s in namespace policy %s: %v",
+				repository, filepath, err)
+		}
+	}
+
+	for i := range policy.Rules {
+		if err = policy.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid rule %s in namespace policy %s: %v",
+				&policy.Rules[i], filepath, err)
+		}
+	}
+
+	return &policy, nil
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPublish returns an error if the policy doesn't permit the volume
+// to be published. Nil repositories stand for the whole autofs-managed CVMFS
+// root. The rules don't reject these, and instead select the repositories
+// that are exposed, see Server.exposeAutofsRoot.
+func (p *namespacePolicy) checkPublish(repositories []string, volCtx *volumeContext, volAttrs map[string]string) error {
+	if repositories == nil {
+		return p.checkNamespace("", volCtx.pod.namespace)
+	}
+
+	for _, repository := range repositories {
+		if err := p.checkNamespace(repository, volCtx.pod.namespace); err != nil {
+			return err
+		}
+
+		if err := p.checkRule(repository, volCtx, volAttrs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkNamespace returns an error if Pods in namespace are not allowed
+// to mount the repository. Empty repository means the whole autofs-managed
+// CVMFS root, giving access to all repositories, and so the namespace must
+// be allowed for every repository listed in the policy.
+func (p *namespacePolicy) checkNamespace(repository, namespace string) error {
+	if len(p.Repositories) == 0 {
+		return nil
+	}
+
+	if namespace == "" {
+		return fmt.Errorf("pod namespace is unknown, make sure podInfoOnMount is enabled in the CSIDriver object")
+	}
+
+	if repository != "" {
+		if !p.isAllowed(repository, namespace) {
+			return fmt.Errorf("pods in namespace %s are not allowed to mount repository %s", namespace, repository)
+		}
+
+		return nil
+	}
+
+	repositories := make([]string, 0, len(p.Repositories))
+	for repository := range p.Repositories {
+		repositories = append(repositories, repository)
+	}
+	sort.Strings(repositories)
+
+	for _, repository := range repositories {
+		if !p.isAllowed(repository, namespace) {
+			return fmt.Errorf("pods in namespace %s are not allowed to mount all repositories: repository %s is restricted",
+				namespace, repository)
+		}
+	}
+
+	return nil
+}
+
+func (p *namespacePolicy) isAllowed(repository, namespace string) bool {
+	patterns, ok := p.Repositories[repository]
+	if !ok {
+		return true
+	}
+
+	return matchAny(patterns, namespace)
+}
+
+// checkRule returns an error if the rules don't permit the volume
+// to expose the repository.
+func (p *namespacePolicy) checkRule(repository string, volCtx *volumeContext, volAttrs map[string]string) error {
+	if len(p.Rules) == 0 {
+		return nil
+	}
+
+	r, err := p.ruleFor(volCtx, volAttrs)
+	if err != nil {
+		return err
+	}
+
+	if !r.permits(repository) {
+		return fmt.Errorf("repository %s is not permitted by rule %s in namespace policy", repository, r)
+	}
+
+	return nil
+}
+
+// checkStage returns an error if the rules don't permit the volume to expose
+// the repository in any namespace. This is used when the Pod is not known yet,
+// i.e. in NodeStageVolume. Only persistent volumes are staged, so the volume
+// attributes are set by the cluster admin.
+func (p *namespacePolicy) checkStage(repository string, volAttrs map[string]string) error {
+	if len(p.Rules) == 0 {
+		return nil
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+
+		if !r.matchesVolumeAttributes(false, volAttrs) {
+			continue
+		}
+
+		if r.dependsOnPod() {
+			// The rule may or may not match once the Pod is known.
+			if r.permits(repository) {
+				return nil
+			}
+
+			continue
+		}
+
+		// The rule matches regardless of the Pod, and so it decides.
+		if !r.permits(repository) {
+			return fmt.Errorf("repository %s is not permitted by rule %s in namespace policy", repository, r)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("repository %s is not permitted by any rule in namespace policy", repository)
+}
+
+// ruleFor returns the first rule that matches the volume, or an error
+// if there is none.
+func (p *namespacePolicy) ruleFor(volCtx *volumeContext, volAttrs map[string]string) (*namespacePolicyRule, error) {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+
+		if !r.matchesPod(&volCtx.pod) {
+			continue
+		}
+
+		if !r.matchesVolumeAttributes(volCtx.ephemeral, volAttrs) {
+			continue
+		}
+
+		return r, nil
+	}
+
+	if volCtx.pod.namespace == "" {
+		return nil, errors.New("volume is not permitted by any rule in namespace policy")
+	}
+
+	return nil, fmt.Errorf("volume in namespace %s is not permitted by any rule in namespace policy", volCtx.pod.namespace)
+}
+
+func (r *namespacePolicyRule) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+
+	return fmt.Sprintf("(allow %v, deny %v)", r.Allow, r.Deny)
+}
+
+func (r *namespacePolicyRule) validate() error {
+	for k := range r.VolumeAttributes {
+		// These are added by kubelet from Pod information, and some of them,
+		// e.g. the Pod name, are chosen by the Pod author.
+		if strings.HasPrefix(k, podInfoKeyPrefix) {
+			return fmt.Errorf("volume attribute %s cannot be used in rules, use namespaces or serviceAccounts instead", k)
+		}
+	}
+
+	for _, patterns := range [][]string{r.Namespaces, r.ServiceAccounts, r.Allow, r.Deny} {
+		if err := validatePatterns(patterns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *namespacePolicyRule) matchesVolumeAttributes(ephemeral bool, volAttrs map[string]string) bool {
+	if len(r.VolumeAttributes) > 0 && ephemeral {
+		return false
+	}
+
+	for k, v := range r.VolumeAttributes {
+		if volAttrs[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dependsOnPod returns true if the rule selects volumes by the Pod
+// the volume is published to.
+func (r *namespacePolicyRule) dependsOnPod() bool {
+	return len(r.Namespaces) > 0 || len(r.ServiceAccounts) > 0
+}
+
+// matchesPod returns true if the rule matches the Pod. Rules with
+// namespace or service account selectors never match if the Pod
+// information is missing.
+func (r *namespacePolicyRule) matchesPod(pod *podInfo) bool {
+	if len(r.Namespaces) > 0 && (pod.namespace == "" || !matchAny(r.Namespaces, pod.namespace)) {
+		return false
+	}
+
+	if len(r.ServiceAccounts) > 0 && (pod.namespace == "" || pod.serviceAccount == "" ||
+		!matchAny(r.ServiceAccounts, pod.namespace+"/"+pod.serviceAccount)) {
+		return false
+	}
+
+	return true
+}
+
+// permits returns true if the rule allows the repository.
+func (r *namespacePolicyRule) permits(repository string) bool {
+	return matchAny(r.Allow, repository) && !matchAny(r.Deny, repository)
+}
+
+// unrestricted returns true if the rule allows all repositories.
+func (r *namespacePolicyRule) unrestricted() bool {
+	return slices.Contains(r.Allow, "*") && len(r.Deny) == 0
+}
+
+// permittedRepositories returns repositories that may be exposed under
+// the autofs-managed CVMFS root. Glob patterns cannot be enumerated, so
+// only repositories listed in Allow by name, and repositories currently
+// present in cvmfsRoot and matching Allow patterns, are returned.
+func (r *namespacePolicyRule) permittedRepositories(cvmfsRoot string) ([]string, error) {
+	candidates := make(map[string]struct{})
+
+	for _, pattern := range r.Allow {
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			candidates[pattern] = struct{}{}
+		}
+	}
+
+	entries, err := os.ReadDir(cvmfsRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		candidates[entry.Name()] = struct{}{}
+	}
+
+	var repositories []string
+	for repository := range candidates {
+		if r.permits(repository) {
+			repositories = append(repositories, repository)
+		}
+	}
+
+	slices.Sort(repositories)
+
+	return repositories, nil
+}
@@ -0,0 +1,370 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writePolicyFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+const testNamespacePolicy = `{
+  "repositories": {
+    "atlas.cern.ch": ["atlas-*", "batch"]
+  },
+  "rules": [
+    {
+      "name": "atlas",
+      "namespaces": ["atlas-*"],
+      "allow": ["atlas*.cern.ch", "sft.cern.ch"],
+      "deny": ["atlas-condb.cern.ch"]
+    },
+    {
+      "name": "prefetch",
+      "serviceAccounts": ["*/cvmfs-prefetch"],
+      "allow": ["*"]
+    },
+    {
+      "name": "restricted-class",
+      "volumeAttributes": {"policyClass": "restricted"},
+      "allow": ["sft.cern.ch"]
+    },
+    {
+      "name": "default",
+      "allow": ["cms.cern.ch", "sft.cern.ch"]
+    }
+  ]
+}`
+
+func TestLoadNamespacePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr bool
+	}{
+		{name: "empty", policy: `{}`},
+		{name: "example", policy: testNamespacePolicy},
+		{name: "no namespaces", policy: `{"repositories": {"atlas.cern.ch": []}}`},
+		{name: "invalid repositories", policy: `{"repositories": {"atlas.cern.ch": "atlas"}}`, wantErr: true},
+		{name: "invalid rules", policy: `{"rules": {}}`, wantErr: true},
+		{name: "invalid repository namespace pattern", policy: `{"repositories": {"atlas.cern.ch": ["atlas-["]}}`, wantErr: true},
+		{name: "invalid rule namespace pattern", policy: `{"rules": [{"namespaces": ["["], "allow": ["*"]}]}`, wantErr: true},
+		{name: "invalid service account pattern", policy: `{"rules": [{"serviceAccounts": ["ns/["], "allow": ["*"]}]}`, wantErr: true},
+		{name: "invalid allow pattern", policy: `{"rules": [{"allow": ["atlas["]}]}`, wantErr: true},
+		{name: "invalid deny pattern", policy: `{"rules": [{"allow": ["*"], "deny": ["atlas["]}]}`, wantErr: true},
+		{
+			name:    "pod info volume attribute",
+			policy:  `{"rules": [{"volumeAttributes": {"csi.storage.k8s.io/pod.namespace": "atlas"}, "allow": ["*"]}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadNamespacePolicy(writePolicyFile(t, tt.policy))
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNamespacePolicyCheckNamespace(t *testing.T) {
+	policy := &namespacePolicy{Repositories: map[string][]string{
+		"atlas.cern.ch": {"atlas-*"},
+		"cms.cern.ch":   {"cms", "cms-*"},
+		"lhcb.cern.ch":  {},
+	}}
+
+	tests := []struct {
+		name       string
+		policy     *namespacePolicy
+		repository string
+		namespace  string
+		wantErr    bool
+	}{
+		{name: "no repositories", policy: &namespacePolicy{}, repository: "atlas.cern.ch", namespace: ""},
+		{name: "unlisted repository", policy: policy, repository: "sft.cern.ch", namespace: "default"},
+		{name: "matching pattern", policy: policy, repository: "atlas.cern.ch", namespace: "atlas-prod"},
+		{name: "exact namespace", policy: policy, repository: "cms.cern.ch", namespace: "cms"},
+		{name: "unknown namespace", policy: policy, repository: "sft.cern.ch", namespace: "", wantErr: true},
+		{name: "namespace not matching", policy: policy, repository: "atlas.cern.ch", namespace: "cms", wantErr: true},
+		{name: "pattern is anchored", policy: policy, repository: "atlas.cern.ch", namespace: "x-atlas-prod", wantErr: true},
+		{name: "no namespaces allowed", policy: policy, repository: "lhcb.cern.ch", namespace: "lhcb", wantErr: true},
+		{
+			name:       "all repositories allowed",
+			policy:     &namespacePolicy{Repositories: map[string][]string{"atlas.cern.ch": {"*"}, "cms.cern.ch": {"cms", "physics"}}},
+			repository: "",
+			namespace:  "physics",
+		},
+		{name: "all repositories restricted", policy: policy, repository: "", namespace: "atlas-prod", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.checkNamespace(tt.repository, tt.namespace)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNamespacePolicyCheckRule(t *testing.T) {
+	policy, err := loadNamespacePolicy(writePolicyFile(t, testNamespacePolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restricted := map[string]string{"policyClass": "restricted"}
+
+	tests := []struct {
+		name       string
+		repository string
+		pod        podInfo
+		ephemeral  bool
+		volAttrs   map[string]string
+		wantRule   string
+		wantErr    bool
+	}{
+		{
+			name:       "namespace rule allows",
+			repository: "atlas-nightlies.cern.ch",
+			pod:        podInfo{namespace: "atlas-prod", serviceAccount: "default"},
+			wantRule:   "atlas",
+		},
+		{
+			name:       "namespace rule denies",
+			repository: "atlas-condb.cern.ch",
+			pod:        podInfo{namespace: "atlas-prod", serviceAccount: "default"},
+			wantRule:   "atlas",
+			wantErr:    true,
+		},
+		{
+			name:       "first matching rule decides",
+			repository: "cms.cern.ch",
+			pod:        podInfo{namespace: "atlas-prod", serviceAccount: "cvmfs-prefetch"},
+			wantRule:   "atlas",
+			wantErr:    true,
+		},
+		{
+			name:       "service account rule",
+			repository: "lhcb.cern.ch",
+			pod:        podInfo{namespace: "batch", serviceAccount: "cvmfs-prefetch"},
+			wantRule:   "prefetch",
+		},
+		{
+			name:       "service account in another namespace",
+			repository: "lhcb.cern.ch",
+			pod:        podInfo{namespace: "batch", serviceAccount: "default"},
+			wantRule:   "default",
+			wantErr:    true,
+		},
+		{
+			name:       "volume attributes rule",
+			repository: "cms.cern.ch",
+			pod:        podInfo{namespace: "batch", serviceAccount: "default"},
+			volAttrs:   restricted,
+			wantRule:   "restricted-class",
+			wantErr:    true,
+		},
+		{
+			name:       "volume attributes rule skips ephemeral volumes",
+			repository: "cms.cern.ch",
+			pod:        podInfo{namespace: "batch", serviceAccount: "default"},
+			ephemeral:  true,
+			volAttrs:   restricted,
+			wantRule:   "default",
+		},
+		{
+			name:       "default rule",
+			repository: "cms.cern.ch",
+			pod:        podInfo{namespace: "batch", serviceAccount: "default"},
+			volAttrs:   map[string]string{"policyClass": "other"},
+			wantRule:   "default",
+		},
+		{
+			name:       "missing pod info",
+			repository: "atlas.cern.ch",
+			wantRule:   "default",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			volCtx := &volumeContext{pod: tt.pod, ephemeral: tt.ephemeral}
+
+			r, err := policy.ruleFor(volCtx, tt.volAttrs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if r.Name != tt.wantRule {
+				t.Errorf("got rule %s, want %s", r.Name, tt.wantRule)
+			}
+
+			err = policy.checkRule(tt.repository, volCtx, tt.volAttrs)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNamespacePolicyNoMatchingRule(t *testing.T) {
+	policy := &namespacePolicy{
+		Rules: []namespacePolicyRule{{Name: "atlas", Namespaces: []string{"atlas-*"}, Allow: []string{"*"}}},
+	}
+
+	for _, pod := range []podInfo{{}, {namespace: "cms"}} {
+		if _, err := policy.ruleFor(&volumeContext{pod: pod}, nil); err == nil {
+			t.Errorf("expected error for Pod %+v", pod)
+		}
+	}
+}
+
+func TestNamespacePolicyCheckStage(t *testing.T) {
+	policy, err := loadNamespacePolicy(writePolicyFile(t, testNamespacePolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		policy     *namespacePolicy
+		repository string
+		volAttrs   map[string]string
+		wantErr    bool
+	}{
+		{name: "allowed by default rule", policy: policy, repository: "cms.cern.ch"},
+		{name: "allowed by a Pod dependent rule", policy: policy, repository: "lhcb.cern.ch"},
+		{
+			name:       "Pod dependent rule precedes volume attributes rule",
+			policy:     policy,
+			repository: "cms.cern.ch",
+			volAttrs:   map[string]string{"policyClass": "restricted"},
+		},
+		{
+			name: "denied by Pod independent rule",
+			policy: &namespacePolicy{Rules: []namespacePolicyRule{
+				{Name: "restricted", VolumeAttributes: map[string]string{"policyClass": "restricted"}, Allow: []string{"sft.cern.ch"}},
+				{Name: "atlas", Namespaces: []string{"atlas-*"}, Allow: []string{"*"}},
+			}},
+			repository: "atlas.cern.ch",
+			volAttrs:   map[string]string{"policyClass": "restricted"},
+			wantErr:    true,
+		},
+		{
+			name: "not allowed by any rule",
+			policy: &namespacePolicy{Rules: []namespacePolicyRule{
+				{Name: "atlas", Namespaces: []string{"atlas-*"}, Allow: []string{"atlas.cern.ch"}},
+			}},
+			repository: "cms.cern.ch",
+			wantErr:    true,
+		},
+		{name: "no rules", policy: &namespacePolicy{}, repository: "cms.cern.ch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.checkStage(tt.repository, tt.volAttrs)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNamespacePolicyRulePermittedRepositories(t *testing.T) {
+	cvmfsRoot := t.TempDir()
+	for _, repository := range []string{"atlas.cern.ch", "atlas-condb.cern.ch", "cms.cern.ch"} {
+		if err := os.Mkdir(filepath.Join(cvmfsRoot, repository), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &namespacePolicyRule{
+		Allow: []string{"atlas*.cern.ch", "sft.cern.ch"},
+		Deny:  []string{"atlas-condb.cern.ch"},
+	}
+
+	got, err := r.permittedRepositories(cvmfsRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"atlas.cern.ch", "sft.cern.ch"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNamespacePolicyCheckPublish(t *testing.T) {
+	policy, err := loadNamespacePolicy(writePolicyFile(t, testNamespacePolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		repositories []string
+		pod          podInfo
+		wantErr      bool
+	}{
+		{name: "permitted by both parts", repositories: []string{"atlas.cern.ch", "sft.cern.ch"}, pod: podInfo{namespace: "atlas-prod"}},
+		{name: "denied by repositories", repositories: []string{"atlas.cern.ch"}, pod: podInfo{namespace: "cms"}, wantErr: true},
+		{name: "denied by rules", repositories: []string{"atlas.cern.ch"}, pod: podInfo{namespace: "batch"}, wantErr: true},
+		{name: "one of repositories denied", repositories: []string{"sft.cern.ch", "lhcb.cern.ch"}, pod: podInfo{namespace: "atlas-prod"}, wantErr: true},
+		{name: "whole root allowed", pod: podInfo{namespace: "atlas-prod"}},
+		{name: "whole root restricted", pod: podInfo{namespace: "cms"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.checkPublish(tt.repositories, &volumeContext{pod: tt.pod}, nil)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+)
+
+// PublishRecordsDir holds a record for each volume published on this node,
+// describing which Pod has mounted which repository and where. The records
+// are named after the SHA256 digest of the volume's target path. The directory
+// must outlive the node plugin Pod, the same as the mounts it describes.
+// It is a variable only so that tests can point it to a temporary directory.
+var PublishRecordsDir = "/var/lib/cvmfs.csi.cern.ch/published"
+
+const publishRecordSuffix = ".json"
+
+// PublishRecord is written in NodePublishVolume and removed in NodeUnpublishVolume.
+// Pod information is available only if the CSIDriver object has podInfoOnMount enabled.
+type PublishRecord struct {
+	VolumeID     string
+	Repository   string
+	Repositories []string
+	SubPath      string
+	TargetPath   string
+	Ephemeral    bool
+
+	// Automounted is set when the volume is bindmounted from the autofs-CVMFS
+	// root, as opposed to being mounted by singlemount-runner.
+	Automounted bool
+
+	PodName        string
+	PodNamespace   string
+	PodUID         string
+	ServiceAccount string
+
+	PublishedAt time.Time
+
+	// Recovered is set when the record was rebuilt at startup from the
+	// mounts on the node, see RecoverPublishRecords. Pod's name, namespace
+	// and service account are not known for these.
+	Recovered bool `json:",omitempty"`
+
+	// LastRepair is set when a stale mount in the target path
+	// was replaced by the automount reconciler.
+	LastRepair *PublishRepair `json:",omitempty"`
+}
+
+func newPublishRecord(volumeID, targetPath string, volCtx *volumeContext) *PublishRecord {
+	return &PublishRecord{
+		VolumeID:       volumeID,
+		Repository:     volCtx.repository,
+		Repositories:   volCtx.repositories,
+		SubPath:        volCtx.subPath,
+		TargetPath:     targetPath,
+		Ephemeral:      volCtx.ephemeral,
+		Automounted:    !volCtx.hasVolumeConfig(),
+		PodName:        volCtx.pod.name,
+		PodNamespace:   volCtx.pod.namespace,
+		PodUID:         volCtx.pod.uid,
+		ServiceAccount: volCtx.pod.serviceAccount,
+		PublishedAt:    time.Now().UTC(),
+	}
+}
+
+func (rec *PublishRecord) String() string {
+	repository := rec.Repository
+	if len(rec.Repositories) > 0 {
+		repository = strings.Join(rec.Repositories, ",")
+	}
+	if repository == "" {
+		repository = "<all repositories>"
+	}
+	if rec.SubPath != "" {
+		repository = path.Join(repository, rec.SubPath)
+	}
+
+	return fmt.Sprintf("volume %s (repository %s) in %s for Pod %s/%s (UID %s, service account %s)",
+		rec.VolumeID, repository, rec.TargetPath,
+		rec.PodNamespace, rec.PodName, rec.PodUID, rec.ServiceAccount)
+}
+
+// logValues returns structured log fields describing the record.
+func (rec *PublishRecord) logValues() []interface{} {
+	repository := rec.Repository
+	if len(rec.Repositories) > 0 {
+		repository = strings.Join(rec.Repositories, ",")
+	}
+
+	return []interface{}{
+		log.KeyVolumeID, rec.VolumeID,
+		log.KeyRepository, repository,
+		"subPath", rec.SubPath,
+		log.KeyTargetPath, rec.TargetPath,
+		"ephemeral", rec.Ephemeral,
+		"pod", rec.PodNamespace + "/" + rec.PodName,
+		"podUID", rec.PodUID,
+		"serviceAccount", rec.ServiceAccount,
+	}
+}
+
+func fmtPublishRecordPath(targetPath string) string {
+	sum := sha256.Sum256([]byte(path.Clean(targetPath)))
+	return path.Join(PublishRecordsDir, hex.EncodeToString(sum[:])+publishRecordSuffix)
+}
+
+func writePublishRecord(rec *PublishRecord) error {
+	recJSON, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(PublishRecordsDir, 0o755); err != nil {
+		return err
+	}
+
+	recPath := fmtPublishRecordPath(rec.TargetPath)
+
+	// The directory is shared by the node plugin and automount-reconciler,
+	// and during rolling updates by two node plugin Pods. Each writer uses
+	// its own temporary file, so that records are replaced atomically.
+	tmpFile, err := os.CreateTemp(PublishRecordsDir, path.Base(recPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err = tmpFile.Write(recJSON); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err = tmpFile.Chmod(0o644); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), recPath)
+}
+
+// readPublishRecord reads the publish record for targetPath.
+// Returns (nil, nil) if there is no record.
+func readPublishRecord(targetPath string) (*PublishRecord, error) {
+	return readPublishRecordFile(fmtPublishRecordPath(targetPath))
+}
+
+func readPublishRecordFile(recPath string) (*PublishRecord, error) {
+	recJSON, err := os.ReadFile(recPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var rec PublishRecord
+	if err = json.Unmarshal(recJSON, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse publish record %s: %v", recPath, err)
+	}
+
+	return &rec, nil
+}
+
+func deletePublishRecord(targetPath string) error {
+	err := os.Remove(fmtPublishRecordPath(targetPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// ListPublishRecords returns records of all volumes
+// published on this node, sorted by target path.
+func ListPublishRecords() ([]*PublishRecord, error) {
+	entries, err := os.ReadDir(PublishRecordsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var recs []*PublishRecord
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), publishRecordSuffix) {
+			continue
+		}
+
+		rec, err := readPublishRecordFile(path.Join(PublishRecordsDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if rec != nil {
+			recs = append(recs, rec)
+		}
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].TargetPath < recs[j].TargetPath
+	})
+
+	return recs, nil
+}
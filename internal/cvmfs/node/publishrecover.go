@@ -0,0 +1,337 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+
+	"github.com/moby/sys/mountinfo"
+)
+
+// Publish records may be missing for volumes that are published, e.g. when
+// they were published by a version of the driver that didn't keep them on
+// the host. Kubelet doesn't call NodePublishVolume again for these, so we
+// rebuild the records from what's left on the node: mountinfo, kubelet's
+// vol_data.json next to the target path, and the stage record.
+
+// RestrictedRootSource is the source of the tmpfs mount holding
+// a restricted CVMFS root, see restrictedRootBind.
+const RestrictedRootSource = "cvmfs-restricted"
+
+// kubeletVolumeData is the subset of vol_data.json that kubelet
+// writes next to the target path of each CSI volume.
+type kubeletVolumeData struct {
+	SpecVolID           string `json:"specVolID"`
+	VolumeHandle        string `json:"volumeHandle"`
+	DriverName          string `json:"driverName"`
+	VolumeLifecycleMode string `json:"volumeLifecycleMode"`
+}
+
+// splitTargetPath splits a kubelet CSI target path, in the form of
+// <kubelet dir>/pods/<pod UID>/volumes/kubernetes.io~csi/<volume name>/mount.
+func splitTargetPath(targetPath string) (kubeletDir, podUID string, ok bool) {
+	parts := strings.Split(path.Clean(targetPath), "/")
+
+	n := len(parts)
+	if n < 7 || parts[n-1] != "mount" || parts[n-3] != "kubernetes.io~csi" ||
+		parts[n-4] != "volumes" || parts[n-6] != "pods" {
+		return "", "", false
+	}
+
+	return strings.Join(parts[:n-6], "/"), parts[n-5], true
+}
+
+func readKubeletVolumeData(targetPath string) (*kubeletVolumeData, error) {
+	dataJSON, err := os.ReadFile(path.Join(path.Dir(targetPath), "vol_data.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var data kubeletVolumeData
+	if err = json.Unmarshal(dataJSON, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse vol_data.json of %s: %v", targetPath, err)
+	}
+
+	return &data, nil
+}
+
+// findStageRecord returns the stage record of the volume published
+// in targetPath, or nil if there is none.
+func findStageRecord(targetPath string, volData *kubeletVolumeData) (*stageRecord, error) {
+	if volData.VolumeLifecycleMode == "Ephemeral" {
+		// Ephemeral volumes are not staged, and have the record for their target path.
+		return readStageRecord(targetPath)
+	}
+
+	kubeletDir, _, ok := splitTargetPath(targetPath)
+	if !ok {
+		return nil, nil
+	}
+
+	// Kubelet names staging paths after the SHA256 digest of the volume
+	// handle. Older versions used the PersistentVolume name.
+
+	sum := sha256.Sum256([]byte(volData.VolumeHandle))
+	csiPluginDir := path.Join(kubeletDir, "plugins", "kubernetes.io", "csi")
+
+	for _, stagingPath := range []string{
+		path.Join(csiPluginDir, volData.DriverName, hex.EncodeToString(sum[:]), "globalmount"),
+		path.Join(csiPluginDir, "pv", volData.SpecVolID, "globalmount"),
+	} {
+		rec, err := readStageRecord(stagingPath)
+		if rec != nil || err != nil {
+			return rec, err
+		}
+	}
+
+	return nil, nil
+}
+
+// isAutomountedTarget returns whether the volume published in targetPath
+// is bindmounted from the autofs-CVMFS root. Returns false if it cannot
+// be determined.
+func isAutomountedTarget(targetPath string) bool {
+	volData, err := readKubeletVolumeData(targetPath)
+	if err != nil {
+		return false
+	}
+
+	stageRec, err := findStageRecord(targetPath, volData)
+	if err != nil || stageRec == nil {
+		return false
+	}
+
+	return !stageRec.Singlemount
+}
+
+// MountedRepository returns the name of the CVMFS repository mounted in the
+// CVMFS mount info. The repository is found either by asking the CVMFS client
+// (this needs the mount to be healthy), or by looking for a mount of the same
+// filesystem in /cvmfs. Returns empty string if the repository is not found.
+func MountedRepository(info *mountinfo.Info, mounts []*mountinfo.Info) string {
+	buf := make([]byte, 256)
+	if n, err := syscall.Getxattr(info.Mountpoint, "user.fqrn", buf); err == nil {
+		return string(buf[:n])
+	}
+
+	return automountedRepository(info, mounts)
+}
+
+// automountedRepository returns the name of the repository in /cvmfs
+// that has the same filesystem as info, or empty string if there is none.
+func automountedRepository(info *mountinfo.Info, mounts []*mountinfo.Info) string {
+	for _, m := range mounts {
+		if m.Major == info.Major && m.Minor == info.Minor && path.Dir(m.Mountpoint) == cvmfsRoot {
+			return path.Base(m.Mountpoint)
+		}
+	}
+
+	return ""
+}
+
+// recoverPublishRecord rebuilds the publish record of the volume
+// mounted in info. mounts are all mounts on the node.
+func recoverPublishRecord(info *mountinfo.Info, mounts []*mountinfo.Info, driverName string) (*PublishRecord, error) {
+	_, podUID, ok := splitTargetPath(info.Mountpoint)
+	if !ok {
+		return nil, nil
+	}
+
+	volData, err := readKubeletVolumeData(info.Mountpoint)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if volData.DriverName != driverName {
+		return nil, nil
+	}
+
+	rec := &PublishRecord{
+		VolumeID:    volData.VolumeHandle,
+		TargetPath:  info.Mountpoint,
+		Ephemeral:   volData.VolumeLifecycleMode == "Ephemeral",
+		PodUID:      podUID,
+		PublishedAt: time.Now().UTC(),
+		Recovered:   true,
+	}
+
+	stageRec, err := findStageRecord(info.Mountpoint, volData)
+	if err != nil {
+		return nil, err
+	}
+
+	if stageRec != nil && stageRec.Singlemount {
+		rec.Repository = stageRec.Repository
+		rec.Repositories = stageRec.Repositories
+		rec.SubPath = stageRec.SubPath
+
+		return rec, nil
+	}
+
+	// Volumes staged by an older version of the driver have no stage
+	// record. Those that are not bindmounts of the autofs-CVMFS root
+	// or of its repositories are assumed to be singlemount volumes.
+	rec.Automounted = stageRec != nil ||
+		info.FSType == "autofs" ||
+		info.FSType == "tmpfs" && info.Source == RestrictedRootSource ||
+		info.FSType == "fuse" && automountedRepository(info, mounts) != ""
+
+	switch {
+	case info.FSType == "fuse":
+		// A single repository, or its subpath.
+		rec.Repository = MountedRepository(info, mounts)
+		if info.Root != "/" {
+			rec.SubPath = strings.TrimPrefix(info.Root, "/")
+		}
+	case info.FSType == "tmpfs" && info.Source == RestrictedRootSource:
+		// Restricted CVMFS root with the repositories mounted inside.
+		for _, m := range mounts {
+			if m.Parent == info.ID {
+				rec.Repositories = append(rec.Repositories, path.Base(m.Mountpoint))
+			}
+		}
+	}
+
+	return rec, nil
+}
+
+// RecoverPublishRecords writes publish records for volumes of driverName
+// that are mounted in kubelet's podsDir, but have no record.
+func RecoverPublishRecords(podsDir, driverName string) error {
+	mounts, err := mountinfo.GetMounts(nil)
+	if err != nil {
+		return err
+	}
+
+	podsDir = path.Clean(podsDir) + "/"
+
+	for _, info := range mounts {
+		if !strings.HasPrefix(info.Mountpoint, podsDir) || path.Base(info.Mountpoint) != "mount" {
+			continue
+		}
+
+		if rec, err := readPublishRecord(info.Mountpoint); rec != nil || err != nil {
+			continue
+		}
+
+		rec, err := recoverPublishRecord(info, mounts, driverName)
+		if err != nil {
+			log.Errorf("Failed to recover publish record for %s: %v", info.Mountpoint, err)
+			continue
+		}
+
+		if rec == nil {
+			continue
+		}
+
+		if err = writePublishRecord(rec); err != nil {
+			log.Errorf("Failed to write recovered publish record for %s: %v", info.Mountpoint, err)
+			continue
+		}
+
+		log.InfoS("Recovered publish record", rec.logValues()...)
+	}
+
+	return nil
+}
+
+// RecoverStaleMountRecord returns the publish record of the volume with the
+// stale CVMFS mount info, for when rec, the record found for the volume,
+// is nil or doesn't say the volume is mounted from the autofs-CVMFS root
+// (records written by older versions don't have Automounted). The target
+// path of a volume with a single repository is a bindmount of
+// /cvmfs/<repo>[/<subpath>]: the repository is found by matching its
+// filesystem with the mounts in /cvmfs, and so this must be called before
+// the dead mount in /cvmfs is unmounted. Repository directories in
+// a restricted CVMFS root, whose mount is parent, are always bindmounts
+// of /cvmfs/<repo>. The recovered record is written, so that it can be
+// repaired. Returns nil if the stale mount doesn't come from /cvmfs.
+func RecoverStaleMountRecord(info, parent *mountinfo.Info, mounts []*mountinfo.Info, rec *PublishRecord) (*PublishRecord, error) {
+	var recovered *PublishRecord
+
+	if parent != nil && parent.Source == RestrictedRootSource {
+		recovered = recordForStaleTarget(parent.Mountpoint, rec)
+		recovered.Repository = ""
+
+		if len(recovered.Repositories) == 0 {
+			for _, m := range mounts {
+				if m.Parent == parent.ID {
+					recovered.Repositories = append(recovered.Repositories, path.Base(m.Mountpoint))
+				}
+			}
+		}
+	} else {
+		repository := automountedRepository(info, mounts)
+		if repository == "" {
+			return nil, nil
+		}
+
+		recovered = recordForStaleTarget(info.Mountpoint, rec)
+		recovered.Repository = repository
+		recovered.SubPath = strings.TrimPrefix(info.Root, "/")
+	}
+
+	if err := writePublishRecord(recovered); err != nil {
+		return nil, err
+	}
+
+	log.InfoS("Recovered publish record of stale mount", recovered.logValues()...)
+
+	return recovered, nil
+}
+
+// recordForStaleTarget returns a copy of rec marked as automounted,
+// or a new record for targetPath if rec is nil.
+func recordForStaleTarget(targetPath string, rec *PublishRecord) *PublishRecord {
+	if rec != nil {
+		recovered := *rec
+		recovered.Automounted = true
+		return &recovered
+	}
+
+	recovered := &PublishRecord{
+		TargetPath:  targetPath,
+		Automounted: true,
+		PublishedAt: time.Now().UTC(),
+		Recovered:   true,
+	}
+
+	if _, podUID, ok := splitTargetPath(targetPath); ok {
+		recovered.PodUID = podUID
+	}
+
+	if volData, err := readKubeletVolumeData(targetPath); err == nil {
+		recovered.VolumeID = volData.VolumeHandle
+		recovered.Ephemeral = volData.VolumeLifecycleMode == "Ephemeral"
+	}
+
+	return recovered
+}
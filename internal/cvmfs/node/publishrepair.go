@@ -0,0 +1,171 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/moby/sys/mountinfo"
+)
+
+// How long after a repair NodeGetVolumeStats keeps reporting the volume
+// as abnormal. Processes that had files open in the stale mount, or had
+// it as their working directory, still see the dead CVMFS client, and
+// so the Pod may need to be restarted anyway.
+const repairedVolumeConditionPeriod = 10 * time.Minute
+
+// PublishRepair describes a stale mount in a volume's target path
+// that was replaced with a fresh bindmount from the autofs-CVMFS root.
+type PublishRepair struct {
+	// Mountpoint that was replaced. This is either the target
+	// path itself, or a repository directory inside of it.
+	Mountpoint string
+	Source     string
+	RepairedAt time.Time
+}
+
+// staleMountSource returns the path in the autofs-CVMFS root that
+// mountpoint of the volume described by rec should be bindmounted from.
+// mountpoint is either the target path itself, for volumes with
+// a single repository, or a repository directory inside of it,
+// for volumes exposing a restricted CVMFS root.
+func (rec *PublishRecord) staleMountSource(mountpoint string) (string, error) {
+	if !rec.Automounted {
+		return "", errors.New("volume is not mounted from the autofs-CVMFS root")
+	}
+
+	if mountpoint == rec.TargetPath {
+		if rec.Repository == "" {
+			return "", errors.New("volume doesn't expose a single repository")
+		}
+
+		repoRoot := path.Join(cvmfsRoot, rec.Repository)
+
+		if rec.SubPath == "" {
+			return repoRoot, nil
+		}
+
+		return resolveSubPath(repoRoot, rec.SubPath)
+	}
+
+	if path.Dir(mountpoint) == rec.TargetPath && rec.Repository == "" {
+		return path.Join(cvmfsRoot, path.Base(mountpoint)), nil
+	}
+
+	return "", fmt.Errorf("%s is not a repository mountpoint of the volume", mountpoint)
+}
+
+// RepairStaleMount replaces the stale mount in mountpoint, a target path
+// or a repository directory inside of it, with a fresh bindmount from
+// the autofs-CVMFS root, and records the repair in the publish record.
+func RepairStaleMount(ctx context.Context, rec *PublishRecord, mountpoint string) error {
+	source, err := rec.staleMountSource(mountpoint)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the volume wasn't unpublished in the meantime.
+	if current, err := readPublishRecord(rec.TargetPath); err != nil || current == nil {
+		return fmt.Errorf("volume in %s is not published anymore", rec.TargetPath)
+	}
+
+	// Accessing the source triggers the automount. Do this before
+	// touching the stale mount, so that we don't leave the target
+	// path empty if the repository can't be mounted.
+	if _, err = os.Stat(source); err != nil {
+		return fmt.Errorf("failed to access %s: %v", source, err)
+	}
+
+	// The stale mount is most likely busy, as the Pod is still using it.
+	// Detach it, so that new lookups see the new bindmount.
+	if err = syscall.Unmount(mountpoint, syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to unmount stale mount: %v", err)
+	}
+
+	if mountpoint == rec.TargetPath {
+		err = bindMount(ctx, source, mountpoint)
+	} else {
+		err = slaveRecursiveBind(ctx, source, mountpoint)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to bindmount %s: %v", source, err)
+	}
+
+	rec.LastRepair = &PublishRepair{
+		Mountpoint: mountpoint,
+		Source:     source,
+		RepairedAt: time.Now().UTC(),
+	}
+
+	if err = writePublishRecord(rec); err != nil {
+		return fmt.Errorf("failed to record the repair: %v", err)
+	}
+
+	log.InfoS("Repaired stale mount in volume target path",
+		append(rec.logValues(), "mountpoint", mountpoint, "source", source)...)
+
+	return nil
+}
+
+// corruptedMountInside returns the first corrupted CVMFS mount nested
+// in volumePath, e.g. a repository of a restricted CVMFS root.
+func corruptedMountInside(volumePath string) (string, error) {
+	prefix := strings.TrimSuffix(volumePath, "/") + "/"
+
+	infos, err := mountinfo.GetMounts(func(info *mountinfo.Info) (skip, stop bool) {
+		return info.FSType != "fuse" || !strings.HasPrefix(info.Mountpoint, prefix), false
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, info := range infos {
+		var st syscall.Stat_t
+		if err := syscall.Stat(info.Mountpoint, &st); errors.Is(err, syscall.ENOTCONN) {
+			return info.Mountpoint, nil
+		}
+	}
+
+	return "", nil
+}
+
+// repairedVolumeCondition returns abnormal volume condition if a stale
+// mount in the volume was recently replaced, nil otherwise.
+func repairedVolumeCondition(volumePath string) *csi.VolumeCondition {
+	rec, err := readPublishRecord(volumePath)
+	if err != nil || rec == nil || rec.LastRepair == nil ||
+		time.Since(rec.LastRepair.RepairedAt) > repairedVolumeConditionPeriod {
+		return nil
+	}
+
+	return &csi.VolumeCondition{
+		Abnormal: true,
+		Message: fmt.Sprintf("stale mount %s was replaced at %s, processes that were using it "+
+			"need to be restarted", rec.LastRepair.Mountpoint, rec.LastRepair.RepairedAt.Format(time.RFC3339)),
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"strings"
+)
+
+// stageRecord is written during NodeStageVolume and describes how the volume
+// was staged. NodeUnstageVolume doesn't get the volume context in its request,
+// so this is the only way for it to know whether singlemount-runner needs to
+// be involved at all. Ephemeral inline volumes are not staged, and instead
+// have the record written for their target path in NodePublishVolume.
+//
+// The record cannot be stored inside the staging directory, because a
+// singlemount volume is mounted over it. Instead it's stored next to it,
+// in the per-volume directory that the CO creates for the staging path.
+type stageRecord struct {
+	VolumeID string
+
+	// Whether the volume was mounted by singlemount-runner
+	// into the staging path.
+	Singlemount bool
+
+	// Fields below are set only for singlemount volumes.
+
+	MountID              string
+	Repository           string
+	Repositories         []string
+	SubPath              string
+	ClientConfigDigest   string
+	ClientConfigFilepath string
+	ConfigParameters     map[string]string
+}
+
+const stageRecordSuffix = ".cvmfs-stage.json"
+
+func fmtStageRecordPath(stagingPath string) string {
+	return path.Clean(stagingPath) + stageRecordSuffix
+}
+
+func newStageRecord(volumeID string, volCtx *volumeContext) *stageRecord {
+	rec := &stageRecord{
+		VolumeID:    volumeID,
+		Singlemount: volCtx.hasVolumeConfig(),
+	}
+
+	if rec.Singlemount {
+		rec.MountID = volCtx.sharedMountID
+		rec.Repository = volCtx.repository
+		rec.Repositories = volCtx.repositories
+		rec.SubPath = volCtx.subPath
+		rec.ClientConfigDigest = digestClientConfig(volCtx.clientConfig)
+		rec.ClientConfigFilepath = volCtx.clientConfigFilepath
+		rec.ConfigParameters = volCtx.configParameters()
+	}
+
+	return rec
+}
+
+func digestClientConfig(config string) string {
+	if config == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeStageRecord stores the record for stagingPath. The file is written
+// into a temporary file first and then renamed, so that a crash mid-write
+// cannot leave behind a truncated record.
+func writeStageRecord(stagingPath string, rec *stageRecord) error {
+	recJSON, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	recPath := fmtStageRecordPath(stagingPath)
+	tmpPath := recPath + ".tmp"
+
+	if err = os.WriteFile(tmpPath, recJSON, 0o644); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, recPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// readStageRecord reads the stage record for stagingPath.
+// Returns (nil, nil) if there is no record, e.g. because the volume
+// was staged by an older version of the driver.
+func readStageRecord(stagingPath string) (*stageRecord, error) {
+	recJSON, err := os.ReadFile(fmtStageRecordPath(stagingPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var rec stageRecord
+	if err = json.Unmarshal(recJSON, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse stage record for %s: %v", stagingPath, err)
+	}
+
+	return &rec, nil
+}
+
+func deleteStageRecord(stagingPath string) error {
+	err := os.Remove(fmtStageRecordPath(stagingPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// checkMatches returns an error if the volume described by volumeID and volCtx
+// is not the same volume that was staged. Values in the record are the expected
+// ones, and values derived from the request are the actual ones.
+func (rec *stageRecord) checkMatches(volumeID string, volCtx *volumeContext) error {
+	actual := newStageRecord(volumeID, volCtx)
+
+	checks := []struct {
+		name     string
+		expected string
+		actual   string
+	}{
+		{"volume ID", rec.VolumeID, actual.VolumeID},
+		{"singlemount", fmt.Sprint(rec.Singlemount), fmt.Sprint(actual.Singlemount)},
+		{"mount ID", rec.MountID, actual.MountID},
+		{"repository", rec.Repository, actual.Repository},
+		{"repositories", strings.Join(rec.Repositories, ","), strings.Join(actual.Repositories, ",")},
+		{"subPath", rec.SubPath, actual.SubPath},
+		{"clientConfig", rec.ClientConfigDigest, actual.ClientConfigDigest},
+		{"clientConfigFilepath", rec.ClientConfigFilepath, actual.ClientConfigFilepath},
+	}
+
+	for _, c := range checks {
+		if c.expected != c.actual {
+			return fmt.Errorf("%s mismatch: expected %q from stage record, got %q", c.name, c.expected, c.actual)
+		}
+	}
+
+	// Config parameters come from both clientConfigOverrides and the snapshot
+	// parameters, and so they are reported together as client config.
+	if !maps.Equal(rec.ConfigParameters, actual.ConfigParameters) {
+		return fmt.Errorf("client config parameters mismatch: expected %v from stage record, got %v",
+			rec.ConfigParameters, actual.ConfigParameters)
+	}
+
+	return nil
+}
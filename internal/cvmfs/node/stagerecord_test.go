@@ -0,0 +1,127 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"testing"
+)
+
+func TestStageRecordCheckMatches(t *testing.T) {
+	stagedCtx := &volumeContext{
+		repository:    "atlas.cern.ch",
+		clientConfig:  "CVMFS_HTTP_PROXY=DIRECT",
+		sharedMountID: "vol-1",
+	}
+
+	rec := newStageRecord("vol-1", stagedCtx)
+
+	tests := []struct {
+		name     string
+		volumeID string
+		volCtx   *volumeContext
+		wantErr  string
+	}{
+		{name: "same volume", volumeID: "vol-1", volCtx: stagedCtx},
+		{
+			name:     "different volume ID",
+			volumeID: "vol-2",
+			volCtx:   stagedCtx,
+			wantErr:  `volume ID mismatch: expected "vol-1" from stage record, got "vol-2"`,
+		},
+		{
+			name:     "different repository",
+			volumeID: "vol-1",
+			volCtx: &volumeContext{
+				repository:    "cms.cern.ch",
+				clientConfig:  "CVMFS_HTTP_PROXY=DIRECT",
+				sharedMountID: "vol-1",
+			},
+			wantErr: `repository mismatch: expected "atlas.cern.ch" from stage record, got "cms.cern.ch"`,
+		},
+		{
+			name:     "different client config overrides",
+			volumeID: "vol-1",
+			volCtx: &volumeContext{
+				repository:            "atlas.cern.ch",
+				clientConfig:          "CVMFS_HTTP_PROXY=DIRECT",
+				clientConfigOverrides: map[string]string{"CVMFS_QUOTA_LIMIT": "1000"},
+				sharedMountID:         "vol-1",
+			},
+			wantErr: `client config parameters mismatch: expected map[] from stage record, got map[CVMFS_QUOTA_LIMIT:1000]`,
+		},
+		{
+			name:     "automounted volume",
+			volumeID: "vol-1",
+			volCtx:   &volumeContext{repository: "atlas.cern.ch"},
+			wantErr:  `singlemount mismatch: expected "true" from stage record, got "false"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rec.checkMatches(tt.volumeID, tt.volCtx)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStageRecordReadWrite(t *testing.T) {
+	stagingPath := t.TempDir() + "/globalmount"
+
+	rec, err := readStageRecord(stagingPath)
+	if err != nil || rec != nil {
+		t.Fatalf("got (%v, %v) for missing record, want (nil, nil)", rec, err)
+	}
+
+	want := newStageRecord("vol-1", &volumeContext{
+		repository:    "atlas.cern.ch",
+		clientConfig:  "CVMFS_HTTP_PROXY=DIRECT",
+		sharedMountID: "shared",
+	})
+
+	if err = writeStageRecord(stagingPath, want); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, err = readStageRecord(stagingPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = rec.checkMatches("vol-1", &volumeContext{
+		repository:    "atlas.cern.ch",
+		clientConfig:  "CVMFS_HTTP_PROXY=DIRECT",
+		sharedMountID: "shared",
+	}); err != nil {
+		t.Errorf("record read back doesn't match: %v", err)
+	}
+
+	if err = deleteStageRecord(stagingPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec, err = readStageRecord(stagingPath); err != nil || rec != nil {
+		t.Errorf("got (%v, %v) after delete, want (nil, nil)", rec, err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resolveSubPath resolves subPath inside of the repository mounted in root.
+// Repositories may contain symlinks, and these are followed, but the resolved
+// path must not point outside of root. This is to prevent symlinks in the
+// repository from exposing arbitrary paths of the node plugin's filesystem.
+func resolveSubPath(root, subPath string) (string, error) {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to resolve %s: %v", root, err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(path.Join(resolvedRoot, subPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", status.Errorf(codes.NotFound, "path %s does not exist in the repository", subPath)
+		}
+
+		return "", status.Errorf(codes.Internal, "failed to resolve %s in %s: %v", subPath, root, err)
+	}
+
+	if resolved != resolvedRoot && !strings.HasPrefix(resolved, resolvedRoot+"/") {
+		return "", status.Errorf(codes.InvalidArgument,
+			"path %s resolves to %s, which is outside of the repository", subPath, resolved)
+	}
+
+	fi, err := os.Stat(resolved)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to stat %s: %v", resolved, err)
+	}
+
+	if !fi.IsDir() {
+		return "", status.Errorf(codes.InvalidArgument, "path %s in the repository is not a directory", subPath)
+	}
+
+	return resolved, nil
+}
+
+// bindSubPath bindmounts subPath of the repository mounted in root into to.
+// Whole root is bindmounted if subPath is empty.
+func bindSubPath(ctx context.Context, root, subPath, to string) error {
+	if subPath == "" {
+		return bindMount(ctx, root, to)
+	}
+
+	source, err := resolveSubPath(root, subPath)
+	if err != nil {
+		return err
+	}
+
+	return bindMount(ctx, source, to)
+}
@@ -18,18 +18,68 @@ package node
 
 import (
 	"fmt"
+	"maps"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/clientconfig"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/repolist"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/snapshot"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/subpath"
+)
+
+// Keys set by kubelet in volume context when
+// CSIDriver.spec.podInfoOnMount is enabled.
+const (
+	ephemeralVolumeKey   = "csi.storage.k8s.io/ephemeral"
+	podNameKey           = "csi.storage.k8s.io/pod.name"
+	podNamespaceKey      = "csi.storage.k8s.io/pod.namespace"
+	podUIDKey            = "csi.storage.k8s.io/pod.uid"
+	podServiceAccountKey = "csi.storage.k8s.io/serviceAccount.name"
+
+	podInfoKeyPrefix = "csi.storage.k8s.io/"
 )
 
+// podInfo describes the Pod for which the volume is being published.
+// Empty if CSIDriver.spec.podInfoOnMount is not enabled.
+type podInfo struct {
+	name           string
+	namespace      string
+	uid            string
+	serviceAccount string
+}
+
 type volumeContext struct {
+	pod podInfo
+
+	// Whether this is an ephemeral inline volume. These are
+	// not staged, and the whole lifecycle of the volume is
+	// handled in NodePublishVolume and NodeUnpublishVolume.
+	ephemeral bool
+
 	// Explicit repository to mount.
 	repository string
 
+	// Repositories of a multi-repository volume. Each repository is
+	// mounted in its own subdirectory of the volume. Mutually exclusive
+	// with repository.
+	repositories []string
+
+	// Path inside the repository to publish, instead of the whole
+	// repository. Relative to the repository root.
+	subPath string
+
 	// CVMFS client configuration.
 	clientConfig string
 
 	// File for sourcing CVMFS client configuration.
 	clientConfigFilepath string
 
+	// Per-volume CVMFS client config parameters, layered
+	// on top of clientConfig or clientConfigFilepath.
+	clientConfigOverrides map[string]string
+
+	// Snapshot of the repository to mount. Nil means the latest revision.
+	snapshot *snapshot.Snapshot
+
 	// A mount can be shared between multiple logical CSI volumes,
 	// saving on resources on the node. If none is provided,
 	// volume ID is used as a default value.
@@ -44,10 +94,20 @@ func newVolumeContext(m map[string]string, volumeID string) (*volumeContext, err
 		sharedMountIDKey        = "sharedMountID"
 	)
 
+	repositories, err := repolist.FromVolumeParameters(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if repositories != nil && m[repositoryKey] != "" {
+		return nil, fmt.Errorf("only one of %s and %s may be defined",
+			repositoryKey, repolist.Key)
+	}
+
 	if (m[clientConfigKey] != "" || m[clientConfigFilepathKey] != "") &&
-		m[repositoryKey] == "" {
-		return nil, fmt.Errorf("%s must be set too when specifying %s",
-			repositoryKey, clientConfigKey)
+		m[repositoryKey] == "" && repositories == nil {
+		return nil, fmt.Errorf("%s or %s must be set too when specifying %s",
+			repositoryKey, repolist.Key, clientConfigKey)
 	}
 
 	if m[clientConfigKey] != "" && m[clientConfigFilepathKey] != "" {
@@ -55,18 +115,118 @@ func newVolumeContext(m map[string]string, volumeID string) (*volumeContext, err
 			clientConfigKey, clientConfigFilepathKey)
 	}
 
-	if m[clientConfigKey] != "" && m[sharedMountIDKey] == "" {
-		m[sharedMountIDKey] = volumeID
+	// Attributes of ephemeral inline volumes are set by the Pod author.
+	// Config files on the node are meant to be selected by the admin
+	// in the StorageClass, and not by anyone who can create Pods.
+	if m[ephemeralVolumeKey] == "true" && m[clientConfigFilepathKey] != "" {
+		return nil, fmt.Errorf("%s is not allowed in ephemeral inline volumes", clientConfigFilepathKey)
+	}
+
+	overrides, err := clientconfig.ParseMap(m[clientconfig.OverridesKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", clientconfig.OverridesKey, err)
+	}
+
+	if len(overrides) > 0 && m[repositoryKey] == "" && repositories == nil {
+		return nil, fmt.Errorf("%s or %s must be set too when specifying %s",
+			repositoryKey, repolist.Key, clientconfig.OverridesKey)
+	}
+
+	snap, err := snapshot.FromVolumeParameters(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if snap != nil && m[repositoryKey] == "" {
+		return nil, fmt.Errorf("%s must be set too when specifying %s, %s or %s",
+			repositoryKey, snapshot.TagKey, snapshot.HashKey, snapshot.RevisionKey)
 	}
 
-	return &volumeContext{
-		repository:           m[repositoryKey],
-		clientConfig:         m[clientConfigKey],
-		clientConfigFilepath: m[clientConfigFilepathKey],
-		sharedMountID:        m[sharedMountIDKey],
-	}, nil
+	subPath, err := subpath.FromVolumeParameters(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if subPath != "" && m[repositoryKey] == "" {
+		return nil, fmt.Errorf("%s must be set too when specifying %s",
+			repositoryKey, subpath.Key)
+	}
+
+	volCtx := &volumeContext{
+		pod: podInfo{
+			name:           m[podNameKey],
+			namespace:      m[podNamespaceKey],
+			uid:            m[podUIDKey],
+			serviceAccount: m[podServiceAccountKey],
+		},
+		ephemeral:             m[ephemeralVolumeKey] == "true",
+		repository:            m[repositoryKey],
+		repositories:          repositories,
+		subPath:               subPath,
+		clientConfig:          m[clientConfigKey],
+		clientConfigFilepath:  m[clientConfigFilepathKey],
+		clientConfigOverrides: overrides,
+		snapshot:              snap,
+		sharedMountID:         m[sharedMountIDKey],
+	}
+
+	if volCtx.hasVolumeConfig() && volCtx.sharedMountID == "" {
+		volCtx.sharedMountID = volumeID
+	}
+
+	return volCtx, nil
 }
 
+// hasVolumeConfig returns true if the volume needs its own CVMFS client
+// config, and so it must be mounted by singlemount-runner. Pinned snapshots
+// are set in the client config too.
 func (volCtx *volumeContext) hasVolumeConfig() bool {
-	return volCtx.clientConfig != "" || volCtx.clientConfigFilepath != ""
+	return volCtx.clientConfig != "" || volCtx.clientConfigFilepath != "" ||
+		len(volCtx.clientConfigOverrides) > 0 || volCtx.snapshot != nil
+}
+
+// repositoryList returns repositories exposed by the volume.
+// Returns nil if the volume exposes the whole autofs-managed CVMFS root.
+func (volCtx *volumeContext) repositoryList() []string {
+	if volCtx.repository != "" {
+		return []string{volCtx.repository}
+	}
+
+	return volCtx.repositories
+}
+
+// forRepository returns volume context for mounting a single repository
+// of a multi-repository volume. Each repository gets its own shared mount,
+// with mount ID derived from the volume's shared mount ID.
+func (volCtx *volumeContext) forRepository(repository string) *volumeContext {
+	repoVolCtx := *volCtx
+	repoVolCtx.repository = repository
+	repoVolCtx.repositories = nil
+	repoVolCtx.sharedMountID = fmtRepositoryMountID(volCtx.sharedMountID, repository)
+
+	return &repoVolCtx
+}
+
+func fmtRepositoryMountID(sharedMountID, repository string) string {
+	return sharedMountID + "-" + repository
+}
+
+// configParameters returns CVMFS client config parameters that
+// are passed to singlemount-runner on top of the client config.
+// Parameters of the pinned snapshot take precedence over overrides.
+func (volCtx *volumeContext) configParameters() map[string]string {
+	if volCtx.snapshot == nil && len(volCtx.clientConfigOverrides) == 0 {
+		return nil
+	}
+
+	params := maps.Clone(volCtx.clientConfigOverrides)
+	if params == nil {
+		params = make(map[string]string)
+	}
+
+	if volCtx.snapshot != nil {
+		maps.Copy(params, volCtx.snapshot.ClientConfigParameters())
+	}
+
+	return params
 }
@@ -0,0 +1,61 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package node
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// getVolumeUsage returns bytes and inodes usage of the filesystem mounted
+// in volumePath. cvmfs2 reports the size of its cache through statfs:
+// total is the cache quota limit and used is the amount of data currently
+// cached. The autofs-CVMFS root itself doesn't report any usage, so volumes
+// exposing the whole /cvmfs will return zeros.
+func getVolumeUsage(volumePath string) ([]*csi.VolumeUsage, error) {
+	statfs := syscall.Statfs_t{}
+	if err := syscall.Statfs(volumePath, &statfs); err != nil {
+		return nil, fmt.Errorf("statfs failed: %v", err)
+	}
+
+	bsize := int64(statfs.Bsize)
+
+	return []*csi.VolumeUsage{
+		{
+			Unit:      csi.VolumeUsage_BYTES,
+			Total:     int64(statfs.Blocks) * bsize,
+			Available: int64(statfs.Bavail) * bsize,
+			Used:      int64(statfs.Blocks-statfs.Bfree) * bsize,
+		},
+		{
+			Unit:      csi.VolumeUsage_INODES,
+			Total:     int64(statfs.Files),
+			Available: int64(statfs.Ffree),
+			Used:      int64(statfs.Files - statfs.Ffree),
+		},
+	}, nil
+}
+
+func abnormalVolumeCondition(mountpoint string) *csi.VolumeCondition {
+	return &csi.VolumeCondition{
+		Abnormal: true,
+		Message: fmt.Sprintf("mountpoint %s is corrupted (the CVMFS client has likely exited), "+
+			"the volume needs to be remounted", mountpoint),
+	}
+}
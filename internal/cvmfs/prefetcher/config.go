@@ -0,0 +1,155 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package prefetcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Config lists prefetching jobs. It's read from a JSON file, e.g.:
+//
+//	{
+//	  "jobs": [
+//	    {
+//	      "name": "lcg-104",
+//	      "repository": "sft.cern.ch",
+//	      "paths": ["lcg/views/LCG_104/x86_64-el9-gcc13-opt/lib/**/*.so"],
+//	      "period": "1h"
+//	    }
+//	  ]
+//	}
+type Config struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// Job describes which files of a repository should be read
+// in order to keep them in the local CVMFS cache.
+type Job struct {
+	// Unique name of the job, used in logs and metrics.
+	Name string `json:"name"`
+
+	// Repository to prefetch, e.g. atlas.cern.ch.
+	Repository string `json:"repository"`
+
+	// Path patterns relative to the repository root. Path elements are
+	// matched as in path.Match, and additionally "**" matches any number
+	// of directories. Matched directories are prefetched whole. At least
+	// one pattern is required, use "**" to prefetch the whole repository.
+	Paths []string `json:"paths"`
+
+	// How often to run the job, as a Go duration string, e.g. "30m".
+	// Defaults to the period set in Opts.
+	Period string `json:"period,omitempty"`
+
+	// Stop the run after reading this many bytes. 0 means no limit.
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+
+	period time.Duration
+}
+
+func loadConfig(filepath string, defaultPeriod time.Duration) (*Config, error) {
+	configJSON, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err = json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse prefetcher config %s: %v", filepath, err)
+	}
+
+	names := make(map[string]struct{}, len(config.Jobs))
+
+	for i := range config.Jobs {
+		job := &config.Jobs[i]
+
+		if err = job.validate(defaultPeriod); err != nil {
+			return nil, fmt.Errorf("invalid prefetcher job #%d %q: %v", i, job.Name, err)
+		}
+
+		if _, ok := names[job.Name]; ok {
+			return nil, fmt.Errorf("prefetcher job %q is defined more than once", job.Name)
+		}
+		names[job.Name] = struct{}{}
+	}
+
+	return &config, nil
+}
+
+func (j *Job) validate(defaultPeriod time.Duration) error {
+	if j.Name == "" {
+		return errors.New("name must be set")
+	}
+
+	if j.Repository == "" || strings.Contains(j.Repository, "/") || strings.HasPrefix(j.Repository, ".") {
+		return fmt.Errorf("invalid repository name %q", j.Repository)
+	}
+
+	if len(j.Paths) == 0 {
+		return errors.New(`paths must be set, use ["**"] to prefetch the whole repository`)
+	}
+
+	for _, p := range j.Paths {
+		if err := validatePathPattern(p); err != nil {
+			return err
+		}
+	}
+
+	if j.MaxBytes < 0 {
+		return errors.New("maxBytes must not be negative")
+	}
+
+	j.period = defaultPeriod
+	if j.Period != "" {
+		period, err := time.ParseDuration(j.Period)
+		if err != nil {
+			return fmt.Errorf("invalid period: %v", err)
+		}
+		j.period = period
+	}
+
+	if j.period <= 0 {
+		return errors.New("period must be positive")
+	}
+
+	return nil
+}
+
+func validatePathPattern(pattern string) error {
+	if pattern == "" || path.IsAbs(pattern) {
+		return fmt.Errorf("path pattern %q must be relative to the repository root", pattern)
+	}
+
+	for _, elem := range strings.Split(pattern, "/") {
+		if elem == ".." {
+			return fmt.Errorf("path pattern %q must not contain '..'", pattern)
+		}
+
+		// Check the pattern is well-formed.
+		if _, err := path.Match(elem, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %v", pattern, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package prefetcher
+
+import (
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "prefetcher"
+
+var (
+	runs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "runs_total",
+			Help:      "Number of prefetching job runs.",
+		},
+		[]string{"job"},
+	)
+
+	runFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "run_failures_total",
+			Help:      "Number of prefetching job runs that failed to walk the repository.",
+		},
+		[]string{"job"},
+	)
+
+	runDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "run_duration_seconds",
+			Help:      "Duration of prefetching job runs.",
+			Buckets:   []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+		},
+		[]string{"job"},
+	)
+
+	filesFetched = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "files_total",
+			Help:      "Number of prefetched files.",
+		},
+		[]string{"job"},
+	)
+
+	bytesFetched = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "bytes_total",
+			Help:      "Number of bytes read from prefetched files.",
+		},
+		[]string{"job"},
+	)
+
+	lastRunBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "last_run_bytes",
+			Help:      "Number of bytes read by the last run of the prefetching job.",
+		},
+		[]string{"job"},
+	)
+
+	failures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "failures_total",
+			Help:      "Number of files that failed to be prefetched.",
+		},
+		[]string{"job"},
+	)
+)
+
+func registerMetrics() {
+	metrics.MustRegister(
+		runs,
+		runFailures,
+		runDuration,
+		filesFetched,
+		bytesFetched,
+		lastRunBytes,
+		failures,
+	)
+}
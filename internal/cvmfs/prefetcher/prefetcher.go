@@ -0,0 +1,367 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package prefetcher keeps the local CVMFS cache warm by periodically reading
+// selected files of CVMFS repositories through the autofs-managed /cvmfs.
+// Jobs starting on a fresh node then don't all stall on a cold cache.
+package prefetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type Opts struct {
+	// Path to the JSON file with prefetcher Config.
+	ConfigFilepath string
+
+	// Root of the autofs-managed CVMFS repositories, usually /cvmfs.
+	CvmfsRoot string
+
+	// Period of jobs that don't set their own.
+	DefaultPeriod time.Duration
+
+	// Maximum number of files read at the same time, across all jobs.
+	MaxConcurrentReads int
+
+	// Run each job once and return, instead of running them periodically.
+	Once bool
+}
+
+// errMaxBytesReached stops a job run once it has read Job.MaxBytes.
+var errMaxBytesReached = errors.New("maximum number of bytes reached")
+
+// runReport summarizes a single run of a job.
+type runReport struct {
+	files    atomic.Int64
+	bytes    atomic.Int64
+	failures atomic.Int64
+}
+
+type prefetcher struct {
+	cvmfsRoot string
+
+	// Limits the number of concurrent reads across all jobs.
+	readSlots chan struct{}
+}
+
+func RunBlocking(o *Opts) error {
+	registerMetrics()
+
+	config, err := loadConfig(o.ConfigFilepath, o.DefaultPeriod)
+	if err != nil {
+		return fmt.Errorf("failed to load prefetcher config: %v", err)
+	}
+
+	if o.MaxConcurrentReads <= 0 {
+		return fmt.Errorf("maximum number of concurrent reads must be positive, got %d", o.MaxConcurrentReads)
+	}
+
+	p := &prefetcher{
+		cvmfsRoot: o.CvmfsRoot,
+		readSlots: make(chan struct{}, o.MaxConcurrentReads),
+	}
+
+	log.Infof("Loaded %d prefetching jobs from %s", len(config.Jobs), o.ConfigFilepath)
+
+	var wg sync.WaitGroup
+
+	for i := range config.Jobs {
+		job := &config.Jobs[i]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if o.Once {
+				p.runJob(job)
+				return
+			}
+
+			p.runJobPeriodically(job)
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (p *prefetcher) runJobPeriodically(job *Job) {
+	t := time.NewTicker(job.period)
+	defer t.Stop()
+
+	// Run at start so that the cache is warmed up as soon
+	// as possible on a fresh node.
+	p.runJob(job)
+
+	for range t.C {
+		p.runJob(job)
+	}
+}
+
+func (p *prefetcher) runJob(job *Job) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	ctx, span := tracing.Start(ctx, "prefetch",
+		attribute.String("job", job.Name),
+		attribute.String(log.KeyRepository, job.Repository),
+	)
+
+	log.InfoS("Prefetching", "job", job.Name, log.KeyRepository, job.Repository)
+
+	start := time.Now()
+	report := &runReport{}
+
+	err := p.walkJob(ctx, job, func(filepath string) {
+		n, err := p.readFile(ctx, filepath)
+		report.bytes.Add(n)
+		bytesFetched.WithLabelValues(job.Name).Add(float64(n))
+
+		if err != nil {
+			if ctx.Err() == nil {
+				log.ErrorS(err, "Failed to prefetch file", "job", job.Name, "file", filepath)
+			}
+			report.failures.Add(1)
+			return
+		}
+
+		report.files.Add(1)
+
+		if job.MaxBytes > 0 && report.bytes.Load() >= job.MaxBytes {
+			cancel(errMaxBytesReached)
+		}
+	})
+
+	if errors.Is(context.Cause(ctx), errMaxBytesReached) {
+		log.InfoS("Reached maximum number of bytes to prefetch, stopping", "job", job.Name, "maxBytes", job.MaxBytes)
+		err = nil
+	}
+
+	duration := time.Since(start)
+
+	runs.WithLabelValues(job.Name).Inc()
+	runDuration.WithLabelValues(job.Name).Observe(duration.Seconds())
+	filesFetched.WithLabelValues(job.Name).Add(float64(report.files.Load()))
+	failures.WithLabelValues(job.Name).Add(float64(report.failures.Load()))
+	lastRunBytes.WithLabelValues(job.Name).Set(float64(report.bytes.Load()))
+
+	span.SetAttributes(
+		attribute.Int64("files", report.files.Load()),
+		attribute.Int64("bytes", report.bytes.Load()),
+		attribute.Int64("failures", report.failures.Load()),
+	)
+	tracing.End(span, err)
+
+	if err != nil {
+		log.ErrorS(err, "Prefetching failed", "job", job.Name, log.KeyRepository, job.Repository)
+		runFailures.WithLabelValues(job.Name).Inc()
+	}
+
+	log.InfoS("Prefetching finished",
+		"job", job.Name,
+		log.KeyRepository, job.Repository,
+		"files", report.files.Load(),
+		"bytes", report.bytes.Load(),
+		"failures", report.failures.Load(),
+		"duration", duration,
+	)
+}
+
+// walkJob calls prefetch for each file matching the job's path patterns.
+// Files are prefetched concurrently, limited by the prefetcher's read slots.
+func (p *prefetcher) walkJob(ctx context.Context, job *Job, prefetch func(filepath string)) error {
+	root := path.Join(p.cvmfsRoot, job.Repository)
+
+	// Trigger the automount and make sure the repository exists.
+	if _, err := os.Stat(root); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	visit := func(filepath string) error {
+		select {
+		case p.readSlots <- struct{}{}:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-p.readSlots
+				wg.Done()
+			}()
+
+			prefetch(filepath)
+		}()
+
+		return nil
+	}
+
+	for _, pattern := range job.Paths {
+		err := walkPattern(ctx, root, strings.Split(path.Clean(pattern), "/"), visit)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkPattern calls visit for each regular file in dir matching the path
+// pattern split into elements. Directories matching the whole pattern are
+// walked recursively. Symlinks are not followed.
+func walkPattern(ctx context.Context, dir string, elems []string, visit func(filepath string) error) error {
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	if len(elems) == 0 || (len(elems) == 1 && elems[0] == "**") {
+		return walkTree(ctx, dir, visit)
+	}
+
+	elem, rest := elems[0], elems[1:]
+
+	if elem == "**" {
+		// Match zero directories...
+		if err := walkPattern(ctx, dir, rest, visit); err != nil {
+			return err
+		}
+
+		// ...or any number of them.
+		return forEachEntry(dir, func(entry fs.DirEntry) error {
+			if !entry.IsDir() {
+				return nil
+			}
+			return walkPattern(ctx, path.Join(dir, entry.Name()), elems, visit)
+		})
+	}
+
+	matchEntry := func(entry fs.DirEntry) error {
+		entryPath := path.Join(dir, entry.Name())
+
+		switch {
+		case entry.IsDir():
+			return walkPattern(ctx, entryPath, rest, visit)
+		case entry.Type().IsRegular() && len(rest) == 0:
+			return visit(entryPath)
+		default:
+			return nil
+		}
+	}
+
+	if !hasMeta(elem) {
+		// Avoid listing possibly large directories if we know the name.
+		fi, err := os.Lstat(path.Join(dir, elem))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		return matchEntry(fs.FileInfoToDirEntry(fi))
+	}
+
+	return forEachEntry(dir, func(entry fs.DirEntry) error {
+		if matched, _ := path.Match(elem, entry.Name()); !matched {
+			return nil
+		}
+		return matchEntry(entry)
+	})
+}
+
+func walkTree(ctx context.Context, root string, visit func(filepath string) error) error {
+	return filepath.WalkDir(root, func(filepath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err = context.Cause(ctx); err != nil {
+			return err
+		}
+
+		if entry.Type().IsRegular() {
+			return visit(filepath)
+		}
+
+		return nil
+	})
+}
+
+func forEachEntry(dir string, do func(entry fs.DirEntry) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err = do(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasMeta(elem string) bool {
+	return strings.ContainsAny(elem, `*?[\`)
+}
+
+// readFile reads the whole file, making the CVMFS client fetch
+// it into the cache. Returns the number of bytes read.
+func (p *prefetcher) readFile(ctx context.Context, filepath string) (int64, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return io.Copy(io.Discard, &ctxReader{ctx: ctx, r: f})
+}
+
+// ctxReader stops reading once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(b []byte) (int, error) {
+	if err := context.Cause(r.ctx); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(b)
+}
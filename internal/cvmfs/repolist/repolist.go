@@ -0,0 +1,69 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package repolist parses the volume parameter listing
+// CVMFS repositories of multi-repository volumes.
+package repolist
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Key of the volume parameter with comma-separated list of repositories.
+const Key = "repositories"
+
+// CVMFS repository names are fully qualified names, e.g. atlas.cern.ch.
+var repositoryNameRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9_-]*[A-Za-z0-9])?)*$`)
+
+// FromVolumeParameters parses and validates the list of repositories
+// in params. Returns nil if the parameter is not set.
+func FromVolumeParameters(params map[string]string) ([]string, error) {
+	value, ok := params[Key]
+	if !ok {
+		return nil, nil
+	}
+
+	var (
+		repositories []string
+		seen         = make(map[string]struct{})
+	)
+
+	for _, repository := range strings.Split(value, ",") {
+		repository = strings.TrimSpace(repository)
+		if repository == "" {
+			continue
+		}
+
+		if !repositoryNameRegexp.MatchString(repository) {
+			return nil, fmt.Errorf("invalid value for volume parameter %s: %q is not a valid repository name", Key, repository)
+		}
+
+		if _, ok := seen[repository]; ok {
+			return nil, fmt.Errorf("invalid value for volume parameter %s: repository %s is listed more than once", Key, repository)
+		}
+
+		seen[repository] = struct{}{}
+		repositories = append(repositories, repository)
+	}
+
+	if len(repositories) == 0 {
+		return nil, fmt.Errorf("volume parameter %s must list at least one repository", Key)
+	}
+
+	return repositories, nil
+}
@@ -0,0 +1,76 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package repolist
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFromVolumeParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{name: "not set", params: map[string]string{}, want: nil},
+		{name: "single", params: map[string]string{Key: "atlas.cern.ch"}, want: []string{"atlas.cern.ch"}},
+		{
+			name:   "multiple",
+			params: map[string]string{Key: "atlas.cern.ch,cms.cern.ch"},
+			want:   []string{"atlas.cern.ch", "cms.cern.ch"},
+		},
+		{
+			name:   "whitespace and empty entries",
+			params: map[string]string{Key: " atlas.cern.ch , ,cms.cern.ch,"},
+			want:   []string{"atlas.cern.ch", "cms.cern.ch"},
+		},
+		{
+			name:   "order is preserved",
+			params: map[string]string{Key: "sft.cern.ch,atlas.cern.ch"},
+			want:   []string{"sft.cern.ch", "atlas.cern.ch"},
+		},
+		{name: "dashes and underscores", params: map[string]string{Key: "a-b_c.example.org"}, want: []string{"a-b_c.example.org"}},
+		{name: "empty", params: map[string]string{Key: ""}, wantErr: true},
+		{name: "only separators", params: map[string]string{Key: " , ,"}, wantErr: true},
+		{name: "duplicate", params: map[string]string{Key: "atlas.cern.ch,atlas.cern.ch"}, wantErr: true},
+		{name: "path separator", params: map[string]string{Key: "atlas.cern.ch/repo"}, wantErr: true},
+		{name: "dotdot", params: map[string]string{Key: ".."}, wantErr: true},
+		{name: "leading dot", params: map[string]string{Key: ".cern.ch"}, wantErr: true},
+		{name: "trailing dot", params: map[string]string{Key: "atlas.cern.ch."}, wantErr: true},
+		{name: "inner whitespace", params: map[string]string{Key: "atlas cern.ch"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromVolumeParameters(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
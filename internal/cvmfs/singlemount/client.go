@@ -21,6 +21,7 @@ import (
 	"time"
 
 	pb "github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount/pb/v1"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
@@ -48,6 +49,8 @@ func NewClient(ctx context.Context, endpoint string) (*Client, error) {
 			},
 		}),
 		grpc.WithBlock(),
+		// Propagate the trace of the caller to singlemount-runner.
+		grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor),
 	)
 	if err != nil {
 		return nil, err
@@ -69,6 +72,16 @@ func (c *Client) Unmount(ctx context.Context, in *pb.UnmountSingleRequest, opts
 	return c.cl.Unmount(ctx, in, opts...)
 }
 
+// Lists all shared mounts managed by Single.
+func (c *Client) ListMounts(ctx context.Context, in *pb.ListMountsRequest, opts ...grpc.CallOption) (*pb.ListMountsResponse, error) {
+	return c.cl.ListMounts(ctx, in, opts...)
+}
+
+// Returns a single shared mount managed by Single.
+func (c *Client) GetMount(ctx context.Context, in *pb.GetMountRequest, opts ...grpc.CallOption) (*pb.GetMountResponse, error) {
+	return c.cl.GetMount(ctx, in, opts...)
+}
+
 func (c *Client) Close() error {
 	err := c.conn.Close()
 	c.conn = nil
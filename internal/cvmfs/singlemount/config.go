@@ -0,0 +1,228 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/clientconfig"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+)
+
+// Directory with the default CVMFS client configuration. It is a variable
+// only so that tests can point it to a temporary directory.
+var cvmfsDefaultConfigDir = "/etc/cvmfs"
+
+const (
+	// Where the config repository is looked up if CVMFS_MOUNT_DIR is not set.
+	cvmfsDefaultMountDir = "/cvmfs"
+
+	// Cache directory of mounts that use the default client configuration.
+	cacheDirname = "cache"
+)
+
+// appendVolumeConfig appends volumeConfig, as supplied with the volume,
+// to config. Parameters defined later in the config take precedence.
+func appendVolumeConfig(config, volumeConfig string) string {
+	if volumeConfig == "" {
+		return config
+	}
+
+	var b strings.Builder
+	b.WriteString(config)
+
+	if config != "" && !strings.HasSuffix(config, "\n") {
+		b.WriteByte('\n')
+	}
+
+	b.WriteString("# Volume client config\n")
+	b.WriteString(volumeConfig)
+
+	if !strings.HasSuffix(volumeConfig, "\n") {
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// appendConfigParameters appends params to config, sorted by name.
+// Parameters defined later in the config take precedence.
+func appendConfigParameters(config string, params map[string]string) string {
+	if len(params) == 0 {
+		return config
+	}
+
+	var b strings.Builder
+	b.WriteString(config)
+
+	if config != "" && !strings.HasSuffix(config, "\n") {
+		b.WriteByte('\n')
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		fmt.Fprintf(&b, "%s=%s\n", name, clientconfig.Quote(params[name]))
+	}
+
+	return b.String()
+}
+
+// defaultRepositoryConfigFiles returns paths to files making up the default
+// client configuration of the repository, in the order they are read by cvmfs2.
+// configRepoDir is the etc/cvmfs directory in the config repository
+// (CVMFS_CONFIG_REPOSITORY), or empty if there is none. Files from the config
+// repository come before their /etc/cvmfs counterparts, so that they can be
+// overridden locally.
+func defaultRepositoryConfigFiles(repository, configRepoDir string) ([]string, error) {
+	files := []string{
+		path.Join(cvmfsDefaultConfigDir, "default.conf"),
+	}
+
+	defaultD, err := filepath.Glob(path.Join(cvmfsDefaultConfigDir, "default.d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(defaultD)
+	files = append(files, defaultD...)
+
+	if configRepoDir != "" {
+		files = append(files, path.Join(configRepoDir, "default.conf"))
+	}
+
+	files = append(files, path.Join(cvmfsDefaultConfigDir, "default.local"))
+
+	if _, domain, ok := strings.Cut(repository, "."); ok {
+		if configRepoDir != "" {
+			files = append(files, path.Join(configRepoDir, "domain.d", domain+".conf"))
+		}
+
+		files = append(files,
+			path.Join(cvmfsDefaultConfigDir, "domain.d", domain+".conf"),
+			path.Join(cvmfsDefaultConfigDir, "domain.d", domain+".local"),
+		)
+	}
+
+	if configRepoDir != "" {
+		files = append(files, path.Join(configRepoDir, "config.d", repository+".conf"))
+	}
+
+	files = append(files,
+		path.Join(cvmfsDefaultConfigDir, "config.d", repository+".conf"),
+		path.Join(cvmfsDefaultConfigDir, "config.d", repository+".local"),
+	)
+
+	return files, nil
+}
+
+// readConfigFiles concatenates files, skipping those that don't exist.
+func readConfigFiles(files []string) (string, error) {
+	var b strings.Builder
+
+	for _, f := range files {
+		contents, err := os.ReadFile(f)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return "", fmt.Errorf("failed to read default config file: %v", err)
+		}
+
+		fmt.Fprintf(&b, "# %s\n", f)
+		b.Write(contents)
+		if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+	}
+
+	return b.String(), nil
+}
+
+// configRepositoryDir returns the etc/cvmfs directory of the config
+// repository set in CVMFS_CONFIG_REPOSITORY, as seen by singlemount-runner,
+// or empty if repository doesn't use one. Like in cvmfs2, only the files
+// read before the config repository (default.conf and default.d) may set
+// CVMFS_CONFIG_REPOSITORY, and the config repository itself has none.
+func configRepositoryDir(repository string) (string, error) {
+	defaultD, err := filepath.Glob(path.Join(cvmfsDefaultConfigDir, "default.d", "*.conf"))
+	if err != nil {
+		return "", err
+	}
+	slices.Sort(defaultD)
+
+	config, err := readConfigFiles(append([]string{path.Join(cvmfsDefaultConfigDir, "default.conf")}, defaultD...))
+	if err != nil {
+		return "", err
+	}
+
+	params := clientconfig.Effective(config)
+
+	configRepo := params["CVMFS_CONFIG_REPOSITORY"]
+	if configRepo == "" || configRepo == repository {
+		return "", nil
+	}
+
+	mountDir := params["CVMFS_MOUNT_DIR"]
+	if mountDir == "" {
+		mountDir = cvmfsDefaultMountDir
+	}
+
+	return path.Join(mountDir, configRepo, "etc", "cvmfs"), nil
+}
+
+// readDefaultRepositoryConfig concatenates the default client configuration
+// of the repository, as found in /etc/cvmfs and in the config repository.
+// cvmfs2 doesn't read these files when mounting with -o config, so we need
+// to pass them explicitly.
+//
+// The mount gets its own cache directory in the singlemount directory,
+// so that it doesn't share the workspace with the automounted repository.
+func readDefaultRepositoryConfig(mountID, repository string) (string, error) {
+	configRepoDir, err := configRepositoryDir(repository)
+	if err != nil {
+		return "", fmt.Errorf("failed to find config repository for %s: %v", repository, err)
+	}
+
+	if configRepoDir != "" {
+		// Accessing the directory triggers the automount of the config repository.
+		if _, err := os.Stat(configRepoDir); err != nil {
+			log.Warningf("Config repository of %s is not available in %s, using only local defaults: %v",
+				repository, configRepoDir, err)
+			configRepoDir = ""
+		}
+	}
+
+	files, err := defaultRepositoryConfigFiles(repository, configRepoDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to list default config files for %s: %v", repository, err)
+	}
+
+	config, err := readConfigFiles(files)
+	if err != nil {
+		return "", err
+	}
+
+	return appendConfigParameters(config, map[string]string{
+		"CVMFS_CACHE_BASE":   path.Join(fmtMountSingleBasePath(mountID), cacheDirname),
+		"CVMFS_SHARED_CACHE": "no",
+	}), nil
+}
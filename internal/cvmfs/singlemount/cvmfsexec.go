@@ -18,7 +18,7 @@ package singlemount
 
 import (
 	"container/ring"
-	"fmt"
+	"context"
 	goexec "os/exec"
 	"strings"
 
@@ -26,116 +26,122 @@ import (
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
 )
 
-var cvmfsErrMessages = []string{
-	"all proxies failed, trying host fail-over)",
-	"already mounted)",
-	"bad certificate, failed to verify repository manifest)",
-	"bad data received)",
-	"bad signature, failed to verify repository manifest)",
-	"bad whitelist)",
-	"cache directory/plugin problem)",
-	"cannot run FUSE event loop)",
-	"catalog root path mismatch)",
-	"certificate blacklisted)",
-	"certificate is not whitelisted)",
-	"certificate not on whitelist)",
-	"corrupted data received)",
-	"decompression failed)",
-	"DNS query timeout)",
-	"double mount)",
-	"empty whitelist)",
-	"empty whitelist (pkcs7))",
-	"expired whitelist)",
-	"failed to download)",
-	"failed to download whitelist)",
-	"failed to download whitelist (pkcs7))",
-	"failed to load shared library)",
-	"failed to mount)",
-	"failed to resolve host address)",
-	"failed to resolve proxy address)",
-	"failed to verify CA chain)",
-	"file catalog failure)",
-	"history init failure)",
-	"host connection problem)",
-	"host data transfer cut short)",
-	"host returned HTTP error)",
-	"host serving data too slowly)",
-	"illegal options)",
-	"incompatible library version)",
-	"incomplete manifest)",
-	"internal error, not yet resolved)",
-	"invalid Base64 input)",
-	"invalid certificate)",
-	"invalid host name to resolve)",
-	"invalid resolver addresses)",
-	"invalid whitelist (pkcs7))",
-	"invalid whitelist signature)",
-	"invalid whitelist signer (pkcs7))",
-	"letter expired)",
-	"letter malformed)",
-	"local I/O failure)",
-	"maintenance mode)",
-	"malformed DNS request)",
-	"malformed URL)",
-	"malformed whitelist)",
-	"malformed whitelist (pkcs7))",
-	"manifest name doesn't match)",
-	"manifest signature is invalid)",
-	"network failure)",
-	"NFS maps init failure)",
-	"no IP address for host)",
-	"object not found)",
-	"outdated manifest)",
-	"peering problem)",
-	"permission denied)",
-	"proxy auto-discovery failed)",
-	"proxy connection problem)",
-	"proxy data transfer cut short)",
-	"proxy returned HTTP error)",
-	"proxy serving data too slowly)",
-	"quota init failure)",
-	"repository name mismatch)",
-	"repository name mismatch on whitelist)",
-	"request canceled)",
-	"resource too big to download)",
-	"revision blacklisted)",
-	"signature verification failed)",
-	"signature verification failure)",
-	"state restore failure)",
-	"state saving failure)",
-	"S3: failed to resolve host address)",
-	"S3: forbidden)",
-	"S3: host connection problem)",
-	"S3: local I/O failure)",
-	"S3: malformed URL (bad request))",
-	"S3: not found)",
-	"S3: service not available)",
-	"S3: too many requests, service asks for backoff and retry)",
-	"S3: unknown service error, perhaps wrong authentication protocol)",
-	"talk socket failure)",
-	"unable to init loader talk socket)",
-	"unknown host name)",
-	"unknown name resolving error)",
-	"unknown network error)",
-	"Unsupported URL in protocol)",
-	"watchdog failure)",
-	"workspace already locked)",
+// cvmfsErrMessages maps suffixes of cvmfs2 error messages
+// to the class of the error they represent.
+var cvmfsErrMessages = []struct {
+	suffix string
+	class  mountErrorClass
+}{
+	{"all proxies failed, trying host fail-over)", mountErrNetwork},
+	{"already mounted)", mountErrAlreadyMounted},
+	{"bad certificate, failed to verify repository manifest)", mountErrSignature},
+	{"bad data received)", mountErrNetwork},
+	{"bad signature, failed to verify repository manifest)", mountErrSignature},
+	{"bad whitelist)", mountErrSignature},
+	{"cache directory/plugin problem)", mountErrCache},
+	{"cannot run FUSE event loop)", mountErrUnknown},
+	{"catalog root path mismatch)", mountErrSignature},
+	{"certificate blacklisted)", mountErrSignature},
+	{"certificate is not whitelisted)", mountErrSignature},
+	{"certificate not on whitelist)", mountErrSignature},
+	{"corrupted data received)", mountErrNetwork},
+	{"decompression failed)", mountErrUnknown},
+	{"DNS query timeout)", mountErrNetwork},
+	{"double mount)", mountErrAlreadyMounted},
+	{"empty whitelist)", mountErrSignature},
+	{"empty whitelist (pkcs7))", mountErrSignature},
+	{"expired whitelist)", mountErrSignature},
+	{"failed to download)", mountErrNetwork},
+	{"failed to download whitelist)", mountErrNetwork},
+	{"failed to download whitelist (pkcs7))", mountErrNetwork},
+	{"failed to load shared library)", mountErrUnknown},
+	{"failed to mount)", mountErrUnknown},
+	{"failed to resolve host address)", mountErrNetwork},
+	{"failed to resolve proxy address)", mountErrNetwork},
+	{"failed to verify CA chain)", mountErrSignature},
+	{"file catalog failure)", mountErrUnknown},
+	{"history init failure)", mountErrUnknown},
+	{"host connection problem)", mountErrNetwork},
+	{"host data transfer cut short)", mountErrNetwork},
+	{"host returned HTTP error)", mountErrNetwork},
+	{"host serving data too slowly)", mountErrNetwork},
+	{"illegal options)", mountErrConfig},
+	{"incompatible library version)", mountErrUnknown},
+	{"incomplete manifest)", mountErrSignature},
+	{"internal error, not yet resolved)", mountErrUnknown},
+	{"invalid Base64 input)", mountErrConfig},
+	{"invalid certificate)", mountErrSignature},
+	{"invalid host name to resolve)", mountErrConfig},
+	{"invalid resolver addresses)", mountErrConfig},
+	{"invalid whitelist (pkcs7))", mountErrSignature},
+	{"invalid whitelist signature)", mountErrSignature},
+	{"invalid whitelist signer (pkcs7))", mountErrSignature},
+	{"letter expired)", mountErrSignature},
+	{"letter malformed)", mountErrSignature},
+	{"local I/O failure)", mountErrCache},
+	{"maintenance mode)", mountErrNetwork},
+	{"malformed DNS request)", mountErrConfig},
+	{"malformed URL)", mountErrConfig},
+	{"malformed whitelist)", mountErrSignature},
+	{"malformed whitelist (pkcs7))", mountErrSignature},
+	{"manifest name doesn't match)", mountErrSignature},
+	{"manifest signature is invalid)", mountErrSignature},
+	{"network failure)", mountErrNetwork},
+	{"NFS maps init failure)", mountErrUnknown},
+	{"no IP address for host)", mountErrNetwork},
+	{"object not found)", mountErrNetwork},
+	{"outdated manifest)", mountErrSignature},
+	{"peering problem)", mountErrNetwork},
+	{"permission denied)", mountErrPermission},
+	{"proxy auto-discovery failed)", mountErrNetwork},
+	{"proxy connection problem)", mountErrNetwork},
+	{"proxy data transfer cut short)", mountErrNetwork},
+	{"proxy returned HTTP error)", mountErrNetwork},
+	{"proxy serving data too slowly)", mountErrNetwork},
+	{"quota init failure)", mountErrCache},
+	{"repository name mismatch)", mountErrSignature},
+	{"repository name mismatch on whitelist)", mountErrSignature},
+	{"request canceled)", mountErrNetwork},
+	{"resource too big to download)", mountErrNetwork},
+	{"revision blacklisted)", mountErrSignature},
+	{"signature verification failed)", mountErrSignature},
+	{"signature verification failure)", mountErrSignature},
+	{"state restore failure)", mountErrUnknown},
+	{"state saving failure)", mountErrUnknown},
+	{"S3: failed to resolve host address)", mountErrNetwork},
+	{"S3: forbidden)", mountErrPermission},
+	{"S3: host connection problem)", mountErrNetwork},
+	{"S3: local I/O failure)", mountErrUnknown},
+	{"S3: malformed URL (bad request))", mountErrConfig},
+	{"S3: not found)", mountErrNetwork},
+	{"S3: service not available)", mountErrNetwork},
+	{"S3: too many requests, service asks for backoff and retry)", mountErrNetwork},
+	{"S3: unknown service error, perhaps wrong authentication protocol)", mountErrConfig},
+	{"talk socket failure)", mountErrUnknown},
+	{"unable to init loader talk socket)", mountErrUnknown},
+	{"unknown host name)", mountErrNetwork},
+	{"unknown name resolving error)", mountErrNetwork},
+	{"unknown network error)", mountErrNetwork},
+	{"Unsupported URL in protocol)", mountErrConfig},
+	{"watchdog failure)", mountErrUnknown},
+	{"workspace already locked)", mountErrCache},
 }
 
-func runCvmfs2AndTryCaptureErr(arg ...string) error {
+func runCvmfs2AndTryCaptureErr(ctx context.Context, repository string, arg ...string) error {
 	// Holds up to 10 last lines of cvmfs2 output.
 	// Let's hope the final error message will be
 	// somewhere in there...
 	logRing := ring.New(10)
 
-	err := exec.RunAndDoCombined(
-		goexec.Command("cvmfs2", arg...),
+	err := exec.RunAndDoCombinedContext(
+		ctx,
+		goexec.Command("cvmfs2", append([]string{repository}, arg...)...),
 		func(execID uint64, line string) {
 			if line == "" {
 				return
 			}
 
-			log.Infof("%s", exec.FmtLogMsg(execID, line))
+			log.InfoS(line, log.KeyExecID, execID, log.KeyRepository, repository)
 
 			logRing.Value = line
 			logRing = logRing.Next()
@@ -147,25 +153,29 @@ func runCvmfs2AndTryCaptureErr(arg ...string) error {
 	}
 
 	// cvmfs2 failed. Search the list of log messages and try
-	// to find the final error message.
+	// to find the final error message. The class of the error
+	// is determined by the last recognized message.
+
+	mntErr := &mountError{
+		Class:      mountErrUnknown,
+		Repository: repository,
+		Err:        err,
+	}
 
-	var errMsgs []string
 	logRing.Do(func(line any) {
 		lineStr, ok := line.(string)
 		if !ok {
 			return
 		}
 
-		for _, errMsgSuffix := range cvmfsErrMessages {
-			if strings.Contains(lineStr, errMsgSuffix) {
-				errMsgs = append(errMsgs, lineStr)
+		for _, errMsg := range cvmfsErrMessages {
+			if strings.Contains(lineStr, errMsg.suffix) {
+				mntErr.Class = errMsg.class
+				mntErr.Messages = append(mntErr.Messages, lineStr)
+				break
 			}
 		}
 	})
 
-	if len(errMsgs) != 0 {
-		return fmt.Errorf("failed to mount repository: %v", errMsgs)
-	}
-
-	return fmt.Errorf("failed to mount repository, please see cvmfs-csi logs for details (%v)", err)
+	return mntErr
 }
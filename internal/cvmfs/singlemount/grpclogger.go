@@ -20,30 +20,50 @@ import (
 	"context"
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
 // Counter value used for pairing up GRPC call and response log messages.
 var grpcCallCounter uint64
 
-func fmtGRPCLogMsg(grpcCallID uint64, msg string) string {
-	return fmt.Sprintf("Call-ID %d: %s", grpcCallID, msg)
-}
-
 func grpcLogger(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	grpcCallID := atomic.AddUint64(&grpcCallCounter, 1)
 
-	log.DebugfWithContext(ctx, fmtGRPCLogMsg(grpcCallID, fmt.Sprintf("Call: %s", info.FullMethod)))
-	log.DebugfWithContext(ctx, fmtGRPCLogMsg(grpcCallID, fmt.Sprintf("Request: %s", req)))
+	reqValues := log.RequestValues(req)
+
+	// Continue the trace of the caller, if any. The span
+	// is then the parent of all spans created by the handler.
+	ctx, span := tracing.Start(tracing.ExtractGRPC(ctx), info.FullMethod, tracing.LogValues(reqValues...)...)
+
+	// Annotate the context with request-scoped fields, so that
+	// the handler can log with them too.
+	ctx = log.WithValues(ctx, append([]interface{}{
+		log.KeyRPC, info.FullMethod,
+		log.KeyCallID, grpcCallID,
+	}, reqValues...)...)
 
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		ctx = log.WithValues(ctx, log.KeyTraceID, traceID)
+	}
+
+	log.DebugSWithContext(ctx, "Call", "request", fmt.Sprint(req))
+
+	start := time.Now()
 	resp, err := handler(ctx, req)
+	metrics.ObserveGRPCRequest(info.FullMethod, status.Code(err), time.Since(start))
+	tracing.End(span, err)
+
 	if err != nil {
-		log.ErrorfWithContext(ctx, fmtGRPCLogMsg(grpcCallID, fmt.Sprintf("Error: %v", err)))
+		log.ErrorSWithContext(ctx, err, "Call failed")
 	} else {
-		log.DebugfWithContext(ctx, fmtGRPCLogMsg(grpcCallID, fmt.Sprintf("Response: %s", resp)))
+		log.DebugSWithContext(ctx, "Response", "response", fmt.Sprint(resp))
 	}
 
 	return resp, err
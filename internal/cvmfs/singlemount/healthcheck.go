@@ -0,0 +1,144 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/talk"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
+)
+
+// Health checker periodically talks to cvmfs2 clients of all shared mounts.
+// If a client has crashed, its mount is remounted, and all targets listed
+// in its bind metadata are bound again.
+
+func (s *singleMountServer) runHealthChecker(period time.Duration) {
+	t := time.NewTicker(period)
+	defer t.Stop()
+
+	for range t.C {
+		s.checkHealth()
+	}
+}
+
+func (s *singleMountServer) checkHealth() {
+	ctx, span := tracing.Start(context.Background(), "checkHealth")
+	defer span.End()
+
+	mountIDs, err := listMountIDs()
+	if err != nil {
+		log.Errorf("Failed to check health of shared mounts: failed to list mounts: %v", err)
+		return
+	}
+
+	var healthy, repaired, failed int
+
+	for _, mountID := range mountIDs {
+		if _, isPending := s.pendingOps.LoadOrStore(mountID, true); isPending {
+			// Mount or Unmount is in progress, we'll get to this one next time.
+			continue
+		}
+
+		switch err := checkSharedMountHealth(mountID); err {
+		case nil:
+			healthy++
+			healthChecks.WithLabelValues(healthCheckHealthy).Inc()
+		case errClientNotRunning:
+			log.InfoS("CVMFS client is not running, remounting", log.KeyMountID, mountID)
+
+			if err = remountSharedMount(ctx, mountID); err != nil {
+				log.ErrorS(err, "Failed to remount", log.KeyMountID, mountID)
+				failed++
+				healthChecks.WithLabelValues(healthCheckFailed).Inc()
+			} else {
+				log.InfoS("Remounted successfully", log.KeyMountID, mountID)
+				repaired++
+				healthChecks.WithLabelValues(healthCheckRepaired).Inc()
+			}
+		default:
+			log.ErrorS(err, "Failed to check health", log.KeyMountID, mountID)
+			failed++
+			healthChecks.WithLabelValues(healthCheckFailed).Inc()
+		}
+
+		s.pendingOps.Delete(mountID)
+	}
+
+	log.Tracef("Health check of %d shared mounts finished: %d healthy, %d repaired, %d failed",
+		len(mountIDs), healthy, repaired, failed)
+
+	if repaired > 0 || failed > 0 {
+		totalHealthy := healthCheckTotal(healthCheckHealthy)
+		totalRepaired := healthCheckTotal(healthCheckRepaired)
+		totalFailed := healthCheckTotal(healthCheckFailed)
+
+		log.Infof("Health check totals since start: %d checked, %d healthy, %d repaired, %d failed",
+			totalHealthy+totalRepaired+totalFailed, totalHealthy, totalRepaired, totalFailed)
+	}
+}
+
+var errClientNotRunning = errors.New("CVMFS client is not running")
+
+// Returns nil if the cvmfs2 client of mountID is healthy,
+// errClientNotRunning if it needs to be remounted.
+func checkSharedMountHealth(mountID string) error {
+	mountpoint := fmtMountpointPath(mountID)
+	socketPath := fmtTalkSocketPath(mountID)
+
+	if _, err := os.Stat(socketPath); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		// Mounts created by older versions of singlemount-runner don't
+		// have the talk socket configured. Fall back to probing the mountpoint.
+
+		mntState, err := mountutils.GetState(mountpoint)
+		if err != nil {
+			return err
+		}
+
+		if mntState != mountutils.StMounted {
+			return errClientNotRunning
+		}
+
+		return nil
+	}
+
+	out, err := talk.Socket(socketPath, "mountpoint")
+	if err == nil {
+		if bytes.HasPrefix(out, []byte(mountpoint)) {
+			return nil
+		}
+
+		return fmt.Errorf("repository is mounted at an unexpected location \"%s\", expected %s", out, mountpoint)
+	}
+
+	if talk.ClientNotRunning(out) {
+		return errClientNotRunning
+	}
+
+	return fmt.Errorf("failed to talk to CVMFS client (%v): %s", err, out)
+}
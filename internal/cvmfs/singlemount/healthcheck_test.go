@@ -0,0 +1,62 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"os"
+	goexec "os/exec"
+	"testing"
+)
+
+func TestCheckHealthCounters(t *testing.T) {
+	useTempSinglemountsDir(t)
+
+	// "broken" has no mountpoint to probe, and so its check fails.
+
+	if err := os.Mkdir(fmtMountSingleBasePath("broken"), 0o775); err != nil {
+		t.Fatal(err)
+	}
+
+	wantHealthy := healthCheckTotal(healthCheckHealthy)
+	wantFailed := healthCheckTotal(healthCheckFailed) + 1
+
+	// "healthy" has its mountpoint mounted. Mounting needs root.
+
+	if os.Geteuid() == 0 {
+		mountpoint := fmtMountpointPath("healthy")
+		if err := os.MkdirAll(mountpoint, 0o775); err != nil {
+			t.Fatal(err)
+		}
+
+		if out, err := goexec.Command("mount", "-t", "tmpfs", "cvmfs-csi-test", mountpoint).CombinedOutput(); err != nil {
+			t.Fatalf("failed to mount tmpfs: %v: %s", err, out)
+		}
+		t.Cleanup(func() { goexec.Command("umount", mountpoint).Run() })
+
+		wantHealthy++
+	}
+
+	(&singleMountServer{}).checkHealth()
+
+	if got := healthCheckTotal(healthCheckHealthy); got != wantHealthy {
+		t.Errorf("got %d healthy checks, want %d", got, wantHealthy)
+	}
+
+	if got := healthCheckTotal(healthCheckFailed); got != wantFailed {
+		t.Errorf("got %d failed checks, want %d", got, wantFailed)
+	}
+}
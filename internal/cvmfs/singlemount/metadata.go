@@ -0,0 +1,312 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+)
+
+// This file implements the metadata store used by singlemount-runner.
+//
+// All metadata files are written atomically: the contents are first written
+// into a temporary file in the same directory, synced to disk, and only then
+// renamed over the original file. A crash mid-write therefore leaves behind
+// either the old or the new version of the file, but never a truncated one.
+//
+// Files under <mountsDir>/<mount ID>/ are only ever modified while holding
+// the mount ID in singleMountServer.pendingOps. mountpoints.json on the other
+// hand is shared by all mount IDs, and its read-modify-write cycles must be
+// serialized with mountpointsMetadataMtx.
+
+const (
+	// Suffix of temporary files created by writeFileAtomic.
+	tmpFileSuffix = ".tmp"
+
+	// Prefix of temporary singlemount directories created by
+	// createMountSingleMetadata. These are skipped when listing mount IDs.
+	tmpDirPrefix = ".tmp-"
+
+	// Suffix appended to metadata files that were found corrupted and
+	// couldn't be repaired. They are kept for inspection by the admin.
+	corruptedFileSuffix = ".corrupted"
+)
+
+// Guards mountpoints.json.
+var mountpointsMetadataMtx sync.Mutex
+
+// errCorruptedMetadata is returned by fromJSONFile when the file exists,
+// but cannot be parsed.
+var errCorruptedMetadata = errors.New("corrupted metadata file")
+
+func writeFileAtomic(filepath string, data []byte, perm os.FileMode) error {
+	tmpFilepath := filepath + tmpFileSuffix
+
+	f, err := os.OpenFile(tmpFilepath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	// Clean up the temporary file if anything below fails.
+	defer ifErr(&err, func() { os.Remove(tmpFilepath) })
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpFilepath, filepath); err != nil {
+		return err
+	}
+
+	return syncDir(path.Dir(filepath))
+}
+
+// Makes sure that the directory entries (e.g. after rename) are persisted.
+func syncDir(dirpath string) error {
+	d, err := os.Open(dirpath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// Reads mountpoints.json, calls update on it, and if it returns true,
+// writes the modified metadata back. The whole cycle is done under
+// mountpointsMetadataMtx.
+func updateMountpointsMetadata(update func(meta *mountpointsMetadata) (bool, error)) error {
+	mountpointsMetadataMtx.Lock()
+	defer mountpointsMetadataMtx.Unlock()
+
+	mountpointsMeta, err := readMountpointsMetadata()
+	if err != nil {
+		return err
+	}
+
+	modified, err := update(&mountpointsMeta)
+	if err != nil || !modified {
+		return err
+	}
+
+	return toJSONFile(fmtMountpointsMetadataPath(), &mountpointsMeta)
+}
+
+func readMountpointsMetadata() (mountpointsMetadata, error) {
+	mountpointsMeta, err := fromJSONFile(fmtMountpointsMetadataPath(), mountpointsMetadata{})
+	if err != nil {
+		return mountpointsMeta, err
+	}
+
+	if mountpointsMeta.Mountpoints == nil {
+		mountpointsMeta.Mountpoints = make(map[string]string)
+	}
+
+	return mountpointsMeta, nil
+}
+
+func readBindMetadata(mountID string) (bindMetadata, error) {
+	bindMeta, err := fromJSONFile(fmtBindMetadataPath(mountID), bindMetadata{})
+	if err != nil {
+		return bindMeta, err
+	}
+
+	if bindMeta.Targets == nil {
+		bindMeta.Targets = make(map[string]struct{})
+	}
+
+	return bindMeta, nil
+}
+
+func addMountpointMetadata(mountpoint, mountID string) error {
+	return updateMountpointsMetadata(func(meta *mountpointsMetadata) (bool, error) {
+		if storedMountID, ok := meta.Mountpoints[mountpoint]; ok {
+			if storedMountID == mountID {
+				// Mountpoint already registered with this mount ID.
+				return false, nil
+			}
+
+			return false, fmt.Errorf(
+				"failed to register mountpoint %s with mount ID %s: mountpoint already exists with mount ID %s",
+				mountpoint, mountID, storedMountID,
+			)
+		}
+
+		meta.Mountpoints[mountpoint] = mountID
+
+		return true, nil
+	})
+}
+
+func deleteMountpointMetadata(mountpoint string) error {
+	return updateMountpointsMetadata(func(meta *mountpointsMetadata) (bool, error) {
+		if _, ok := meta.Mountpoints[mountpoint]; !ok {
+			// Mountpoint not found in map, assume it was already deleted.
+			return false, nil
+		}
+
+		delete(meta.Mountpoints, mountpoint)
+
+		return true, nil
+	})
+}
+
+func getMountIDForMountpoint(mountpoint string) (string, error) {
+	mountpointsMetadataMtx.Lock()
+	defer mountpointsMetadataMtx.Unlock()
+
+	mountpointsMeta, err := readMountpointsMetadata()
+	if err != nil {
+		return "", err
+	}
+
+	return mountpointsMeta.Mountpoints[mountpoint], nil
+}
+
+// RepairMetadata checks the metadata stored in SinglemountsDir and tries to
+// repair it. Leftovers of interrupted writes are removed, and corrupted bind.json
+// and mountpoints.json files are rebuilt from each other. Corrupted mount.json
+// files cannot be rebuilt, and the respective shared mounts need to be removed
+// by the admin. Must be called before RunBlocking().
+func RepairMetadata() error {
+	entries, err := os.ReadDir(SinglemountsDir)
+	if err != nil {
+		return err
+	}
+
+	// Remove leftovers from interrupted writes.
+
+	for i := range entries {
+		name := entries[i].Name()
+
+		if strings.HasPrefix(name, tmpDirPrefix) || strings.HasSuffix(name, tmpFileSuffix) {
+			log.Infof("Removing leftover temporary metadata %s", path.Join(SinglemountsDir, name))
+
+			if err := os.RemoveAll(path.Join(SinglemountsDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	mountIDs, err := listMountIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, mountID := range mountIDs {
+		for _, filepath := range []string{fmtBindMetadataPath(mountID), fmtMountMetadataPath(mountID)} {
+			if err := os.Remove(filepath + tmpFileSuffix); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	// Check mountpoints.json.
+
+	var mountpointsMetaCorrupted bool
+
+	mountpointsMeta, err := readMountpointsMetadata()
+	if err != nil {
+		if !errors.Is(err, errCorruptedMetadata) {
+			return err
+		}
+
+		log.Errorf("%v, rebuilding it from bind metadata", err)
+		mountpointsMetaCorrupted = true
+		mountpointsMeta = mountpointsMetadata{Mountpoints: make(map[string]string)}
+	}
+
+	// Check each bind.json and mount.json.
+
+	var corruptedBindMetas []string
+
+	for _, mountID := range mountIDs {
+		if _, err := fromJSONFile(fmtMountMetadataPath(mountID), mountMetadata{}); err != nil {
+			if !errors.Is(err, errCorruptedMetadata) {
+				return err
+			}
+
+			log.Errorf("%v; shared mount %s cannot be repaired automatically", err, mountID)
+		}
+
+		bindMeta, err := readBindMetadata(mountID)
+		if err != nil {
+			if !errors.Is(err, errCorruptedMetadata) {
+				return err
+			}
+
+			log.Errorf("%v, rebuilding it from mountpoints metadata", err)
+			corruptedBindMetas = append(corruptedBindMetas, mountID)
+			continue
+		}
+
+		if mountpointsMetaCorrupted {
+			for target := range bindMeta.Targets {
+				mountpointsMeta.Mountpoints[target] = mountID
+			}
+		}
+	}
+
+	if mountpointsMetaCorrupted {
+		if err := repairMetadataFile(fmtMountpointsMetadataPath(), &mountpointsMeta); err != nil {
+			return err
+		}
+	}
+
+	for _, mountID := range corruptedBindMetas {
+		bindMeta := bindMetadata{Targets: make(map[string]struct{})}
+
+		for target, targetMountID := range mountpointsMeta.Mountpoints {
+			if targetMountID == mountID {
+				bindMeta.Targets[target] = struct{}{}
+			}
+		}
+
+		if err := repairMetadataFile(fmtBindMetadataPath(mountID), &bindMeta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Keeps a copy of the corrupted file and replaces it with repaired contents.
+func repairMetadataFile(filepath string, repaired any) error {
+	if err := os.Rename(filepath, filepath+corruptedFileSuffix); err != nil {
+		return err
+	}
+
+	log.Infof("Corrupted metadata file %s was moved to %s", filepath, filepath+corruptedFileSuffix)
+
+	return toJSONFile(filepath, repaired)
+}
@@ -0,0 +1,144 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const metricsSubsystem = "singlemount"
+
+var (
+	reconcileRuns = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "reconcile_runs_total",
+			Help:      "Number of reconciliation runs.",
+		},
+	)
+
+	reconcileRepairs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "reconcile_repairs_total",
+			Help:      "Number of repairs done during reconciliation, by action.",
+		},
+		[]string{"action"},
+	)
+
+	healthChecks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: metricsSubsystem,
+			Name:      "health_checks_total",
+			Help:      "Number of shared mount health checks, by result.",
+		},
+		[]string{"result"},
+	)
+
+	mountsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, metricsSubsystem, "mounts"),
+		"Number of shared CVMFS mounts.",
+		nil, nil,
+	)
+
+	bindTargetsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, metricsSubsystem, "bind_targets"),
+		"Number of targets bindmounted from shared CVMFS mounts.",
+		nil, nil,
+	)
+)
+
+// Reconciliation repair actions.
+const (
+	repairRemount      = "remount"
+	repairRemoveTarget = "remove_target"
+	repairRemoveMount  = "remove_mount"
+)
+
+// Health check results.
+const (
+	healthCheckHealthy  = "healthy"
+	healthCheckRepaired = "repaired"
+	healthCheckFailed   = "failed"
+)
+
+var healthCheckResults = []string{healthCheckHealthy, healthCheckRepaired, healthCheckFailed}
+
+// healthCheckTotal returns the number of health checks with result
+// since singlemount-runner has started.
+func healthCheckTotal(result string) uint64 {
+	var m dto.Metric
+	if err := healthChecks.WithLabelValues(result).Write(&m); err != nil {
+		return 0
+	}
+
+	return uint64(m.GetCounter().GetValue())
+}
+
+// sharedMountsCollector collects shared mount statistics from metadata
+// stored in SinglemountsDir at scrape time.
+type sharedMountsCollector struct{}
+
+var _ prometheus.Collector = sharedMountsCollector{}
+
+func (sharedMountsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mountsDesc
+	ch <- bindTargetsDesc
+}
+
+func (sharedMountsCollector) Collect(ch chan<- prometheus.Metric) {
+	mountIDs, err := listMountIDs()
+	if err != nil {
+		log.Errorf("Failed to collect metrics: failed to list mounts: %v", err)
+		return
+	}
+
+	var targets int
+	for _, mountID := range mountIDs {
+		bindMeta, err := readBindMetadata(mountID)
+		if err != nil {
+			log.Errorf("Failed to collect metrics: failed to read bind metadata for %s: %v", mountID, err)
+			continue
+		}
+
+		targets += len(bindMeta.Targets)
+	}
+
+	ch <- prometheus.MustNewConstMetric(mountsDesc, prometheus.GaugeValue, float64(len(mountIDs)))
+	ch <- prometheus.MustNewConstMetric(bindTargetsDesc, prometheus.GaugeValue, float64(targets))
+}
+
+func registerMetrics() {
+	// Export all health check results, even before any has happened.
+	for _, result := range healthCheckResults {
+		healthChecks.WithLabelValues(result)
+	}
+
+	metrics.MustRegister(
+		reconcileRuns,
+		reconcileRepairs,
+		healthChecks,
+		sharedMountsCollector{},
+	)
+}
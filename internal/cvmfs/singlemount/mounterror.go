@@ -0,0 +1,138 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mountErrorDomain is the domain of errdetails.ErrorInfo
+// attached to cvmfs2 mount errors.
+const mountErrorDomain = "cvmfs.csi.cern.ch"
+
+// mountErrorClass describes the reason of a failed cvmfs2 mount.
+type mountErrorClass string
+
+const (
+	// cvmfs2 failed for an unrecognized reason.
+	mountErrUnknown mountErrorClass = "UNKNOWN"
+
+	// Stratum servers or proxies could not be reached. These errors
+	// are usually transient, and the mount may be retried.
+	mountErrNetwork mountErrorClass = "NETWORK"
+
+	// Repository manifest, certificate or whitelist could not be verified.
+	// These need to be fixed either on the server side or in the client
+	// config (e.g. public keys), retrying alone will not help.
+	mountErrSignature mountErrorClass = "SIGNATURE"
+
+	// Local cache directory could not be used.
+	mountErrCache mountErrorClass = "CACHE"
+
+	// Client configuration or mount options are invalid.
+	mountErrConfig mountErrorClass = "CONFIG"
+
+	// Access to the repository, or to a local resource,
+	// was denied to the CVMFS client.
+	mountErrPermission mountErrorClass = "PERMISSION"
+
+	// The repository is already mounted. If it's mounted in the requested
+	// mountpoint, the mount is treated as successful, see cvmfsMounterUnmounter.
+	// Otherwise the repository is mounted elsewhere with the same cache.
+	mountErrAlreadyMounted mountErrorClass = "ALREADY_MOUNTED"
+)
+
+// mountError is returned when cvmfs2 fails to mount a repository.
+// It implements the interface expected by status.FromError, and so
+// it's converted into a gRPC status whose code corresponds to its class.
+type mountError struct {
+	Class      mountErrorClass
+	Repository string
+	// Error messages recognized in cvmfs2 output.
+	Messages []string
+	// Error returned from running cvmfs2.
+	Err error
+}
+
+func (e *mountError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("failed to mount repository %s, please see cvmfs-csi logs for details (%v)",
+			e.Repository, e.Err)
+	}
+
+	return fmt.Sprintf("failed to mount repository %s (%s error): %v",
+		e.Repository, strings.ToLower(string(e.Class)), e.Messages)
+}
+
+func (e *mountError) Unwrap() error {
+	return e.Err
+}
+
+func (e *mountError) code() codes.Code {
+	switch e.Class {
+	case mountErrNetwork:
+		return codes.Unavailable
+	case mountErrSignature, mountErrCache, mountErrAlreadyMounted:
+		return codes.FailedPrecondition
+	case mountErrConfig:
+		return codes.InvalidArgument
+	case mountErrPermission:
+		return codes.PermissionDenied
+	default:
+		return codes.Internal
+	}
+}
+
+func (e *mountError) GRPCStatus() *status.Status {
+	st := status.New(e.code(), e.Error())
+
+	info := &errdetails.ErrorInfo{
+		Reason: string(e.Class),
+		Domain: mountErrorDomain,
+		Metadata: map[string]string{
+			"repository": e.Repository,
+		},
+	}
+	if len(e.Messages) != 0 {
+		info.Metadata["message"] = e.Messages[len(e.Messages)-1]
+	}
+
+	stWithDetails, err := st.WithDetails(info)
+	if err != nil {
+		return st
+	}
+
+	return stWithDetails
+}
+
+// mountErrorToStatus converts err returned from makeSharedMount
+// into a gRPC status error. Errors other than mountError are
+// reported as codes.Internal.
+func mountErrorToStatus(err error) error {
+	var mntErr *mountError
+	if errors.As(err, &mntErr) {
+		return mntErr.GRPCStatus().Err()
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
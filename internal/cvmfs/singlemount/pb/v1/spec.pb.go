@@ -36,6 +36,60 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// State of a mountpoint, as seen by the singlemount-runner.
+type MountState int32
+
+const (
+	MountState_MOUNT_STATE_UNKNOWN     MountState = 0
+	MountState_MOUNT_STATE_NOT_MOUNTED MountState = 1
+	MountState_MOUNT_STATE_MOUNTED     MountState = 2
+	// The mountpoint is corrupted, e.g. because the cvmfs2 process has exited.
+	MountState_MOUNT_STATE_CORRUPTED MountState = 3
+)
+
+// Enum value maps for MountState.
+var (
+	MountState_name = map[int32]string{
+		0: "MOUNT_STATE_UNKNOWN",
+		1: "MOUNT_STATE_NOT_MOUNTED",
+		2: "MOUNT_STATE_MOUNTED",
+		3: "MOUNT_STATE_CORRUPTED",
+	}
+	MountState_value = map[string]int32{
+		"MOUNT_STATE_UNKNOWN":     0,
+		"MOUNT_STATE_NOT_MOUNTED": 1,
+		"MOUNT_STATE_MOUNTED":     2,
+		"MOUNT_STATE_CORRUPTED":   3,
+	}
+)
+
+func (x MountState) Enum() *MountState {
+	p := new(MountState)
+	*p = x
+	return p
+}
+
+func (x MountState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MountState) Descriptor() protoreflect.EnumDescriptor {
+	return file_spec_proto_enumTypes[0].Descriptor()
+}
+
+func (MountState) Type() protoreflect.EnumType {
+	return &file_spec_proto_enumTypes[0]
+}
+
+func (x MountState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MountState.Descriptor instead.
+func (MountState) EnumDescriptor() ([]byte, []int) {
+	return file_spec_proto_rawDescGZIP(), []int{0}
+}
+
 type MountSingleRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -55,6 +109,12 @@ type MountSingleRequest struct {
 	Repository string `protobuf:"bytes,4,opt,name=repository,proto3" json:"repository,omitempty"`
 	// Absolute path to an existing directory where to mount the repository.
 	Target string `protobuf:"bytes,5,opt,name=target,proto3" json:"target,omitempty"`
+	// CVMFS client config parameters (e.g. CVMFS_REPOSITORY_TAG) appended
+	// to the config, overriding values set in config or config_filepath.
+	// If neither config nor config_filepath is set, the parameters are
+	// appended to the default configuration of the repository found in
+	// /etc/cvmfs.
+	ConfigParameters map[string]string `protobuf:"bytes,6,rep,name=config_parameters,json=configParameters,proto3" json:"config_parameters,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
 func (x *MountSingleRequest) Reset() {
@@ -124,6 +184,13 @@ func (x *MountSingleRequest) GetTarget() string {
 	return ""
 }
 
+func (x *MountSingleRequest) GetConfigParameters() map[string]string {
+	if x != nil {
+		return x.ConfigParameters
+	}
+	return nil
+}
+
 type MountSingleResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -248,12 +315,353 @@ func (*UnmountSingleResponse) Descriptor() ([]byte, []int) {
 	return file_spec_proto_rawDescGZIP(), []int{3}
 }
 
+type BindTarget struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Absolute path to the target bindmount.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Live state of the target mountpoint.
+	State MountState `protobuf:"varint,2,opt,name=state,proto3,enum=cvmfs.csi.cern.ch.v1.MountState" json:"state,omitempty"`
+}
+
+func (x *BindTarget) Reset() {
+	*x = BindTarget{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_spec_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BindTarget) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BindTarget) ProtoMessage() {}
+
+func (x *BindTarget) ProtoReflect() protoreflect.Message {
+	mi := &file_spec_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BindTarget.ProtoReflect.Descriptor instead.
+func (*BindTarget) Descriptor() ([]byte, []int) {
+	return file_spec_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BindTarget) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *BindTarget) GetState() MountState {
+	if x != nil {
+		return x.State
+	}
+	return MountState_MOUNT_STATE_UNKNOWN
+}
+
+type SharedMount struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Identifier of the shared mount, as passed in MountSingleRequest.mount_id.
+	MountId string `protobuf:"bytes,1,opt,name=mount_id,json=mountId,proto3" json:"mount_id,omitempty"`
+	// CVMFS repository mounted in this shared mount.
+	Repository string `protobuf:"bytes,2,opt,name=repository,proto3" json:"repository,omitempty"`
+	// CVMFS client configuration used for the cvmfs2 mount.
+	Config string `protobuf:"bytes,3,opt,name=config,proto3" json:"config,omitempty"`
+	// Absolute path to the cvmfs2 mountpoint.
+	Mountpoint string `protobuf:"bytes,4,opt,name=mountpoint,proto3" json:"mountpoint,omitempty"`
+	// Live state of the cvmfs2 mountpoint.
+	State MountState `protobuf:"varint,5,opt,name=state,proto3,enum=cvmfs.csi.cern.ch.v1.MountState" json:"state,omitempty"`
+	// Targets that are bindmounted from this shared mount.
+	Targets []*BindTarget `protobuf:"bytes,6,rep,name=targets,proto3" json:"targets,omitempty"`
+}
+
+func (x *SharedMount) Reset() {
+	*x = SharedMount{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_spec_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SharedMount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SharedMount) ProtoMessage() {}
+
+func (x *SharedMount) ProtoReflect() protoreflect.Message {
+	mi := &file_spec_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SharedMount.ProtoReflect.Descriptor instead.
+func (*SharedMount) Descriptor() ([]byte, []int) {
+	return file_spec_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SharedMount) GetMountId() string {
+	if x != nil {
+		return x.MountId
+	}
+	return ""
+}
+
+func (x *SharedMount) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *SharedMount) GetConfig() string {
+	if x != nil {
+		return x.Config
+	}
+	return ""
+}
+
+func (x *SharedMount) GetMountpoint() string {
+	if x != nil {
+		return x.Mountpoint
+	}
+	return ""
+}
+
+func (x *SharedMount) GetState() MountState {
+	if x != nil {
+		return x.State
+	}
+	return MountState_MOUNT_STATE_UNKNOWN
+}
+
+func (x *SharedMount) GetTargets() []*BindTarget {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+type ListMountsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListMountsRequest) Reset() {
+	*x = ListMountsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_spec_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListMountsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMountsRequest) ProtoMessage() {}
+
+func (x *ListMountsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_spec_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMountsRequest.ProtoReflect.Descriptor instead.
+func (*ListMountsRequest) Descriptor() ([]byte, []int) {
+	return file_spec_proto_rawDescGZIP(), []int{6}
+}
+
+type ListMountsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Mounts []*SharedMount `protobuf:"bytes,1,rep,name=mounts,proto3" json:"mounts,omitempty"`
+}
+
+func (x *ListMountsResponse) Reset() {
+	*x = ListMountsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_spec_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListMountsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMountsResponse) ProtoMessage() {}
+
+func (x *ListMountsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_spec_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMountsResponse.ProtoReflect.Descriptor instead.
+func (*ListMountsResponse) Descriptor() ([]byte, []int) {
+	return file_spec_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListMountsResponse) GetMounts() []*SharedMount {
+	if x != nil {
+		return x.Mounts
+	}
+	return nil
+}
+
+type GetMountRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Mount ID of the shared mount to look up.
+	// Exactly one of mount_id and mountpoint must be set.
+	MountId string `protobuf:"bytes,1,opt,name=mount_id,json=mountId,proto3" json:"mount_id,omitempty"`
+	// Look up the shared mount that is bindmounted to this target.
+	// Exactly one of mount_id and mountpoint must be set.
+	Mountpoint string `protobuf:"bytes,2,opt,name=mountpoint,proto3" json:"mountpoint,omitempty"`
+}
+
+func (x *GetMountRequest) Reset() {
+	*x = GetMountRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_spec_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetMountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMountRequest) ProtoMessage() {}
+
+func (x *GetMountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_spec_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMountRequest.ProtoReflect.Descriptor instead.
+func (*GetMountRequest) Descriptor() ([]byte, []int) {
+	return file_spec_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetMountRequest) GetMountId() string {
+	if x != nil {
+		return x.MountId
+	}
+	return ""
+}
+
+func (x *GetMountRequest) GetMountpoint() string {
+	if x != nil {
+		return x.Mountpoint
+	}
+	return ""
+}
+
+type GetMountResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Mount *SharedMount `protobuf:"bytes,1,opt,name=mount,proto3" json:"mount,omitempty"`
+}
+
+func (x *GetMountResponse) Reset() {
+	*x = GetMountResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_spec_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetMountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMountResponse) ProtoMessage() {}
+
+func (x *GetMountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_spec_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMountResponse.ProtoReflect.Descriptor instead.
+func (*GetMountResponse) Descriptor() ([]byte, []int) {
+	return file_spec_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetMountResponse) GetMount() *SharedMount {
+	if x != nil {
+		return x.Mount
+	}
+	return nil
+}
+
 var File_spec_proto protoreflect.FileDescriptor
 
 var file_spec_proto_rawDesc = []byte{
 	0x0a, 0x0a, 0x73, 0x70, 0x65, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x14, 0x63, 0x76,
 	0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e,
-	0x76, 0x31, 0x22, 0xa8, 0x01, 0x0a, 0x12, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67,
+	0x76, 0x31, 0x22, 0xda, 0x02, 0x0a, 0x12, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67,
 	0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a, 0x08, 0x6d, 0x6f, 0x75,
 	0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x6f, 0x75,
 	0x6e, 0x74, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x02,
@@ -263,31 +671,100 @@ var file_spec_proto_rawDesc = []byte{
 	0x65, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74,
 	0x6f, 0x72, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73,
 	0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x18,
-	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x22, 0x15, 0x0a,
-	0x13, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x36, 0x0a, 0x14, 0x55, 0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x53,
-	0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x0a,
-	0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x22, 0x17, 0x0a, 0x15,
-	0x55, 0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0xce, 0x01, 0x0a, 0x06, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65,
-	0x12, 0x5e, 0x0a, 0x05, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x28, 0x2e, 0x63, 0x76, 0x6d, 0x66,
-	0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31,
-	0x2e, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x1a, 0x29, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x6b, 0x0a,
+	0x11, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x5f, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65,
+	0x72, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x3e, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73,
+	0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e,
+	0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74,
+	0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x10, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x1a, 0x43, 0x0a, 0x15, 0x43, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22,
+	0x15, 0x0a, 0x13, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x36, 0x0a, 0x14, 0x55, 0x6e, 0x6d, 0x6f, 0x75, 0x6e,
+	0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e,
+	0x0a, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x22, 0x17,
+	0x0a, 0x15, 0x55, 0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x58, 0x0a, 0x0a, 0x42, 0x69, 0x6e, 0x64, 0x54,
+	0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x36, 0x0a, 0x05, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x20, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73,
+	0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e,
+	0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x22, 0xf4, 0x01, 0x0a, 0x0b, 0x53, 0x68, 0x61, 0x72, 0x65, 0x64, 0x4d, 0x6f, 0x75, 0x6e,
+	0x74, 0x12, 0x19, 0x0a, 0x08, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x1e, 0x0a, 0x0a,
+	0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0a, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x16, 0x0a, 0x06,
+	0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x12, 0x1e, 0x0a, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70, 0x6f, 0x69,
+	0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70,
+	0x6f, 0x69, 0x6e, 0x74, 0x12, 0x36, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x20, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e,
 	0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x75, 0x6e, 0x74,
-	0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
-	0x12, 0x64, 0x0a, 0x07, 0x55, 0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x2a, 0x2e, 0x63, 0x76,
-	0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e,
-	0x76, 0x31, 0x2e, 0x55, 0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x3a, 0x0a, 0x07,
+	0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e,
+	0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63,
+	0x68, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x69, 0x6e, 0x64, 0x54, 0x61, 0x72, 0x67, 0x65, 0x74, 0x52,
+	0x07, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x73, 0x22, 0x13, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74,
+	0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x4f, 0x0a,
+	0x12, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x39, 0x0a, 0x06, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e,
+	0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x65,
+	0x64, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x06, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x22, 0x4c,
+	0x0a, 0x0f, 0x47, 0x65, 0x74, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x19, 0x0a, 0x08, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x1e, 0x0a, 0x0a,
+	0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x22, 0x4b, 0x0a, 0x10,
+	0x47, 0x65, 0x74, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x37, 0x0a, 0x05, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x21, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e,
+	0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x65, 0x64, 0x4d, 0x6f, 0x75,
+	0x6e, 0x74, 0x52, 0x05, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x2a, 0x76, 0x0a, 0x0a, 0x4d, 0x6f, 0x75,
+	0x6e, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x17, 0x0a, 0x13, 0x4d, 0x4f, 0x55, 0x4e, 0x54,
+	0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00,
+	0x12, 0x1b, 0x0a, 0x17, 0x4d, 0x4f, 0x55, 0x4e, 0x54, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f,
+	0x4e, 0x4f, 0x54, 0x5f, 0x4d, 0x4f, 0x55, 0x4e, 0x54, 0x45, 0x44, 0x10, 0x01, 0x12, 0x17, 0x0a,
+	0x13, 0x4d, 0x4f, 0x55, 0x4e, 0x54, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x4d, 0x4f, 0x55,
+	0x4e, 0x54, 0x45, 0x44, 0x10, 0x02, 0x12, 0x19, 0x0a, 0x15, 0x4d, 0x4f, 0x55, 0x4e, 0x54, 0x5f,
+	0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x43, 0x4f, 0x52, 0x52, 0x55, 0x50, 0x54, 0x45, 0x44, 0x10,
+	0x03, 0x32, 0x8e, 0x03, 0x0a, 0x06, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x12, 0x5e, 0x0a, 0x05,
+	0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x28, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73,
+	0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x75,
+	0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x29, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e,
+	0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67,
+	0x6c, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x64, 0x0a, 0x07,
+	0x55, 0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x2a, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e,
 	0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x55,
-	0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x3f, 0x5a, 0x3d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
-	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x65, 0x72, 0x6e, 0x6f, 0x70, 0x73, 0x2f, 0x63, 0x76, 0x6d,
-	0x66, 0x73, 0x2d, 0x63, 0x73, 0x69, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f,
-	0x63, 0x76, 0x6d, 0x66, 0x73, 0x2f, 0x73, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x6d, 0x6f, 0x75, 0x6e,
-	0x74, 0x2f, 0x70, 0x62, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6e, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x2b, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e,
+	0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x6e, 0x6d, 0x6f, 0x75,
+	0x6e, 0x74, 0x53, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x00, 0x12, 0x61, 0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x73,
+	0x12, 0x27, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72,
+	0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x75, 0x6e,
+	0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x28, 0x2e, 0x63, 0x76, 0x6d, 0x66,
+	0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31,
+	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x5b, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x4d, 0x6f, 0x75, 0x6e,
+	0x74, 0x12, 0x25, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65,
+	0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x6f, 0x75, 0x6e,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x26, 0x2e, 0x63, 0x76, 0x6d, 0x66, 0x73,
+	0x2e, 0x63, 0x73, 0x69, 0x2e, 0x63, 0x65, 0x72, 0x6e, 0x2e, 0x63, 0x68, 0x2e, 0x76, 0x31, 0x2e,
+	0x47, 0x65, 0x74, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x00, 0x42, 0x45, 0x5a, 0x43, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2d, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x69, 0x62, 0x2f, 0x63,
+	0x76, 0x6d, 0x66, 0x73, 0x2d, 0x63, 0x73, 0x69, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61,
+	0x6c, 0x2f, 0x63, 0x76, 0x6d, 0x66, 0x73, 0x2f, 0x73, 0x69, 0x6e, 0x67, 0x6c, 0x65, 0x6d, 0x6f,
+	0x75, 0x6e, 0x74, 0x2f, 0x70, 0x62, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
 }
 
 var (
@@ -302,23 +779,42 @@ func file_spec_proto_rawDescGZIP() []byte {
 	return file_spec_proto_rawDescData
 }
 
-var file_spec_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_spec_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_spec_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_spec_proto_goTypes = []interface{}{
-	(*MountSingleRequest)(nil),    // 0: cvmfs.csi.cern.ch.v1.MountSingleRequest
-	(*MountSingleResponse)(nil),   // 1: cvmfs.csi.cern.ch.v1.MountSingleResponse
-	(*UnmountSingleRequest)(nil),  // 2: cvmfs.csi.cern.ch.v1.UnmountSingleRequest
-	(*UnmountSingleResponse)(nil), // 3: cvmfs.csi.cern.ch.v1.UnmountSingleResponse
+	(MountState)(0),               // 0: cvmfs.csi.cern.ch.v1.MountState
+	(*MountSingleRequest)(nil),    // 1: cvmfs.csi.cern.ch.v1.MountSingleRequest
+	(*MountSingleResponse)(nil),   // 2: cvmfs.csi.cern.ch.v1.MountSingleResponse
+	(*UnmountSingleRequest)(nil),  // 3: cvmfs.csi.cern.ch.v1.UnmountSingleRequest
+	(*UnmountSingleResponse)(nil), // 4: cvmfs.csi.cern.ch.v1.UnmountSingleResponse
+	(*BindTarget)(nil),            // 5: cvmfs.csi.cern.ch.v1.BindTarget
+	(*SharedMount)(nil),           // 6: cvmfs.csi.cern.ch.v1.SharedMount
+	(*ListMountsRequest)(nil),     // 7: cvmfs.csi.cern.ch.v1.ListMountsRequest
+	(*ListMountsResponse)(nil),    // 8: cvmfs.csi.cern.ch.v1.ListMountsResponse
+	(*GetMountRequest)(nil),       // 9: cvmfs.csi.cern.ch.v1.GetMountRequest
+	(*GetMountResponse)(nil),      // 10: cvmfs.csi.cern.ch.v1.GetMountResponse
+	nil,                           // 11: cvmfs.csi.cern.ch.v1.MountSingleRequest.ConfigParametersEntry
 }
 var file_spec_proto_depIdxs = []int32{
-	0, // 0: cvmfs.csi.cern.ch.v1.Single.Mount:input_type -> cvmfs.csi.cern.ch.v1.MountSingleRequest
-	2, // 1: cvmfs.csi.cern.ch.v1.Single.Unmount:input_type -> cvmfs.csi.cern.ch.v1.UnmountSingleRequest
-	1, // 2: cvmfs.csi.cern.ch.v1.Single.Mount:output_type -> cvmfs.csi.cern.ch.v1.MountSingleResponse
-	3, // 3: cvmfs.csi.cern.ch.v1.Single.Unmount:output_type -> cvmfs.csi.cern.ch.v1.UnmountSingleResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	11, // 0: cvmfs.csi.cern.ch.v1.MountSingleRequest.config_parameters:type_name -> cvmfs.csi.cern.ch.v1.MountSingleRequest.ConfigParametersEntry
+	0,  // 1: cvmfs.csi.cern.ch.v1.BindTarget.state:type_name -> cvmfs.csi.cern.ch.v1.MountState
+	0,  // 2: cvmfs.csi.cern.ch.v1.SharedMount.state:type_name -> cvmfs.csi.cern.ch.v1.MountState
+	5,  // 3: cvmfs.csi.cern.ch.v1.SharedMount.targets:type_name -> cvmfs.csi.cern.ch.v1.BindTarget
+	6,  // 4: cvmfs.csi.cern.ch.v1.ListMountsResponse.mounts:type_name -> cvmfs.csi.cern.ch.v1.SharedMount
+	6,  // 5: cvmfs.csi.cern.ch.v1.GetMountResponse.mount:type_name -> cvmfs.csi.cern.ch.v1.SharedMount
+	1,  // 6: cvmfs.csi.cern.ch.v1.Single.Mount:input_type -> cvmfs.csi.cern.ch.v1.MountSingleRequest
+	3,  // 7: cvmfs.csi.cern.ch.v1.Single.Unmount:input_type -> cvmfs.csi.cern.ch.v1.UnmountSingleRequest
+	7,  // 8: cvmfs.csi.cern.ch.v1.Single.ListMounts:input_type -> cvmfs.csi.cern.ch.v1.ListMountsRequest
+	9,  // 9: cvmfs.csi.cern.ch.v1.Single.GetMount:input_type -> cvmfs.csi.cern.ch.v1.GetMountRequest
+	2,  // 10: cvmfs.csi.cern.ch.v1.Single.Mount:output_type -> cvmfs.csi.cern.ch.v1.MountSingleResponse
+	4,  // 11: cvmfs.csi.cern.ch.v1.Single.Unmount:output_type -> cvmfs.csi.cern.ch.v1.UnmountSingleResponse
+	8,  // 12: cvmfs.csi.cern.ch.v1.Single.ListMounts:output_type -> cvmfs.csi.cern.ch.v1.ListMountsResponse
+	10, // 13: cvmfs.csi.cern.ch.v1.Single.GetMount:output_type -> cvmfs.csi.cern.ch.v1.GetMountResponse
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_spec_proto_init() }
@@ -375,19 +871,92 @@ func file_spec_proto_init() {
 				return nil
 			}
 		}
+		file_spec_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BindTarget); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_spec_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SharedMount); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_spec_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListMountsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_spec_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListMountsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_spec_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetMountRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_spec_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetMountResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_spec_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_spec_proto_goTypes,
 		DependencyIndexes: file_spec_proto_depIdxs,
+		EnumInfos:         file_spec_proto_enumTypes,
 		MessageInfos:      file_spec_proto_msgTypes,
 	}.Build()
 	File_spec_proto = out.File
@@ -26,6 +26,10 @@ type SingleClient interface {
 	Mount(ctx context.Context, in *MountSingleRequest, opts ...grpc.CallOption) (*MountSingleResponse, error)
 	// Unmount a single CVMFS repository.
 	Unmount(ctx context.Context, in *UnmountSingleRequest, opts ...grpc.CallOption) (*UnmountSingleResponse, error)
+	// Lists all shared mounts managed by Single.
+	ListMounts(ctx context.Context, in *ListMountsRequest, opts ...grpc.CallOption) (*ListMountsResponse, error)
+	// Returns a single shared mount managed by Single.
+	GetMount(ctx context.Context, in *GetMountRequest, opts ...grpc.CallOption) (*GetMountResponse, error)
 }
 
 type singleClient struct {
@@ -54,6 +58,24 @@ func (c *singleClient) Unmount(ctx context.Context, in *UnmountSingleRequest, op
 	return out, nil
 }
 
+func (c *singleClient) ListMounts(ctx context.Context, in *ListMountsRequest, opts ...grpc.CallOption) (*ListMountsResponse, error) {
+	out := new(ListMountsResponse)
+	err := c.cc.Invoke(ctx, "/cvmfs.csi.cern.ch.v1.Single/ListMounts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *singleClient) GetMount(ctx context.Context, in *GetMountRequest, opts ...grpc.CallOption) (*GetMountResponse, error) {
+	out := new(GetMountResponse)
+	err := c.cc.Invoke(ctx, "/cvmfs.csi.cern.ch.v1.Single/GetMount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SingleServer is the server API for Single service.
 // All implementations must embed UnimplementedSingleServer
 // for forward compatibility
@@ -62,6 +84,10 @@ type SingleServer interface {
 	Mount(context.Context, *MountSingleRequest) (*MountSingleResponse, error)
 	// Unmount a single CVMFS repository.
 	Unmount(context.Context, *UnmountSingleRequest) (*UnmountSingleResponse, error)
+	// Lists all shared mounts managed by Single.
+	ListMounts(context.Context, *ListMountsRequest) (*ListMountsResponse, error)
+	// Returns a single shared mount managed by Single.
+	GetMount(context.Context, *GetMountRequest) (*GetMountResponse, error)
 	mustEmbedUnimplementedSingleServer()
 }
 
@@ -75,6 +101,12 @@ func (UnimplementedSingleServer) Mount(context.Context, *MountSingleRequest) (*M
 func (UnimplementedSingleServer) Unmount(context.Context, *UnmountSingleRequest) (*UnmountSingleResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Unmount not implemented")
 }
+func (UnimplementedSingleServer) ListMounts(context.Context, *ListMountsRequest) (*ListMountsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMounts not implemented")
+}
+func (UnimplementedSingleServer) GetMount(context.Context, *GetMountRequest) (*GetMountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMount not implemented")
+}
 func (UnimplementedSingleServer) mustEmbedUnimplementedSingleServer() {}
 
 // UnsafeSingleServer may be embedded to opt out of forward compatibility for this service.
@@ -124,6 +156,42 @@ func _Single_Unmount_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Single_ListMounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMountsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SingleServer).ListMounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cvmfs.csi.cern.ch.v1.Single/ListMounts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SingleServer).ListMounts(ctx, req.(*ListMountsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Single_GetMount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SingleServer).GetMount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cvmfs.csi.cern.ch.v1.Single/GetMount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SingleServer).GetMount(ctx, req.(*GetMountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Single_ServiceDesc is the grpc.ServiceDesc for Single service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -139,6 +207,14 @@ var Single_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Unmount",
 			Handler:    _Single_Unmount_Handler,
 		},
+		{
+			MethodName: "ListMounts",
+			Handler:    _Single_ListMounts_Handler,
+		},
+		{
+			MethodName: "GetMount",
+			Handler:    _Single_GetMount_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "spec.proto",
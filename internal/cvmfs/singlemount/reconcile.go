@@ -0,0 +1,299 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	pb "github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount/pb/v1"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
+)
+
+// Reconciliation restores singlemount state after singlemount-runner restarts.
+// cvmfs2 processes don't survive the restart of the container, and leave
+// behind corrupted FUSE mountpoints, as well as stale bindmounts in targets.
+// For each shared mount stored in SinglemountsDir we:
+//
+//   - remove targets that no longer exist on the node,
+//   - remove the shared mount if it has no targets left,
+//   - remount the cvmfs2 mount if it's corrupted or not mounted,
+//   - rebind targets that are corrupted or not mounted.
+
+// runReconciler reconciles the mounts once, and then every period.
+// Zero period means only once.
+func (s *singleMountServer) runReconciler(period time.Duration) {
+	s.reconcile()
+
+	if period <= 0 {
+		return
+	}
+
+	t := time.NewTicker(period)
+	defer t.Stop()
+
+	for range t.C {
+		s.reconcile()
+	}
+}
+
+func (s *singleMountServer) reconcile() {
+	log.Tracef("Reconciling %s", SinglemountsDir)
+	reconcileRuns.Inc()
+
+	ctx, span := tracing.Start(context.Background(), "reconcile")
+	defer span.End()
+
+	mountIDs, err := listMountIDs()
+	if err != nil {
+		log.Errorf("Failed to reconcile %s: failed to list mounts: %v", SinglemountsDir, err)
+		return
+	}
+
+	for _, mountID := range mountIDs {
+		if _, isPending := s.pendingOps.LoadOrStore(mountID, true); isPending {
+			// Mount or Unmount is in progress, we'll get to this one next time.
+			continue
+		}
+
+		if err := reconcileSharedMount(ctx, mountID); err != nil {
+			log.ErrorS(err, "Failed to reconcile", log.KeyMountID, mountID)
+		}
+
+		s.pendingOps.Delete(mountID)
+	}
+
+	if err := s.reconcileMountpointsMetadata(); err != nil {
+		log.Errorf("Failed to reconcile mountpoints metadata: %v", err)
+	}
+}
+
+func reconcileSharedMount(ctx context.Context, mountID string) error {
+	if _, err := fromJSONFile(fmtMountMetadataPath(mountID), mountMetadata{}); err != nil {
+		// Without mount metadata we cannot remount anything.
+		return err
+	}
+
+	bindMeta, err := readBindMetadata(mountID)
+	if err != nil {
+		return err
+	}
+
+	// Remove targets that don't exist anymore.
+
+	for target := range bindMeta.Targets {
+		if _, err := os.Stat(target); err == nil || !os.IsNotExist(err) {
+			continue
+		}
+
+		log.InfoS("Target doesn't exist anymore, removing it", log.KeyMountID, mountID, log.KeyTargetPath, target)
+
+		if err := deleteTarget(ctx, target, mountID); err != nil {
+			return err
+		}
+
+		delete(bindMeta.Targets, target)
+		reconcileRepairs.WithLabelValues(repairRemoveTarget).Inc()
+	}
+
+	if len(bindMeta.Targets) == 0 {
+		log.InfoS("Mount has no targets, removing it", log.KeyMountID, mountID)
+
+		if err := removeSharedMount(ctx, mountID); err != nil {
+			return err
+		}
+
+		reconcileRepairs.WithLabelValues(repairRemoveMount).Inc()
+		return nil
+	}
+
+	// Make sure cvmfs2 is mounted.
+
+	mountpoint := fmtMountpointPath(mountID)
+
+	mntState, err := mountutils.GetState(mountpoint)
+	if err != nil {
+		return fmt.Errorf("failed to probe mountpoint %s: %v", mountpoint, err)
+	}
+
+	if mntState != mountutils.StMounted {
+		log.Infof("CVMFS mount %s is %s, remounting", mountpoint, mntState)
+	}
+
+	mountMeta, err := fromJSONFile(fmtMountMetadataPath(mountID), mountMetadata{})
+	if err != nil {
+		return err
+	}
+
+	err = tryMountOrRecover(ctx,
+		&cvmfsMounterUnmounter{
+			repository: mountMeta.Repository,
+			configPath: fmtConfigPath(mountID),
+		},
+		mountpoint,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remount %s: %v", mountpoint, err)
+	}
+
+	if mntState != mountutils.StMounted {
+		reconcileRepairs.WithLabelValues(repairRemount).Inc()
+	}
+
+	// Make sure targets are bound to the cvmfs2 mount. If we had to remount
+	// cvmfs2 above, existing bindmounts still point to the old, dead mount.
+
+	return rebindTargets(ctx, mountpoint, bindMeta.Targets, mntState != mountutils.StMounted)
+}
+
+// Unmounts the cvmfs2 mount of mountID, mounts it again, and rebinds all its targets.
+func remountSharedMount(ctx context.Context, mountID string) error {
+	mountMeta, err := fromJSONFile(fmtMountMetadataPath(mountID), mountMetadata{})
+	if err != nil {
+		return err
+	}
+
+	bindMeta, err := readBindMetadata(mountID)
+	if err != nil {
+		return err
+	}
+
+	mountpoint := fmtMountpointPath(mountID)
+
+	if err = (cvmfsMounterUnmounter{}).unmount(ctx, mountpoint); err != nil {
+		return err
+	}
+
+	err = tryMountOrRecover(ctx,
+		&cvmfsMounterUnmounter{
+			repository: mountMeta.Repository,
+			configPath: fmtConfigPath(mountID),
+		},
+		mountpoint,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remount %s: %v", mountpoint, err)
+	}
+
+	return rebindTargets(ctx, mountpoint, bindMeta.Targets, true)
+}
+
+// Makes sure all targets are bindmounted from cvmfsMountpoint. If unbindFirst
+// is set, existing bindmounts are unmounted first, e.g. because they point to
+// a cvmfs2 mount that's been replaced.
+func rebindTargets(ctx context.Context, cvmfsMountpoint string, targets map[string]struct{}, unbindFirst bool) error {
+	var failed int
+
+	for target := range targets {
+		if unbindFirst {
+			if err := (bindMounterUnmounter{}).unmount(ctx, target); err != nil {
+				log.Errorf("Failed to unmount stale target %s: %v", target, err)
+				failed++
+				continue
+			}
+		}
+
+		targetState, err := mountutils.GetState(target)
+		if err != nil {
+			log.Errorf("Failed to probe target %s: %v", target, err)
+			failed++
+			continue
+		}
+
+		if targetState != mountutils.StMounted {
+			log.Infof("Target %s of %s is %s, rebinding", target, cvmfsMountpoint, targetState)
+		}
+
+		err = tryMountOrRecover(ctx,
+			&bindMounterUnmounter{
+				cvmfsMountpoint: cvmfsMountpoint,
+			},
+			target,
+		)
+		if err != nil {
+			log.Errorf("Failed to rebind target %s: %v", target, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to rebind %d out of %d targets", failed, len(targets))
+	}
+
+	return nil
+}
+
+// Removes target from both bind metadata and mountpoints metadata.
+func deleteTarget(ctx context.Context, target, mountID string) error {
+	if err := (bindMounterUnmounter{}).unmount(ctx, target); err != nil {
+		return err
+	}
+
+	if _, err := deleteBindMetadata(&pb.UnmountSingleRequest{Mountpoint: target}, mountID); err != nil {
+		return err
+	}
+
+	return deleteMountpointMetadata(target)
+}
+
+// Unmounts cvmfs2 and removes the singlemount directory of mountID.
+func removeSharedMount(ctx context.Context, mountID string) error {
+	if err := (cvmfsMounterUnmounter{}).unmount(ctx, fmtMountpointPath(mountID)); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(fmtMountSingleBasePath(mountID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Removes mountpoints that reference mount IDs that don't exist anymore.
+// Mount registers the mountpoint before it creates the singlemount directory,
+// and so mount IDs with a pending operation are skipped, the same as when
+// reconciling shared mounts.
+func (s *singleMountServer) reconcileMountpointsMetadata() error {
+	return updateMountpointsMetadata(func(meta *mountpointsMetadata) (bool, error) {
+		var modified bool
+
+		for mountpoint, mountID := range meta.Mountpoints {
+			if _, isPending := s.pendingOps.LoadOrStore(mountID, true); isPending {
+				continue
+			}
+
+			_, err := os.Stat(fmtMountSingleBasePath(mountID))
+
+			s.pendingOps.Delete(mountID)
+
+			if err == nil || !os.IsNotExist(err) {
+				continue
+			}
+
+			log.Infof("Mountpoint %s references non-existent mount ID %s, removing it", mountpoint, mountID)
+
+			delete(meta.Mountpoints, mountpoint)
+			modified = true
+		}
+
+		return modified, nil
+	})
+}
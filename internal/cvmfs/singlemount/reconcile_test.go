@@ -0,0 +1,139 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"os"
+	"testing"
+)
+
+// useTempSinglemountsDir points SinglemountsDir to an empty temporary
+// directory for the duration of the test.
+func useTempSinglemountsDir(t *testing.T) {
+	t.Helper()
+
+	orig := SinglemountsDir
+	SinglemountsDir = t.TempDir()
+	t.Cleanup(func() { SinglemountsDir = orig })
+}
+
+func TestReconcileMountpointsMetadataWithPendingMount(t *testing.T) {
+	useTempSinglemountsDir(t)
+
+	s := &singleMountServer{}
+
+	// Mount of "pending" has registered its target, but hasn't created
+	// the singlemount directory yet. "stale" has no directory and no pending
+	// operation. "mounted" is a complete shared mount.
+
+	if err := os.Mkdir(fmtMountSingleBasePath("mounted"), 0o775); err != nil {
+		t.Fatal(err)
+	}
+
+	for target, mountID := range map[string]string{
+		"/targets/pending": "pending",
+		"/targets/stale":   "stale",
+		"/targets/mounted": "mounted",
+	} {
+		if err := addMountpointMetadata(target, mountID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.pendingOps.Store("pending", true)
+
+	s.reconcile()
+
+	meta, err := readMountpointsMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"/targets/pending": "pending",
+		"/targets/mounted": "mounted",
+	}
+
+	if len(meta.Mountpoints) != len(want) {
+		t.Fatalf("got mountpoints %v, want %v", meta.Mountpoints, want)
+	}
+	for target, mountID := range want {
+		if meta.Mountpoints[target] != mountID {
+			t.Fatalf("got mountpoints %v, want %v", meta.Mountpoints, want)
+		}
+	}
+
+	if _, isPending := s.pendingOps.Load("pending"); !isPending {
+		t.Error("reconcile released the pending operation of a Mount in progress")
+	}
+
+	// The Mount failed and was cleaned up without removing its target,
+	// leaving a stale record. Once the operation is done, reconcile
+	// removes it.
+
+	s.pendingOps.Delete("pending")
+
+	s.reconcile()
+
+	if meta, err = readMountpointsMetadata(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := meta.Mountpoints["/targets/pending"]; ok {
+		t.Errorf("stale mountpoint of a finished operation was not removed: %v", meta.Mountpoints)
+	}
+}
+
+func TestReconcileDuringMount(t *testing.T) {
+	useTempSinglemountsDir(t)
+
+	s := &singleMountServer{}
+
+	// Mount takes the mount ID in pendingOps, registers the target,
+	// and only then creates the singlemount directory. Run reconcile
+	// concurrently in between these steps.
+
+	if _, isPending := s.pendingOps.LoadOrStore("vol-1", true); isPending {
+		t.Fatal("unexpected pending operation")
+	}
+
+	if err := addMountpointMetadata("/targets/vol-1", "vol-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.reconcile()
+		close(done)
+	}()
+	<-done
+
+	if err := os.Mkdir(fmtMountSingleBasePath("vol-1"), 0o775); err != nil {
+		t.Fatal(err)
+	}
+
+	s.pendingOps.Delete("vol-1")
+
+	mountID, err := getMountIDForMountpoint("/targets/vol-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mountID != "vol-1" {
+		t.Errorf("mountpoint of a Mount in progress was removed by reconcile")
+	}
+}
@@ -23,7 +23,9 @@ import (
 	"os"
 	goexec "os/exec"
 	"sync"
+	"time"
 
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/clientconfig"
 	pb "github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount/pb/v1"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/grpcutils"
@@ -41,10 +43,38 @@ type (
 		// Track pending Mount/Unmount calls (key'd by mount ID).
 		// Return status.Aborted if such a call is pending.
 		pendingOps sync.Map
+
+		configValidation clientconfig.ValidateOpts
+
+		// Reject volume client config that is not made of simple
+		// assignments, instead of only logging a warning.
+		strictConfigParsing bool
 	}
 
 	Opts struct {
 		Endpoint string
+
+		// How often to reconcile shared mounts. Reconciliation
+		// always runs once at startup. Zero means never again.
+		ReconcilePeriod time.Duration
+
+		// How often to check health of cvmfs2 clients using cvmfs_talk.
+		// Zero means never.
+		HealthCheckPeriod time.Duration
+
+		// Directories that path parameters (e.g. CVMFS_CACHE_BASE) in volume
+		// client config may point into, in addition to the mount's own
+		// singlemount directory.
+		ConfigAllowedPaths []string
+
+		// Client config parameters accepted in volume client
+		// config in addition to the known CVMFS parameters.
+		ConfigExtraParameters []string
+
+		// Reject volume client config that contains anything else than
+		// CVMFS_<NAME>=<VALUE> assignments. Such config is only logged
+		// with a warning otherwise.
+		StrictConfigParsing bool
 	}
 )
 
@@ -70,7 +100,28 @@ func RunBlocking(o Opts) error {
 		return err
 	}
 
-	pb.RegisterSingleServer(s.GRPCServer, &singleMountServer{})
+	registerMetrics()
+
+	srv := &singleMountServer{
+		configValidation: clientconfig.ValidateOpts{
+			ExtraParameters: o.ConfigExtraParameters,
+			AllowedPaths:    o.ConfigAllowedPaths,
+		},
+		strictConfigParsing: o.StrictConfigParsing,
+	}
+
+	// Restore shared mounts left behind by the previous singlemount-runner run.
+	// This runs in the background, as remounting many mounts, or mounts
+	// with unresponsive servers, may take a long time, and we don't want
+	// to block the endpoint in the meantime. Requests for mounts that are
+	// being reconciled return codes.Aborted, and are retried by the caller.
+	go srv.runReconciler(o.ReconcilePeriod)
+
+	if o.HealthCheckPeriod > 0 {
+		go srv.runHealthChecker(o.HealthCheckPeriod)
+	}
+
+	pb.RegisterSingleServer(s.GRPCServer, srv)
 
 	return s.Serve()
 }
@@ -91,13 +142,12 @@ func validateMountSingleRequest(req *pb.MountSingleRequest) error {
 		return err
 	}
 
-	if (req.Config == "" && req.ConfigFilepath == "") ||
-		(req.Config != "" && req.ConfigFilepath != "") {
-		return fmt.Errorf("exactly one of config and config_filepath must be non-empty")
+	if req.Config != "" && req.ConfigFilepath != "" {
+		return fmt.Errorf("only one of config and config_filepath may be non-empty")
 	}
 
-	if err := checkNotEmpty(req.Config, "config"); err != nil {
-		return err
+	if req.Config == "" && req.ConfigFilepath == "" && len(req.ConfigParameters) == 0 {
+		return fmt.Errorf("at least one of config, config_filepath and config_parameters must be non-empty")
 	}
 
 	if err := checkNotEmpty(req.Target, "target"); err != nil {
@@ -107,7 +157,12 @@ func validateMountSingleRequest(req *pb.MountSingleRequest) error {
 	return nil
 }
 
-func populateMountSingleRequest(req *pb.MountSingleRequest) error {
+// populateMountSingleRequest sets MountSingleRequest.Config to the volume
+// client config, read from ConfigFilepath if set, and validates it together
+// with ConfigParameters. The volume client config is layered on top of
+// the default config of the repository once the mount is created, see
+// createMountSingleMetadata.
+func (s *singleMountServer) populateMountSingleRequest(req *pb.MountSingleRequest) error {
 	if req.ConfigFilepath != "" {
 		configContents, err := os.ReadFile(req.ConfigFilepath)
 		if err != nil {
@@ -115,6 +170,33 @@ func populateMountSingleRequest(req *pb.MountSingleRequest) error {
 		}
 
 		req.Config = string(configContents)
+		req.ConfigFilepath = ""
+	}
+
+	// Path parameters may always point into the mount's own directory.
+	validateOpts := s.configValidation
+	validateOpts.AllowedPaths = append([]string{fmtMountSingleBasePath(req.MountId)}, validateOpts.AllowedPaths...)
+
+	volumeParams, err := clientconfig.Parse(req.Config)
+	if err != nil {
+		if s.strictConfigParsing {
+			return fmt.Errorf("invalid client config: %v", err)
+		}
+
+		// Volume client config used to be passed to cvmfs2 as is. Keep
+		// accepting it for now, validating only the simple assignments.
+		log.Warningf("Client config of mount %s will be rejected by future versions, "+
+			"it must contain only CVMFS_<NAME>=<VALUE> assignments: %v", req.MountId, err)
+
+		if err = clientconfig.ValidateMap(clientconfig.Effective(req.Config), &validateOpts); err != nil {
+			return fmt.Errorf("invalid client config: %v", err)
+		}
+	} else if err = clientconfig.Validate(volumeParams, &validateOpts); err != nil {
+		return fmt.Errorf("invalid client config: %v", err)
+	}
+
+	if err = clientconfig.ValidateMap(req.ConfigParameters, &validateOpts); err != nil {
+		return fmt.Errorf("invalid client config parameters: %v", err)
 	}
 
 	return nil
@@ -128,6 +210,15 @@ func validateUnmountSingleRequest(req *pb.UnmountSingleRequest) error {
 	return nil
 }
 
+func validateGetMountRequest(req *pb.GetMountRequest) error {
+	if (req.MountId == "" && req.Mountpoint == "") ||
+		(req.MountId != "" && req.Mountpoint != "") {
+		return fmt.Errorf("exactly one of mount_id and mountpoint must be non-empty")
+	}
+
+	return nil
+}
+
 func (s *singleMountServer) Mount(
 	ctx context.Context,
 	req *pb.MountSingleRequest,
@@ -138,7 +229,7 @@ func (s *singleMountServer) Mount(
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	if err = populateMountSingleRequest(req); err != nil {
+	if err = s.populateMountSingleRequest(req); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
@@ -166,8 +257,8 @@ func (s *singleMountServer) Mount(
 		},
 	)
 
-	if err = makeSharedMount(req); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	if err = makeSharedMount(ctx, req); err != nil {
+		return nil, mountErrorToStatus(err)
 	}
 
 	return &pb.MountSingleResponse{}, nil
@@ -181,7 +272,7 @@ func (s *singleMountServer) Unmount(
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	if err := (bindMounterUnmounter{}).unmount(req.Mountpoint); err != nil {
+	if err := (bindMounterUnmounter{}).unmount(ctx, req.Mountpoint); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to unbind %s: %v", req.Mountpoint, err)
 	}
 
@@ -208,14 +299,8 @@ func (s *singleMountServer) Unmount(
 	if lastBindMount {
 		// We need to clean up the CVMFS mount and the singlemount directory.
 
-		if err := (cvmfsMounterUnmounter{}).unmount(fmtMountpointPath(mountID)); err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to unmount CVMFS volume: %v", err)
-		}
-
-		if err := os.RemoveAll(fmtMountSingleBasePath(mountID)); err != nil {
-			if !os.IsNotExist(err) {
-				return nil, status.Errorf(codes.Internal, "failed to remove volume directory: %v", err)
-			}
+		if err := removeSharedMount(ctx, mountID); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to remove CVMFS volume: %v", err)
 		}
 
 		if err := deleteMountpointMetadata(req.Mountpoint); err != nil {
@@ -226,3 +311,67 @@ func (s *singleMountServer) Unmount(
 
 	return &pb.UnmountSingleResponse{}, nil
 }
+
+func (s *singleMountServer) ListMounts(
+	ctx context.Context,
+	req *pb.ListMountsRequest,
+) (*pb.ListMountsResponse, error) {
+	mountIDs, err := listMountIDs()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list mounts: %v", err)
+	}
+
+	resp := &pb.ListMountsResponse{}
+
+	for _, mountID := range mountIDs {
+		sharedMount, err := getSharedMount(mountID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		if sharedMount == nil {
+			// The mount was deleted in the meantime.
+			continue
+		}
+
+		resp.Mounts = append(resp.Mounts, sharedMount)
+	}
+
+	return resp, nil
+}
+
+func (s *singleMountServer) GetMount(
+	ctx context.Context,
+	req *pb.GetMountRequest,
+) (*pb.GetMountResponse, error) {
+	if err := validateGetMountRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	mountID := req.MountId
+
+	if req.Mountpoint != "" {
+		var err error
+		mountID, err = getMountIDForMountpoint(req.Mountpoint)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to read target metadata: %v", err)
+		}
+
+		if mountID == "" {
+			return nil, status.Errorf(codes.NotFound, "mountpoint %s is not registered", req.Mountpoint)
+		}
+	}
+
+	sharedMount, err := getSharedMount(mountID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if sharedMount == nil {
+		return nil, status.Errorf(codes.NotFound, "mount ID %s not found", mountID)
+	}
+
+	return &pb.GetMountResponse{
+		Mount: sharedMount,
+	}, nil
+}
@@ -0,0 +1,197 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package singlemount
+
+import (
+	"context"
+	"maps"
+	"os"
+	goexec "os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	pb "github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount/pb/v1"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// useFakeCvmfs2 puts a cvmfs2 executable in PATH that mounts
+// a tmpfs instead of the repository. Mounts made by it are
+// unmounted when the test finishes.
+func useFakeCvmfs2(t *testing.T) {
+	t.Helper()
+
+	if os.Geteuid() != 0 {
+		t.Skip("test needs to mount filesystems, run it as root")
+	}
+
+	binDir := t.TempDir()
+
+	// cvmfs2 <repository> <mountpoint> -o config=<config file>
+	script := "#!/bin/sh\nexec mount -t tmpfs cvmfs-csi-test \"$2\"\n"
+	if err := os.WriteFile(path.Join(binDir, "cvmfs2"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", binDir+":"+os.Getenv("PATH"))
+}
+
+// useTempDefaultConfigDir points cvmfsDefaultConfigDir to a temporary
+// directory with files, given as paths relative to it, holding node
+// defaults of the client config.
+func useTempDefaultConfigDir(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	orig := cvmfsDefaultConfigDir
+	cvmfsDefaultConfigDir = t.TempDir()
+	t.Cleanup(func() { cvmfsDefaultConfigDir = orig })
+
+	for name, contents := range files {
+		p := path.Join(cvmfsDefaultConfigDir, name)
+
+		if err := os.MkdirAll(path.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func unmountOnCleanup(t *testing.T, mountpoints ...string) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		for _, mountpoint := range mountpoints {
+			goexec.Command("umount", "--lazy", mountpoint).Run()
+		}
+	})
+}
+
+func TestMountWritesEffectiveConfig(t *testing.T) {
+	useFakeCvmfs2(t)
+	useTempSinglemountsDir(t)
+	useTempDefaultConfigDir(t, map[string]string{
+		"default.local":         "CVMFS_HTTP_PROXY=http://proxy:3128\nCVMFS_QUOTA_LIMIT=500\n",
+		"domain.d/cern.ch.conf": "CVMFS_SERVER_URL=http://cvmfs-stratum-one.cern.ch/cvmfs/@fqrn@\n",
+	})
+
+	s := &singleMountServer{}
+	target := t.TempDir()
+	unmountOnCleanup(t, target, fmtMountpointPath("vol-1"))
+
+	_, err := s.Mount(context.Background(), &pb.MountSingleRequest{
+		MountId:          "vol-1",
+		Repository:       "sft.cern.ch",
+		Config:           "CVMFS_HTTP_PROXY=DIRECT\nCVMFS_QUOTA_LIMIT=1000\n",
+		ConfigParameters: map[string]string{"CVMFS_QUOTA_LIMIT": "2000", "CVMFS_REPOSITORY_TAG": "release-1"},
+		Target:           target,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st, err := mountutils.GetState(target); err != nil || st != mountutils.StMounted {
+		t.Fatalf("target is not mounted: %s, %v", st, err)
+	}
+
+	// Node defaults are overridden by the volume client config,
+	// and that by the config parameters.
+
+	want := map[string]string{
+		"CVMFS_HTTP_PROXY":     "DIRECT",
+		"CVMFS_QUOTA_LIMIT":    "2000",
+		"CVMFS_REPOSITORY_TAG": "release-1",
+		"CVMFS_SERVER_URL":     "http://cvmfs-stratum-one.cern.ch/cvmfs/@fqrn@",
+		"CVMFS_CACHE_BASE":     path.Join(fmtMountSingleBasePath("vol-1"), cacheDirname),
+		"CVMFS_SHARED_CACHE":   "no",
+		"CVMFS_RELOAD_SOCKETS": fmtMountSingleBasePath("vol-1"),
+		"CVMFS_TALK_SOCKET":    fmtTalkSocketPath("vol-1"),
+	}
+
+	baseDir := fmtMountSingleBasePath("vol-1")
+
+	got, err := fromJSONFile[map[string]string](path.Join(baseDir, effectiveConfigFilename), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(got, want) {
+		t.Errorf("got effective config %v, want %v", got, want)
+	}
+
+	// The effective config is stored next to the mount metadata,
+	// which keeps the volume client config for checking shared mounts.
+
+	meta, err := fromJSONFile(path.Join(baseDir, mountMetadataFilename), mountMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.VolumeConfig == nil || *meta.VolumeConfig != "CVMFS_HTTP_PROXY=DIRECT\nCVMFS_QUOTA_LIMIT=1000\n" {
+		t.Errorf("unexpected volume config in mount metadata: %v", meta.VolumeConfig)
+	}
+
+	config, err := os.ReadFile(fmtConfigPath("vol-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(config), "# Volume client config\nCVMFS_HTTP_PROXY=DIRECT\n") {
+		t.Errorf("volume client config is missing in the config file:\n%s", config)
+	}
+}
+
+func TestMountRejectsInvalidConfig(t *testing.T) {
+	useTempSinglemountsDir(t)
+	useTempDefaultConfigDir(t, nil)
+
+	s := &singleMountServer{}
+
+	tests := []struct {
+		name   string
+		config string
+		params map[string]string
+	}{
+		{name: "unknown parameter", config: "CVMFS_HTTP_PROXI=DIRECT"},
+		{name: "cache outside of allowed paths", config: "CVMFS_HTTP_PROXY=DIRECT\nCVMFS_CACHE_BASE=/etc"},
+		{name: "unknown config parameter", params: map[string]string{"CVMFS_REPOSITORY_TAGG": "release-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Mount(context.Background(), &pb.MountSingleRequest{
+				MountId:          "vol-1",
+				Repository:       "sft.cern.ch",
+				Config:           tt.config,
+				ConfigParameters: tt.params,
+				Target:           t.TempDir(),
+			})
+			if got := status.Code(err); got != codes.InvalidArgument {
+				t.Fatalf("got code %s (%v), want %s", got, err, codes.InvalidArgument)
+			}
+
+			if _, err = os.Stat(fmtMountSingleBasePath("vol-1")); !os.IsNotExist(err) {
+				t.Errorf("singlemount directory was created for invalid config: %v", err)
+			}
+		})
+	}
+}
@@ -18,31 +18,41 @@ package singlemount
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	goexec "os/exec"
 	"path"
+	"sort"
+	"strings"
 
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/clientconfig"
 	pb "github.com/cvmfs-contrib/cvmfs-csi/internal/cvmfs/singlemount/pb/v1"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/mountutils"
 )
 
-const (
-	// Path to directory where the metadata and mountpoints are stored.
-	// The structure is as follows:
-	//
-	//   <mountsDir>/
-	//     targets.json
-	//     <MountSingleRequest.MountId>/
-	//       mount/
-	//       bind.json
-	//       config
-	//       mount.json
-	SinglemountsDir = "/var/lib/cvmfs.csi.cern.ch/single"
+// Path to directory where the metadata and mountpoints are stored.
+// The structure is as follows:
+//
+//	<mountsDir>/
+//	  mountpoints.json
+//	  <MountSingleRequest.MountId>/
+//	    mount/
+//	    bind.json
+//	    config
+//	    config.json
+//	    cvmfs_io
+//	    mount.json
+//
+// It is a variable only so that tests can point it to a temporary directory.
+var SinglemountsDir = "/var/lib/cvmfs.csi.cern.ch/single"
 
+const (
 	// Contains mapping between all mountpoint -> mount ID that are currently
 	// in use. We need to keep track of these, because CSI's NodeUnstageVolume
 	// gives us only staging_target_path in the request, and we need a way to then
@@ -61,13 +71,29 @@ const (
 
 	// CVMFS client config extracted from MountSingleRequest.
 	cvmfsConfigFilename = "config"
+
+	// Effective CVMFS client config parameters, after merging node
+	// defaults with volume client config, as a JSON object.
+	effectiveConfigFilename = "config.json"
+
+	// cvmfs_talk socket of the cvmfs2 client.
+	talkSocketFilename = "cvmfs_io"
 )
 
 type (
 	mountMetadata struct {
-		MountID    string
+		MountID string
+
+		// Effective client config of the mount, i.e. node defaults
+		// layered with the volume client config.
 		Config     string
 		Repository string
+
+		// Volume client config and config parameters the mount was
+		// created with. Shared mounts are checked against these. Not set
+		// in metadata written by older versions of singlemount-runner.
+		VolumeConfig     *string           `json:",omitempty"`
+		ConfigParameters map[string]string `json:",omitempty"`
 	}
 
 	bindMetadata struct {
@@ -99,6 +125,10 @@ func fmtConfigPath(mountID string) string {
 	return path.Join(fmtMountSingleBasePath(mountID), cvmfsConfigFilename)
 }
 
+func fmtTalkSocketPath(mountID string) string {
+	return path.Join(fmtMountSingleBasePath(mountID), talkSocketFilename)
+}
+
 func fmtMountpointsMetadataPath() string {
 	return path.Join(SinglemountsDir, mountpointsFilename)
 }
@@ -112,12 +142,17 @@ func CreateSingleMountsDir() error {
 
 // Makes sure that directory <mountsDir>/<MountSingleRequest.MountId> exists.
 // If it doesn't, it is created and populated. If it already exists, it checks
-// that the supplied MountSingleRequest matches metadata.json.
+// that the supplied MountSingleRequest matches metadata.json, and adds
+// MountSingleRequest.Target into bind metadata.
 // Returns (true, nil) if this call created the singlemount metadata directory.
 func ensureMountSingleMetadata(req *pb.MountSingleRequest) (bool, error) {
 	if err := createMountSingleMetadata(req); err != nil {
 		if os.IsExist(err) {
-			return false, checkMountMetadataMatches(req)
+			if err = checkMountMetadataMatches(req); err != nil {
+				return false, err
+			}
+
+			return false, ensureBindMetadata(req)
 		}
 
 		return false, err
@@ -126,8 +161,8 @@ func ensureMountSingleMetadata(req *pb.MountSingleRequest) (bool, error) {
 	return true, nil
 }
 
-func writeConfigFile(mountID, config string) error {
-	f, err := os.OpenFile(fmtConfigPath(mountID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o444)
+func writeConfigFile(entryDir, mountID, config string) error {
+	f, err := os.OpenFile(path.Join(entryDir, cvmfsConfigFilename), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o444)
 	if err != nil {
 		return err
 	}
@@ -140,6 +175,11 @@ func writeConfigFile(mountID, config string) error {
 		return err
 	}
 
+	_, err = f.WriteString(fmt.Sprintf("CVMFS_TALK_SOCKET=%s\n", fmtTalkSocketPath(mountID)))
+	if err != nil {
+		return err
+	}
+
 	// Write the rest of the config.
 
 	_, err = f.WriteString(config)
@@ -147,26 +187,58 @@ func writeConfigFile(mountID, config string) error {
 		return err
 	}
 
-	return nil
+	return f.Sync()
 }
 
-func createMountSingleMetadata(req *pb.MountSingleRequest) error {
+// effectiveConfig returns parameters set in the config file written by
+// writeConfigFile. Parameters set by shell constructs in node defaults
+// (e.g. conditionals) are not included.
+func effectiveConfig(mountID, config string) map[string]string {
+	params := clientconfig.Effective(config)
+	params["CVMFS_RELOAD_SOCKETS"] = fmtMountSingleBasePath(mountID)
+	params["CVMFS_TALK_SOCKET"] = fmtTalkSocketPath(mountID)
+
+	return params
+}
+
+// Creates and populates the singlemount directory for MountSingleRequest.MountId.
+// The directory is first populated under a temporary name and then renamed,
+// so that it's never observed half-populated, even after a crash. Returns
+// an error satisfying os.IsExist() if the directory already exists.
+func createMountSingleMetadata(req *pb.MountSingleRequest) (err error) {
 	// Create the root singlemount directory.
 
-	entryDir := fmtMountSingleBasePath(req.MountId)
-	if err := os.Mkdir(entryDir, 0o775); err != nil {
+	entryDir := path.Join(SinglemountsDir, tmpDirPrefix+req.MountId)
+	if err = os.RemoveAll(entryDir); err != nil {
 		return err
 	}
 
+	if err = os.Mkdir(entryDir, 0o775); err != nil {
+		return err
+	}
+
+	// Clean up the temporary directory if anything below fails.
+	defer ifErr(&err, func() { os.RemoveAll(entryDir) })
+
+	// Layer the volume client config on top of node defaults.
+
+	defaultConfig, err := readDefaultRepositoryConfig(req.MountId, req.Repository)
+	if err != nil {
+		return err
+	}
+
+	config := appendVolumeConfig(defaultConfig, req.Config)
+	config = appendConfigParameters(config, req.ConfigParameters)
+
 	// Write mount metadata.
 
-	mountMeta := mountMetadataFromMountSingleRequest(req)
+	mountMeta := mountMetadataFromMountSingleRequest(req, config)
 	mountMetaJSON, err := json.Marshal(mountMeta)
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(path.Join(entryDir, mountMetadataFilename), mountMetaJSON, 0o444)
+	err = writeFileAtomic(path.Join(entryDir, mountMetadataFilename), mountMetaJSON, 0o444)
 	if err != nil {
 		return err
 	}
@@ -187,7 +259,15 @@ func createMountSingleMetadata(req *pb.MountSingleRequest) error {
 
 	// Write CVMFS config.
 
-	err = writeConfigFile(req.MountId, req.Config)
+	err = writeConfigFile(entryDir, req.MountId, config)
+	if err != nil {
+		return err
+	}
+
+	err = toJSONFile(
+		path.Join(entryDir, effectiveConfigFilename),
+		effectiveConfig(req.MountId, config),
+	)
 	if err != nil {
 		return err
 	}
@@ -198,9 +278,19 @@ func createMountSingleMetadata(req *pb.MountSingleRequest) error {
 		return err
 	}
 
-	return nil
+	// Move the populated directory into its place.
+
+	if err = os.Rename(entryDir, fmtMountSingleBasePath(req.MountId)); err != nil {
+		return err
+	}
+
+	return syncDir(SinglemountsDir)
 }
 
+// checkMountMetadataMatches returns an error if the mount with the same ID
+// was created with a different repository or volume client config. Node
+// defaults are not compared, so that changes to them don't break existing
+// shared mounts. req must be populated with populateMountSingleRequest.
 func checkMountMetadataMatches(req *pb.MountSingleRequest) error {
 	if _, err := os.Stat(fmtMountSingleBasePath(req.MountId)); err != nil {
 		if os.IsNotExist(err) {
@@ -217,16 +307,13 @@ func checkMountMetadataMatches(req *pb.MountSingleRequest) error {
 		return err
 	}
 
-	reqMountMeta := mountMetadataFromMountSingleRequest(req)
-
 	checks := []struct {
 		name     string
 		expected string
 		actual   string
 	}{
-		{"mountID", storedMountMeta.MountID, reqMountMeta.MountID},
-		{"config", storedMountMeta.Config, reqMountMeta.Config},
-		{"repository", storedMountMeta.Repository, reqMountMeta.Repository},
+		{"mountID", storedMountMeta.MountID, req.MountId},
+		{"repository", storedMountMeta.Repository, req.Repository},
 	}
 
 	for _, c := range checks {
@@ -235,22 +322,40 @@ func checkMountMetadataMatches(req *pb.MountSingleRequest) error {
 		}
 	}
 
+	if storedMountMeta.VolumeConfig == nil {
+		// Older versions stored only the effective config, which can't be
+		// compared with the request. Assume the mount is compatible.
+		log.Infof("Mount metadata of %s has no volume client config, skipping config check", req.MountId)
+		return nil
+	}
+
+	if *storedMountMeta.VolumeConfig != req.Config {
+		return fmt.Errorf("config mismatch: expected %q, got %q", *storedMountMeta.VolumeConfig, req.Config)
+	}
+
+	if !maps.Equal(storedMountMeta.ConfigParameters, req.ConfigParameters) {
+		return fmt.Errorf("config parameters mismatch: expected %v, got %v",
+			storedMountMeta.ConfigParameters, req.ConfigParameters)
+	}
+
 	return nil
 }
 
-func mountMetadataFromMountSingleRequest(req *pb.MountSingleRequest) mountMetadata {
+func mountMetadataFromMountSingleRequest(req *pb.MountSingleRequest, config string) mountMetadata {
 	return mountMetadata{
-		MountID:    req.MountId,
-		Config:     req.Config,
-		Repository: req.Repository,
+		MountID:          req.MountId,
+		Config:           config,
+		Repository:       req.Repository,
+		VolumeConfig:     &req.Config,
+		ConfigParameters: req.ConfigParameters,
 	}
 }
 
 // Adds MountSingleRequest.Target into bind metadata if it's missing.
 func ensureBindMetadata(req *pb.MountSingleRequest) error {
-	bindMetaFilepath := path.Join(fmtMountSingleBasePath(req.MountId), bindMetadataFilename)
+	bindMetaFilepath := fmtBindMetadataPath(req.MountId)
 
-	bindMeta, err := fromJSONFile(bindMetaFilepath, bindMetadata{})
+	bindMeta, err := readBindMetadata(req.MountId)
 	if err != nil {
 		return err
 	}
@@ -273,9 +378,9 @@ func ensureBindMetadata(req *pb.MountSingleRequest) error {
 // This then means the CVMFS mount itself can be unmounted, and the singlemount dir
 // can be removed.
 func deleteBindMetadata(req *pb.UnmountSingleRequest, mountID string) (bool, error) {
-	bindMetaFilepath := path.Join(fmtMountSingleBasePath(mountID), bindMetadataFilename)
+	bindMetaFilepath := fmtBindMetadataPath(mountID)
 
-	bindMeta, err := fromJSONFile(bindMetaFilepath, bindMetadata{})
+	bindMeta, err := readBindMetadata(mountID)
 	if err != nil {
 		return false, err
 	}
@@ -293,16 +398,26 @@ func deleteBindMetadata(req *pb.UnmountSingleRequest, mountID string) (bool, err
 	return len(bindMeta.Targets) == 0, nil
 }
 
-func makeSharedMount(req *pb.MountSingleRequest) error {
-	_, err := ensureMountSingleMetadata(req)
+func makeSharedMount(ctx context.Context, req *pb.MountSingleRequest) (err error) {
+	created, err := ensureMountSingleMetadata(req)
 	if err != nil {
-		return nil
+		return err
 	}
 
-	// Clean up after ensureMountSingleMetadata().
+	// Clean up after ensureMountSingleMetadata(). The singlemount directory
+	// is removed only if it was created by this call, as otherwise it's still
+	// in use by other targets.
 	defer ifErr(
 		&err,
 		func() {
+			if !created {
+				_, err2 := deleteBindMetadata(&pb.UnmountSingleRequest{Mountpoint: req.Target}, req.MountId)
+				if err2 != nil {
+					log.Errorf("failed to clean up bind metadata for %s: %v", req.Target, err2)
+				}
+				return
+			}
+
 			if err2 := os.RemoveAll(fmtMountSingleBasePath(req.MountId)); err2 != nil {
 				log.Errorf("failed to clean up singlemount directory %s: %v",
 					fmtMountSingleBasePath(req.MountId), err)
@@ -310,7 +425,7 @@ func makeSharedMount(req *pb.MountSingleRequest) error {
 		},
 	)
 
-	err = tryMountOrRecover(
+	err = tryMountOrRecover(ctx,
 		&cvmfsMounterUnmounter{
 			repository: req.Repository,
 			configPath: fmtConfigPath(req.MountId),
@@ -321,11 +436,11 @@ func makeSharedMount(req *pb.MountSingleRequest) error {
 		return err
 	}
 
-	// Clean up after tryMountOrRecover(cvmfsMounterUnmounter).
+	// Clean up after tryMountOrRecover(ctx, cvmfsMounterUnmounter).
 	defer ifErr(
 		&err,
 		func() {
-			err2 := cvmfsMounterUnmounter{}.unmount(fmtMountpointPath(req.MountId))
+			err2 := cvmfsMounterUnmounter{}.unmount(ctx, fmtMountpointPath(req.MountId))
 			if err2 != nil {
 				log.Errorf("failed to clean up cvmfs2 mount %s: %v",
 					fmtMountpointPath(req.MountId), err)
@@ -333,7 +448,7 @@ func makeSharedMount(req *pb.MountSingleRequest) error {
 		},
 	)
 
-	err = tryMountOrRecover(
+	err = tryMountOrRecover(ctx,
 		&bindMounterUnmounter{
 			cvmfsMountpoint: fmtMountpointPath(req.MountId),
 		},
@@ -343,11 +458,11 @@ func makeSharedMount(req *pb.MountSingleRequest) error {
 		return err
 	}
 
-	// Clean up after tryMountOrRecover(bindMounterUnmounter).
+	// Clean up after tryMountOrRecover(ctx, bindMounterUnmounter).
 	defer ifErr(
 		&err,
 		func() {
-			err2 := bindMounterUnmounter{}.unmount(req.Target)
+			err2 := bindMounterUnmounter{}.unmount(ctx, req.Target)
 			if err2 != nil {
 				log.Errorf("failed to clean up bind mount %s: %v",
 					req.Target, err)
@@ -358,7 +473,7 @@ func makeSharedMount(req *pb.MountSingleRequest) error {
 	return nil
 }
 
-func tryMountOrRecover(mu mounterUnmounter, mountpointPath string) error {
+func tryMountOrRecover(ctx context.Context, mu mounterUnmounter, mountpointPath string) error {
 	mntState, err := mountutils.GetState(mountpointPath)
 	if err != nil {
 		return err
@@ -368,12 +483,12 @@ func tryMountOrRecover(mu mounterUnmounter, mountpointPath string) error {
 	case mountutils.StMounted:
 		return nil
 	case mountutils.StCorrupted:
-		if err = mu.unmount(mountpointPath); err != nil {
+		if err = mu.unmount(ctx, mountpointPath); err != nil {
 			return err
 		}
 		fallthrough
 	case mountutils.StNotMounted:
-		return mu.mount(mountpointPath)
+		return mu.mount(ctx, mountpointPath)
 	default:
 		return fmt.Errorf("mountpoint %s is in unexpected state", mountpointPath)
 	}
@@ -381,8 +496,8 @@ func tryMountOrRecover(mu mounterUnmounter, mountpointPath string) error {
 
 type (
 	mounterUnmounter interface {
-		mount(mountpoint string) error
-		unmount(mounpoint string) error
+		mount(ctx context.Context, mountpoint string) error
+		unmount(ctx context.Context, mountpoint string) error
 	}
 
 	cvmfsMounterUnmounter struct {
@@ -397,9 +512,8 @@ type (
 	}
 )
 
-func (mu cvmfsMounterUnmounter) mount(mountpoint string) error {
+func (mu cvmfsMounterUnmounter) mount(ctx context.Context, mountpoint string) error {
 	cvmfsArgs := []string{
-		mu.repository,
 		mountpoint,
 		"-o", fmt.Sprintf("config=%s", mu.configPath),
 	}
@@ -408,11 +522,23 @@ func (mu cvmfsMounterUnmounter) mount(mountpoint string) error {
 		cvmfsArgs = append(cvmfsArgs, "-d")
 	}
 
-	return runCvmfs2AndTryCaptureErr(cvmfsArgs...)
+	err := runCvmfs2AndTryCaptureErr(ctx, mu.repository, cvmfsArgs...)
+
+	// Mounting is idempotent: if the repository is already mounted
+	// in mountpoint, e.g. by a concurrent or retried request, we're done.
+	var mntErr *mountError
+	if errors.As(err, &mntErr) && mntErr.Class == mountErrAlreadyMounted {
+		if mntState, stErr := mountutils.GetState(mountpoint); stErr == nil && mntState == mountutils.StMounted {
+			log.InfoS("Repository is already mounted", log.KeyRepository, mu.repository, "mountpoint", mountpoint)
+			return nil
+		}
+	}
+
+	return err
 }
 
-func (mu cvmfsMounterUnmounter) unmount(mountpoint string) error {
-	out, err := exec.CombinedOutput(goexec.Command("fusermount", "-u", mountpoint))
+func (mu cvmfsMounterUnmounter) unmount(ctx context.Context, mountpoint string) error {
+	out, err := exec.CombinedOutputContext(ctx, goexec.Command("fusermount", "-u", mountpoint))
 	if err != nil {
 		// Ignore these errors for idempotency:
 		// * Not a mountpoint: ": Invalid argument"
@@ -428,8 +554,8 @@ func (mu cvmfsMounterUnmounter) unmount(mountpoint string) error {
 	return err
 }
 
-func (mu bindMounterUnmounter) mount(mountpoint string) error {
-	out, err := exec.CombinedOutput(goexec.Command(
+func (mu bindMounterUnmounter) mount(ctx context.Context, mountpoint string) error {
+	out, err := exec.CombinedOutputContext(ctx, goexec.Command(
 		"mount",
 		"--bind",
 		mu.cvmfsMountpoint,
@@ -442,8 +568,8 @@ func (mu bindMounterUnmounter) mount(mountpoint string) error {
 	return err
 }
 
-func (mu bindMounterUnmounter) unmount(mountpoint string) error {
-	out, err := exec.CombinedOutput(goexec.Command("umount", mountpoint))
+func (mu bindMounterUnmounter) unmount(ctx context.Context, mountpoint string) error {
+	out, err := exec.CombinedOutputContext(ctx, goexec.Command("umount", mountpoint))
 	if err != nil {
 		// Ignore these errors for idempotency:
 		// * Not a mountpoint: ": not mounted"
@@ -459,64 +585,89 @@ func (mu bindMounterUnmounter) unmount(mountpoint string) error {
 	return err
 }
 
-func addMountpointMetadata(mountpoint, mountID string) error {
-	mountpointsMeta, err := fromJSONFile(fmtMountpointsMetadataPath(), mountpointsMetadata{
-		Mountpoints: make(map[string]string),
-	})
+// Returns mount IDs of all shared mounts stored in SinglemountsDir.
+func listMountIDs() ([]string, error) {
+	entries, err := os.ReadDir(SinglemountsDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if storedMountID, ok := mountpointsMeta.Mountpoints[mountpoint]; ok {
-		if storedMountID == mountID {
-			// Mountpoint already registered with this mount ID.
-			return nil
+	var mountIDs []string
+	for i := range entries {
+		if entries[i].IsDir() && !strings.HasPrefix(entries[i].Name(), tmpDirPrefix) {
+			mountIDs = append(mountIDs, entries[i].Name())
 		}
-
-		return fmt.Errorf(
-			"failed to register mountpoint %s with mount ID %s: mountpoint already exists with mount ID %s",
-			mountpoint, mountID, storedMountID,
-		)
 	}
 
-	mountpointsMeta.Mountpoints[mountpoint] = mountID
+	return mountIDs, nil
+}
 
-	if err = toJSONFile(fmtMountpointsMetadataPath(), &mountpointsMeta); err != nil {
-		return err
+// Builds shared mount description from metadata stored in the singlemount
+// directory of mountID, and probes the live state of its mountpoints.
+// Returns (nil, nil) if no such shared mount exists.
+func getSharedMount(mountID string) (*pb.SharedMount, error) {
+	if _, err := os.Stat(fmtMountSingleBasePath(mountID)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to stat mount metadata directory: %v", err)
 	}
 
-	return nil
-}
+	mountMeta, err := fromJSONFile(fmtMountMetadataPath(mountID), mountMetadata{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mount metadata for %s: %v", mountID, err)
+	}
 
-func deleteMountpointMetadata(mountpoint string) error {
-	mountpointsMeta, err := fromJSONFile(fmtMountpointsMetadataPath(), mountpointsMetadata{
-		Mountpoints: make(map[string]string),
-	})
+	bindMeta, err := readBindMetadata(mountID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to read bind metadata for %s: %v", mountID, err)
 	}
 
-	if _, ok := mountpointsMeta.Mountpoints[mountpoint]; !ok {
-		// Mountpoint not found in map, assume it was already deleted.
-		return nil
+	targets := make([]string, 0, len(bindMeta.Targets))
+	for target := range bindMeta.Targets {
+		targets = append(targets, target)
 	}
+	sort.Strings(targets)
 
-	delete(mountpointsMeta.Mountpoints, mountpoint)
+	sharedMount := &pb.SharedMount{
+		MountId:    mountID,
+		Repository: mountMeta.Repository,
+		Config:     mountMeta.Config,
+		Mountpoint: fmtMountpointPath(mountID),
+		State:      getPbMountState(fmtMountpointPath(mountID)),
+		Targets:    make([]*pb.BindTarget, len(targets)),
+	}
 
-	if err = toJSONFile(fmtMountpointsMetadataPath(), &mountpointsMeta); err != nil {
-		return err
+	for i, target := range targets {
+		sharedMount.Targets[i] = &pb.BindTarget{
+			Path:  target,
+			State: getPbMountState(target),
+		}
 	}
 
-	return nil
+	return sharedMount, nil
 }
 
-func getMountIDForMountpoint(mountpoint string) (string, error) {
-	mountpointsMeta, err := fromJSONFile(fmtMountpointsMetadataPath(), mountpointsMetadata{
-		Mountpoints: make(map[string]string),
-	})
+func getPbMountState(mountpoint string) pb.MountState {
+	mntState, err := mountutils.GetState(mountpoint)
 	if err != nil {
-		return "", err
+		if os.IsNotExist(err) {
+			return pb.MountState_MOUNT_STATE_NOT_MOUNTED
+		}
+
+		log.Errorf("failed to probe mountpoint %s: %v", mountpoint, err)
+		return pb.MountState_MOUNT_STATE_UNKNOWN
 	}
 
-	return mountpointsMeta.Mountpoints[mountpoint], nil
+	switch mntState {
+	case mountutils.StNotMounted:
+		return pb.MountState_MOUNT_STATE_NOT_MOUNTED
+	case mountutils.StMounted:
+		return pb.MountState_MOUNT_STATE_MOUNTED
+	case mountutils.StCorrupted:
+		return pb.MountState_MOUNT_STATE_CORRUPTED
+	default:
+		return pb.MountState_MOUNT_STATE_UNKNOWN
+	}
 }
@@ -18,6 +18,7 @@ package singlemount
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 )
 
@@ -39,7 +40,7 @@ func fromJSONFile[V any](filepath string, defaultValue V) (V, error) {
 
 	var val V
 	if err = json.Unmarshal(jsonData, &val); err != nil {
-		return defaultValue, err
+		return defaultValue, fmt.Errorf("%w %s: %v", errCorruptedMetadata, filepath, err)
 	}
 
 	return val, err
@@ -51,5 +52,5 @@ func toJSONFile(filepath string, val any) error {
 		return err
 	}
 
-	return os.WriteFile(filepath, jsonData, 0o644)
+	return writeFileAtomic(filepath, jsonData, 0o644)
 }
@@ -0,0 +1,114 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package snapshot parses volume parameters that pin a CVMFS volume
+// to a specific snapshot of its repository, and turns them into CVMFS
+// client config parameters.
+package snapshot
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	// TagKey names a tagged snapshot of the repository.
+	TagKey = "tag"
+
+	// HashKey is the content hash of the repository's root catalog.
+	HashKey = "hash"
+
+	// RevisionKey is a point in time (RFC 3339 timestamp). The newest
+	// snapshot published before this time is mounted.
+	RevisionKey = "revision"
+)
+
+var (
+	// Named snapshots created with cvmfs_server tag.
+	tagRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+	// Hex-encoded content hash, optionally with hash algorithm suffix.
+	hashRegexp = regexp.MustCompile(`^[0-9a-f]{40}(-rmd160|-shake128)?$`)
+)
+
+// Snapshot describes which snapshot of a repository to mount.
+// Exactly one of its fields is set.
+type Snapshot struct {
+	Tag      string
+	Hash     string
+	Revision time.Time
+}
+
+// FromVolumeParameters parses and validates snapshot parameters in params.
+// Returns (nil, nil) if params contains none of them.
+func FromVolumeParameters(params map[string]string) (*Snapshot, error) {
+	var (
+		s   Snapshot
+		set []string
+	)
+
+	if tag, ok := params[TagKey]; ok {
+		if !tagRegexp.MatchString(tag) {
+			return nil, fmt.Errorf("invalid value for volume parameter %s: %q is not a valid tag name", TagKey, tag)
+		}
+
+		s.Tag = tag
+		set = append(set, TagKey)
+	}
+
+	if hash, ok := params[HashKey]; ok {
+		if !hashRegexp.MatchString(hash) {
+			return nil, fmt.Errorf("invalid value for volume parameter %s: %q is not a valid root catalog hash", HashKey, hash)
+		}
+
+		s.Hash = hash
+		set = append(set, HashKey)
+	}
+
+	if revision, ok := params[RevisionKey]; ok {
+		t, err := time.Parse(time.RFC3339, revision)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for volume parameter %s: expected RFC 3339 timestamp (e.g. 2022-03-01T00:00:00Z): %v", RevisionKey, err)
+		}
+
+		s.Revision = t.UTC()
+		set = append(set, RevisionKey)
+	}
+
+	switch len(set) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("only one of volume parameters %s, %s and %s may be defined, got %v",
+			TagKey, HashKey, RevisionKey, set)
+	}
+}
+
+// ClientConfigParameters returns CVMFS client config parameters
+// that make cvmfs2 mount the snapshot.
+func (s *Snapshot) ClientConfigParameters() map[string]string {
+	switch {
+	case s.Tag != "":
+		return map[string]string{"CVMFS_REPOSITORY_TAG": s.Tag}
+	case s.Hash != "":
+		return map[string]string{"CVMFS_ROOT_HASH": s.Hash}
+	default:
+		return map[string]string{"CVMFS_REPOSITORY_DATE": s.Revision.Format("2006-01-02T15:04:05Z")}
+	}
+}
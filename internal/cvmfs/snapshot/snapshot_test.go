@@ -0,0 +1,107 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package snapshot
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+const testHash = "0123456789abcdef0123456789abcdef01234567"
+
+func TestFromVolumeParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    *Snapshot
+		wantErr bool
+	}{
+		{name: "not set", params: map[string]string{"repository": "atlas.cern.ch"}, want: nil},
+		{name: "tag", params: map[string]string{TagKey: "v1.2-rc_3"}, want: &Snapshot{Tag: "v1.2-rc_3"}},
+		{name: "hash", params: map[string]string{HashKey: testHash}, want: &Snapshot{Hash: testHash}},
+		{name: "hash with suffix", params: map[string]string{HashKey: testHash + "-rmd160"}, want: &Snapshot{Hash: testHash + "-rmd160"}},
+		{
+			name:   "revision",
+			params: map[string]string{RevisionKey: "2022-03-01T00:00:00Z"},
+			want:   &Snapshot{Revision: time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "revision with offset",
+			params: map[string]string{RevisionKey: "2022-03-01T02:00:00+02:00"},
+			want:   &Snapshot{Revision: time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{name: "empty tag", params: map[string]string{TagKey: ""}, wantErr: true},
+		{name: "tag with slash", params: map[string]string{TagKey: "a/b"}, wantErr: true},
+		{name: "tag with leading dot", params: map[string]string{TagKey: ".a"}, wantErr: true},
+		{name: "short hash", params: map[string]string{HashKey: "0123abcd"}, wantErr: true},
+		{name: "uppercase hash", params: map[string]string{HashKey: "0123456789ABCDEF0123456789ABCDEF01234567"}, wantErr: true},
+		{name: "unknown hash suffix", params: map[string]string{HashKey: testHash + "-md5"}, wantErr: true},
+		{name: "date only revision", params: map[string]string{RevisionKey: "2022-03-01"}, wantErr: true},
+		{name: "tag and hash", params: map[string]string{TagKey: "v1", HashKey: testHash}, wantErr: true},
+		{
+			name:    "tag and revision",
+			params:  map[string]string{TagKey: "v1", RevisionKey: "2022-03-01T00:00:00Z"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromVolumeParameters(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if got != nil && (got.Tag != tt.want.Tag || got.Hash != tt.want.Hash || !got.Revision.Equal(tt.want.Revision)) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientConfigParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot Snapshot
+		want     map[string]string
+	}{
+		{name: "tag", snapshot: Snapshot{Tag: "v1"}, want: map[string]string{"CVMFS_REPOSITORY_TAG": "v1"}},
+		{name: "hash", snapshot: Snapshot{Hash: testHash}, want: map[string]string{"CVMFS_ROOT_HASH": testHash}},
+		{
+			name:     "revision",
+			snapshot: Snapshot{Revision: time.Date(2022, 3, 1, 12, 30, 0, 0, time.UTC)},
+			want:     map[string]string{"CVMFS_REPOSITORY_DATE": "2022-03-01T12:30:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.snapshot.ClientConfigParameters(); !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
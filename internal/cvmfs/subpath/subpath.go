@@ -0,0 +1,59 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package subpath parses the volume parameter selecting
+// a subdirectory of a CVMFS repository to be published.
+package subpath
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Key of the volume parameter with path inside the repository.
+const Key = "subPath"
+
+// FromVolumeParameters parses and validates the subpath in params.
+// The subpath must be relative to the repository root, and must not
+// escape it. Returns an empty string if the parameter is not set.
+func FromVolumeParameters(params map[string]string) (string, error) {
+	value, ok := params[Key]
+	if !ok {
+		return "", nil
+	}
+
+	if strings.ContainsRune(value, 0) {
+		return "", fmt.Errorf("invalid value for volume parameter %s: must not contain NUL characters", Key)
+	}
+
+	if path.IsAbs(value) {
+		return "", fmt.Errorf("invalid value for volume parameter %s: %q must be a path relative to the repository root", Key, value)
+	}
+
+	for _, elem := range strings.Split(value, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("invalid value for volume parameter %s: %q must not contain '..'", Key, value)
+		}
+	}
+
+	cleaned := path.Clean(value)
+	if cleaned == "." {
+		return "", fmt.Errorf("invalid value for volume parameter %s: must not be empty", Key)
+	}
+
+	return cleaned, nil
+}
@@ -0,0 +1,65 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package subpath
+
+import (
+	"testing"
+)
+
+func TestFromVolumeParameters(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "not set", params: map[string]string{}, want: ""},
+		{name: "nil params", params: nil, want: ""},
+		{name: "single element", params: map[string]string{Key: "a"}, want: "a"},
+		{name: "nested", params: map[string]string{Key: "a/b/c"}, want: "a/b/c"},
+		{name: "trailing slash", params: map[string]string{Key: "a/b/"}, want: "a/b"},
+		{name: "leading dot", params: map[string]string{Key: "./a"}, want: "a"},
+		{name: "repeated slashes", params: map[string]string{Key: "a//b"}, want: "a/b"},
+		{name: "dotdot in name", params: map[string]string{Key: "a/..b"}, want: "a/..b"},
+		{name: "empty", params: map[string]string{Key: ""}, wantErr: true},
+		{name: "dot", params: map[string]string{Key: "."}, wantErr: true},
+		{name: "absolute", params: map[string]string{Key: "/a"}, wantErr: true},
+		{name: "root", params: map[string]string{Key: "/"}, wantErr: true},
+		{name: "escapes root", params: map[string]string{Key: "../a"}, wantErr: true},
+		{name: "dotdot inside", params: map[string]string{Key: "a/../b"}, wantErr: true},
+		{name: "trailing dotdot", params: map[string]string{Key: "a/.."}, wantErr: true},
+		{name: "NUL", params: map[string]string{Key: "a\x00b"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromVolumeParameters(tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
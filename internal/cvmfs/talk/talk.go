@@ -0,0 +1,60 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package talk wraps cvmfs_talk, used for querying running CVMFS clients.
+package talk
+
+import (
+	"bytes"
+	goexec "os/exec"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/exec"
+)
+
+// Repository runs cvmfs_talk command against the CVMFS client serving
+// repository repo, using the default talk socket location.
+func Repository(repo, command string) ([]byte, error) {
+	return exec.CombinedOutput(
+		goexec.Command(
+			"cvmfs_talk",
+			"-i", repo,
+			command,
+		),
+	)
+}
+
+// Socket runs cvmfs_talk command against the CVMFS client listening
+// on talk socket socketPath.
+func Socket(socketPath, command string) ([]byte, error) {
+	return exec.CombinedOutput(
+		goexec.Command(
+			"cvmfs_talk",
+			"-p", socketPath,
+			command,
+		),
+	)
+}
+
+// ClientNotRunning checks cvmfs_talk output for errors indicating that the
+// CVMFS client exited unexpectedly, and the watchdog didn't remount it
+// automatically.
+func ClientNotRunning(out []byte) bool {
+	const cvmfsErrConnRefused = "(111 - Connection refused)\x0A"
+	const cvmfsErrClientNotRunning = "Seems like CernVM-FS is not running"
+
+	return bytes.HasSuffix(out, []byte(cvmfsErrConnRefused)) ||
+		bytes.HasPrefix(out, []byte(cvmfsErrClientNotRunning))
+}
@@ -18,74 +18,165 @@ package exec
 
 import (
 	"bufio"
-	"fmt"
+	"context"
 	"io"
 	"os/exec"
+	"path"
 	"sync/atomic"
+	"time"
 
 	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/metrics"
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // This file only provides wrappers around "os/exec" and logs the executed commands.
+// Functions with the Context suffix also record the command as a span of the trace
+// in ctx.
 
 // Counter value used for pairing pre- and post-exec log messages.
 var execCounter uint64
 
-func FmtLogMsg(execID uint64, msg string) string {
-	return fmt.Sprintf("Exec-ID %d: %s", execID, msg)
+// Depth of the exported functions' callers, as seen from logStart and logExit.
+const callerDepth = 4
+
+func logStart(execID uint64, cmd *exec.Cmd) {
+	log.InfoSDepth(callerDepth, "Running command", log.KeyExecID, execID, "env", cmd.Env, "prog", cmd.Path, "args", cmd.Args)
+}
+
+func logExit(execID uint64, cmd *exec.Cmd) {
+	log.InfoSDepth(callerDepth, "Process exited", log.KeyExecID, execID, "state", cmd.ProcessState.String())
+}
+
+// LogOutputLine logs a line of output of the command with execID.
+func LogOutputLine(execID uint64, line string) {
+	log.InfoSDepth(1, line, log.KeyExecID, execID)
+}
+
+func startSpan(ctx context.Context, execID uint64, cmd *exec.Cmd) trace.Span {
+	command := cmd.Path
+	if len(cmd.Args) > 0 {
+		command = cmd.Args[0]
+	}
+
+	_, span := tracing.Start(ctx, "exec "+path.Base(command),
+		attribute.Int64(log.KeyExecID, int64(execID)),
+		attribute.StringSlice("args", cmd.Args),
+	)
+
+	return span
+}
+
+func endSpan(span trace.Span, cmd *exec.Cmd, err error) {
+	if cmd.ProcessState != nil {
+		span.SetAttributes(attribute.Int("exitCode", cmd.ProcessState.ExitCode()))
+	}
+
+	tracing.End(span, err)
 }
 
 func Run(cmd *exec.Cmd) error {
+	return run(context.Background(), cmd)
+}
+
+func RunContext(ctx context.Context, cmd *exec.Cmd) error {
+	return run(ctx, cmd)
+}
+
+func run(ctx context.Context, cmd *exec.Cmd) (err error) {
 	c := atomic.AddUint64(&execCounter, 1)
-	log.InfofDepth(2, FmtLogMsg(c, "Running command env=%v prog=%s cmd=%v"), cmd.Env, cmd.Path, cmd.Args)
+	logStart(c, cmd)
 
-	err := cmd.Run()
-	log.InfofDepth(2, FmtLogMsg(c, "Process exited: %s"), cmd.ProcessState)
+	span := startSpan(ctx, c, cmd)
+	defer func() { endSpan(span, cmd, err) }()
+
+	start := time.Now()
+	err = cmd.Run()
+	metrics.ObserveExec(cmd, time.Since(start), err)
+	logExit(c, cmd)
 
 	if err != nil {
-		log.ErrorfDepth(2, FmtLogMsg(c, "Error: %v"), err)
+		log.ErrorSDepth(callerDepth-1, err, "Command failed", log.KeyExecID, c)
 	}
 
 	return err
 }
 
 func Output(cmd *exec.Cmd) ([]byte, error) {
+	return output(context.Background(), cmd)
+}
+
+func OutputContext(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	return output(ctx, cmd)
+}
+
+func output(ctx context.Context, cmd *exec.Cmd) (out []byte, err error) {
 	c := atomic.AddUint64(&execCounter, 1)
-	log.InfofDepth(2, FmtLogMsg(c, "Running command env=%v prog=%s args=%v"), cmd.Env, cmd.Path, cmd.Args)
+	logStart(c, cmd)
 
-	out, err := cmd.Output()
-	log.InfofDepth(2, FmtLogMsg(c, "Process exited: %s"), cmd.ProcessState)
+	span := startSpan(ctx, c, cmd)
+	defer func() { endSpan(span, cmd, err) }()
+
+	start := time.Now()
+	out, err = cmd.Output()
+	metrics.ObserveExec(cmd, time.Since(start), err)
+	logExit(c, cmd)
 
 	if err != nil {
-		log.ErrorfDepth(2, FmtLogMsg(c, "Error: %v"), err)
+		log.ErrorSDepth(callerDepth-1, err, "Command failed", log.KeyExecID, c)
 	}
 
 	return out, err
 }
 
 func CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return combinedOutput(context.Background(), cmd)
+}
+
+func CombinedOutputContext(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	return combinedOutput(ctx, cmd)
+}
+
+func combinedOutput(ctx context.Context, cmd *exec.Cmd) (out []byte, err error) {
 	c := atomic.AddUint64(&execCounter, 1)
-	log.InfofDepth(2, FmtLogMsg(c, "Running command env=%v prog=%s args=%v"), cmd.Env, cmd.Path, cmd.Args)
+	logStart(c, cmd)
+
+	span := startSpan(ctx, c, cmd)
+	defer func() { endSpan(span, cmd, err) }()
 
-	out, err := cmd.CombinedOutput()
-	log.InfofDepth(2, FmtLogMsg(c, "Process exited: %s"), cmd.ProcessState)
+	start := time.Now()
+	out, err = cmd.CombinedOutput()
+	metrics.ObserveExec(cmd, time.Since(start), err)
+	logExit(c, cmd)
 
 	if err != nil {
-		log.ErrorfDepth(2, FmtLogMsg(c, "Error: %v; Output: %s"), err, out)
+		log.ErrorSDepth(callerDepth-1, err, "Command failed", log.KeyExecID, c, "output", string(out))
 	}
 
 	return out, err
 }
 
 func RunAndLogCombined(cmd *exec.Cmd) error {
-	return RunAndDoCombined(cmd, func(execID uint64, line string) {
-		log.Infof("%s", FmtLogMsg(execID, line))
-	})
+	return runAndDoCombined(context.Background(), cmd, LogOutputLine)
 }
 
 func RunAndDoCombined(cmd *exec.Cmd, eachCombinedOutLine func(execID uint64, line string)) error {
+	return runAndDoCombined(context.Background(), cmd, eachCombinedOutLine)
+}
+
+func RunAndDoCombinedContext(ctx context.Context, cmd *exec.Cmd, eachCombinedOutLine func(execID uint64, line string)) error {
+	return runAndDoCombined(ctx, cmd, eachCombinedOutLine)
+}
+
+func runAndDoCombined(ctx context.Context, cmd *exec.Cmd, eachCombinedOutLine func(execID uint64, line string)) (err error) {
 	c := atomic.AddUint64(&execCounter, 1)
-	log.InfofDepth(2, FmtLogMsg(c, "Running command env=%v prog=%s args=%v"), cmd.Env, cmd.Path, cmd.Args)
+	logStart(c, cmd)
+
+	span := startSpan(ctx, c, cmd)
+	defer func() { endSpan(span, cmd, err) }()
 
 	rd, wr := io.Pipe()
 	defer rd.Close()
@@ -101,5 +192,9 @@ func RunAndDoCombined(cmd *exec.Cmd, eachCombinedOutLine func(execID uint64, lin
 		}
 	}()
 
-	return cmd.Run()
+	start := time.Now()
+	err = cmd.Run()
+	metrics.ObserveExec(cmd, time.Since(start), err)
+
+	return err
 }
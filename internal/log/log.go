@@ -19,6 +19,7 @@ package log
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"k8s.io/klog/v2"
 )
@@ -33,13 +34,18 @@ const (
 	LevelTrace = 5
 )
 
-func tryPrependReqID(ctx context.Context, logMsg string) string {
-	reqID := ctx.Value(ReqIDContextKey)
-	if reqID != nil {
-		return fmt.Sprintf("Req-ID: %v %s", reqID, logMsg)
+// fmtWithContextValues formats the message and appends structured fields
+// stored in ctx. Used for severities that have no structured klog API.
+func fmtWithContextValues(ctx context.Context, format string, args ...interface{}) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, format, args...)
+
+	kvs := contextValues(ctx)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		fmt.Fprintf(&b, " %v=%q", kvs[i], fmt.Sprint(kvs[i+1]))
 	}
 
-	return logMsg
+	return b.String()
 }
 
 func LevelEnabled(level int) bool {
@@ -51,7 +57,7 @@ func Infof(format string, args ...interface{}) {
 }
 
 func InfofWithContext(ctx context.Context, format string, args ...interface{}) {
-	klog.V(LevelInfo).InfofDepth(1, tryPrependReqID(ctx, format), args...)
+	klog.V(LevelInfo).InfoSDepth(1, fmt.Sprintf(format, args...), contextValues(ctx)...)
 }
 
 func InfofDepth(depth int, format string, args ...interface{}) {
@@ -59,7 +65,7 @@ func InfofDepth(depth int, format string, args ...interface{}) {
 }
 
 func InfofWithContextDepth(ctx context.Context, depth int, format string, args ...interface{}) {
-	klog.V(LevelInfo).InfofDepth(depth, tryPrependReqID(ctx, format), args...)
+	klog.V(LevelInfo).InfoSDepth(depth, fmt.Sprintf(format, args...), contextValues(ctx)...)
 }
 
 func Debugf(format string, args ...interface{}) {
@@ -68,7 +74,7 @@ func Debugf(format string, args ...interface{}) {
 
 func DebugfWithContext(ctx context.Context, format string, args ...interface{}) {
 	if klog.V(LevelDebug).Enabled() {
-		klog.V(LevelDebug).InfofDepth(1, tryPrependReqID(ctx, format), args...)
+		klog.V(LevelDebug).InfoSDepth(1, fmt.Sprintf(format, args...), contextValues(ctx)...)
 	}
 }
 
@@ -78,7 +84,7 @@ func DebugfDepth(depth int, format string, args ...interface{}) {
 
 func DebugfWithContextDepth(ctx context.Context, depth int, format string, args ...interface{}) {
 	if klog.V(LevelDebug).Enabled() {
-		klog.V(LevelDebug).InfofDepth(depth, tryPrependReqID(ctx, format), args...)
+		klog.V(LevelDebug).InfoSDepth(depth, fmt.Sprintf(format, args...), contextValues(ctx)...)
 	}
 }
 
@@ -88,7 +94,7 @@ func Tracef(format string, args ...interface{}) {
 
 func TracefWithContext(ctx context.Context, format string, args ...interface{}) {
 	if klog.V(LevelDebug).Enabled() {
-		klog.V(LevelDebug).InfofDepth(1, tryPrependReqID(ctx, format), args...)
+		klog.V(LevelDebug).InfoSDepth(1, fmt.Sprintf(format, args...), contextValues(ctx)...)
 	}
 }
 
@@ -98,7 +104,7 @@ func TracefDepth(depth int, format string, args ...interface{}) {
 
 func TracefWithContextDepth(ctx context.Context, depth int, format string, args ...interface{}) {
 	if klog.V(LevelDebug).Enabled() {
-		klog.V(LevelDebug).InfofDepth(depth, tryPrependReqID(ctx, format), args...)
+		klog.V(LevelDebug).InfoSDepth(depth, fmt.Sprintf(format, args...), contextValues(ctx)...)
 	}
 }
 
@@ -107,7 +113,7 @@ func Warningf(format string, args ...interface{}) {
 }
 
 func WarningfWithContext(ctx context.Context, format string, args ...interface{}) {
-	klog.WarningfDepth(1, tryPrependReqID(ctx, format), args...)
+	klog.WarningfDepth(1, "%s", fmtWithContextValues(ctx, format, args...))
 }
 
 func WarningfDepth(depth int, format string, args ...interface{}) {
@@ -115,7 +121,7 @@ func WarningfDepth(depth int, format string, args ...interface{}) {
 }
 
 func WarningfWithContextDepth(ctx context.Context, depth int, format string, args ...interface{}) {
-	klog.WarningfDepth(depth, tryPrependReqID(ctx, format), args...)
+	klog.WarningfDepth(depth, "%s", fmtWithContextValues(ctx, format, args...))
 }
 
 func Errorf(format string, args ...interface{}) {
@@ -123,7 +129,7 @@ func Errorf(format string, args ...interface{}) {
 }
 
 func ErrorfWithContext(ctx context.Context, format string, args ...interface{}) {
-	klog.ErrorfDepth(1, tryPrependReqID(ctx, format), args...)
+	klog.ErrorSDepth(1, nil, fmt.Sprintf(format, args...), contextValues(ctx)...)
 }
 
 func ErrorfDepth(depth int, format string, args ...interface{}) {
@@ -131,7 +137,7 @@ func ErrorfDepth(depth int, format string, args ...interface{}) {
 }
 
 func ErrorfWithContextDepth(ctx context.Context, depth int, format string, args ...interface{}) {
-	klog.ErrorfDepth(depth, tryPrependReqID(ctx, format), args...)
+	klog.ErrorSDepth(depth, nil, fmt.Sprintf(format, args...), contextValues(ctx)...)
 }
 
 func Fatalf(format string, args ...interface{}) {
@@ -139,7 +145,7 @@ func Fatalf(format string, args ...interface{}) {
 }
 
 func FatalfWithContext(ctx context.Context, format string, args ...interface{}) {
-	klog.FatalfDepth(1, tryPrependReqID(ctx, format), args...)
+	klog.FatalfDepth(1, "%s", fmtWithContextValues(ctx, format, args...))
 }
 
 func FatalfDepth(depth int, format string, args ...interface{}) {
@@ -147,5 +153,5 @@ func FatalfDepth(depth int, format string, args ...interface{}) {
 }
 
 func FatalfWithContextDepth(ctx context.Context, depth int, format string, args ...interface{}) {
-	klog.FatalfDepth(depth, tryPrependReqID(ctx, format), args...)
+	klog.FatalfDepth(depth, "%s", fmtWithContextValues(ctx, format, args...))
 }
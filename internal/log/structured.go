@@ -0,0 +1,166 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+
+	"k8s.io/klog/v2"
+)
+
+// Log formats accepted by SetFormat.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Keys of structured log fields. Keep these stable, as they
+// are used for indexing driver activity in log pipelines.
+const (
+	KeyReqID      = "reqID"
+	KeyRPC        = "rpc"
+	KeyCallID     = "callID"
+	KeyExecID     = "execID"
+	KeyVolumeID   = "volumeID"
+	KeyMountID    = "mountID"
+	KeyRepository = "repository"
+	KeyTargetPath = "targetPath"
+	KeyTraceID    = "traceID"
+)
+
+// SetFormat sets the output format of all log messages. Must be called
+// after flags are parsed, and before any other goroutines start logging.
+func SetFormat(format string) error {
+	switch format {
+	case FormatText:
+		// klog's default text output.
+		return nil
+	case FormatJSON:
+		klog.SetSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			// Verbosity is already filtered by klog based on its -v flag,
+			// so we let through messages of all levels here.
+			Level: slog.Level(math.MinInt32),
+		})))
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q, allowed values are: '%s', '%s'",
+			format, FormatText, FormatJSON)
+	}
+}
+
+type contextValuesKey struct{}
+
+// WithValues returns a copy of ctx annotated with structured log fields.
+// The fields are added to all messages logged with ctx.
+func WithValues(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	prev, _ := ctx.Value(contextValuesKey{}).([]interface{})
+
+	kvs := make([]interface{}, 0, len(prev)+len(keysAndValues))
+	kvs = append(kvs, prev...)
+	kvs = append(kvs, keysAndValues...)
+
+	return context.WithValue(ctx, contextValuesKey{}, kvs)
+}
+
+func contextValues(ctx context.Context, keysAndValues ...interface{}) []interface{} {
+	var kvs []interface{}
+
+	if reqID := ctx.Value(ReqIDContextKey); reqID != nil {
+		kvs = append(kvs, KeyReqID, reqID)
+	}
+
+	if ctxKvs, ok := ctx.Value(contextValuesKey{}).([]interface{}); ok {
+		kvs = append(kvs, ctxKvs...)
+	}
+
+	return append(kvs, keysAndValues...)
+}
+
+// RequestValues returns structured log fields describing a GRPC request,
+// e.g. volume ID and target path of CSI requests, or mount ID of
+// singlemount-runner requests. Fields that are not set are omitted.
+func RequestValues(req interface{}) []interface{} {
+	var kvs []interface{}
+
+	addString := func(key, value string) {
+		if value != "" {
+			kvs = append(kvs, key, value)
+		}
+	}
+
+	if r, ok := req.(interface{ GetVolumeId() string }); ok {
+		addString(KeyVolumeID, r.GetVolumeId())
+	}
+	if r, ok := req.(interface{ GetMountId() string }); ok {
+		addString(KeyMountID, r.GetMountId())
+	}
+	if r, ok := req.(interface{ GetRepository() string }); ok {
+		addString(KeyRepository, r.GetRepository())
+	}
+	if r, ok := req.(interface{ GetVolumeContext() map[string]string }); ok {
+		addString(KeyRepository, r.GetVolumeContext()["repository"])
+	}
+	if r, ok := req.(interface{ GetTargetPath() string }); ok {
+		addString(KeyTargetPath, r.GetTargetPath())
+	}
+	if r, ok := req.(interface{ GetTarget() string }); ok {
+		addString(KeyTargetPath, r.GetTarget())
+	}
+	if r, ok := req.(interface{ GetMountpoint() string }); ok {
+		addString(KeyTargetPath, r.GetMountpoint())
+	}
+
+	return kvs
+}
+
+func InfoS(msg string, keysAndValues ...interface{}) {
+	klog.V(LevelInfo).InfoSDepth(1, msg, keysAndValues...)
+}
+
+func InfoSWithContext(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	klog.V(LevelInfo).InfoSDepth(1, msg, contextValues(ctx, keysAndValues...)...)
+}
+
+func InfoSDepth(depth int, msg string, keysAndValues ...interface{}) {
+	klog.V(LevelInfo).InfoSDepth(depth, msg, keysAndValues...)
+}
+
+func DebugS(msg string, keysAndValues ...interface{}) {
+	klog.V(LevelDebug).InfoSDepth(1, msg, keysAndValues...)
+}
+
+func DebugSWithContext(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	if klog.V(LevelDebug).Enabled() {
+		klog.V(LevelDebug).InfoSDepth(1, msg, contextValues(ctx, keysAndValues...)...)
+	}
+}
+
+func ErrorS(err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorSDepth(1, err, msg, keysAndValues...)
+}
+
+func ErrorSWithContext(ctx context.Context, err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorSDepth(1, err, msg, contextValues(ctx, keysAndValues...)...)
+}
+
+func ErrorSDepth(depth int, err error, msg string, keysAndValues ...interface{}) {
+	klog.ErrorSDepth(depth, err, msg, keysAndValues...)
+}
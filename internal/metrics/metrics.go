@@ -0,0 +1,148 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package metrics holds the Prometheus registry shared by all CVMFS CSI
+// commands, together with metrics common to all of them. Component-specific
+// metrics are defined in their respective packages and registered with
+// MustRegister.
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	goexec "os/exec"
+	"path"
+	"time"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// Namespace of all CVMFS CSI metrics.
+	Namespace = "cvmfscsi"
+
+	// Path where the metrics are served.
+	metricsPath = "/metrics"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	grpcRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "Number of handled gRPC requests.",
+		},
+		[]string{"method", "code"},
+	)
+
+	grpcRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of handled gRPC requests.",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 30, 60, 120},
+		},
+		[]string{"method"},
+	)
+
+	execDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "exec",
+			Name:      "duration_seconds",
+			Help:      "Duration of executed commands.",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 30, 60, 120},
+		},
+		[]string{"command"},
+	)
+
+	execFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "exec",
+			Name:      "failures_total",
+			Help:      "Number of executed commands that have failed.",
+		},
+		[]string{"command"},
+	)
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		grpcRequests,
+		grpcRequestDuration,
+		execDuration,
+		execFailures,
+	)
+}
+
+// MustRegister registers collectors in the registry served by Serve.
+func MustRegister(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+// ObserveGRPCRequest records a handled gRPC request.
+func ObserveGRPCRequest(method string, code codes.Code, duration time.Duration) {
+	grpcRequests.WithLabelValues(method, code.String()).Inc()
+	grpcRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// ObserveExec records a finished command.
+func ObserveExec(cmd *goexec.Cmd, duration time.Duration, err error) {
+	command := cmd.Path
+	if len(cmd.Args) > 0 {
+		command = cmd.Args[0]
+	}
+	command = path.Base(command)
+
+	execDuration.WithLabelValues(command).Observe(duration.Seconds())
+	if err != nil {
+		execFailures.WithLabelValues(command).Inc()
+	}
+}
+
+// Serve starts serving metrics over HTTP on address in background.
+func Serve(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	log.Infof("Serving metrics on %s%s", listener.Addr(), metricsPath)
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Errorf("Failed to serve metrics: %v", err)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright CERN.
+//
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package tracing sets up OpenTelemetry tracing shared by all CVMFS CSI
+// commands. Tracing is disabled unless Init is called with an exporter:
+// until then, or if the exporter is empty, spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cvmfs-contrib/cvmfs-csi/internal/log"
+	cvmfsversion "github.com/cvmfs-contrib/cvmfs-csi/internal/version"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Name of the tracer used for all CVMFS CSI spans.
+	tracerName = "github.com/cvmfs-contrib/cvmfs-csi"
+
+	// Exporters accepted by Init.
+	exporterOTLP   = "otlp"
+	exporterStdout = "stdout"
+	fileScheme     = "file://"
+)
+
+// Init configures the global tracer provider to export spans with the selected
+// exporter. Supported exporters are:
+//
+//   - "otlp": OTLP over gRPC, configured with the standard OTEL_EXPORTER_OTLP_*
+//     environment variables (e.g. OTEL_EXPORTER_OTLP_ENDPOINT),
+//   - "stdout": spans are written to standard output as JSON,
+//   - "file://<absolute path>": spans are appended to the file as JSON.
+//
+// If exporter is empty, tracing stays disabled. The returned function flushes
+// any remaining spans and should be called before the program exits.
+func Init(ctx context.Context, serviceName, exporter string) (func(context.Context) error, error) {
+	if exporter == "" {
+		// Tracing is disabled, keep the no-op global tracer provider.
+		return func(context.Context) error { return nil }, nil
+	}
+
+	spanExporter, closer, err := newExporter(ctx, exporter)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(cvmfsversion.Version()),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracing resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	log.Infof("Exporting traces to %s", exporter)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if err2 := closer.Close(); err == nil {
+				err = err2
+			}
+		}
+		return err
+	}, nil
+}
+
+// newExporter creates the span exporter. If the exporter writes into a file,
+// the file is returned too, so that it can be closed once the exporter shuts down.
+func newExporter(ctx context.Context, exporter string) (sdktrace.SpanExporter, io.Closer, error) {
+	switch {
+	case exporter == exporterOTLP:
+		exp, err := otlptracegrpc.New(ctx)
+		return exp, nil, err
+	case exporter == exporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exp, nil, err
+	case strings.HasPrefix(exporter, fileScheme):
+		f, err := openExportFile(strings.TrimPrefix(exporter, fileScheme))
+		if err != nil {
+			return nil, nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exp, f, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported tracing exporter %q, expected one of '%s', '%s', '%s<absolute path>'",
+			exporter, exporterOTLP, exporterStdout, fileScheme)
+	}
+}
+
+func openExportFile(filepath string) (*os.File, error) {
+	if !strings.HasPrefix(filepath, "/") {
+		return nil, fmt.Errorf("tracing export file path must be absolute, got %q", filepath)
+	}
+
+	f, err := os.OpenFile(filepath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tracing export file: %v", err)
+	}
+
+	return f, nil
+}
+
+// Start starts a new span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err in span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// TraceID returns ID of the trace the span in ctx belongs to.
+// Returns an empty string if tracing is disabled.
+func TraceID(ctx context.Context) string {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.HasTraceID() {
+		return ""
+	}
+
+	return spanCtx.TraceID().String()
+}
+
+// LogValues converts structured log fields into span attributes.
+func LogValues(keysAndValues ...interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(keysAndValues)/2)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		attrs = append(attrs, attribute.String(fmt.Sprint(keysAndValues[i]), fmt.Sprint(keysAndValues[i+1])))
+	}
+
+	return attrs
+}